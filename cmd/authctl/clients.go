@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cheebo/rand"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/issuer"
+	"github.com/nori-io/auth/service/oauth"
+)
+
+// createClient registers an OAuth client, the secret is printed once and only its hash is stored
+func createClient(args []string) error {
+	fs := flag.NewFlagSet("create-client", flag.ExitOnError)
+	dsn := fs.String("dsn", "", "mysql data source name")
+	name := fs.String("name", "", "client name")
+	redirectURIs := fs.String("redirect-uris", "", "space separated redirect URIs")
+	postLogoutURIs := fs.String("post-logout-redirect-uris", "", "space separated URIs allowed after logout")
+	grantTypes := fs.String("grant-types", "", "space separated grant types")
+	scopes := fs.String("scopes", "", "space separated scopes the client may request")
+	public := fs.Bool("public", false, "public client without a secret, e.g. a mobile or single page app using PKCE")
+	publicKey := fs.String("public-key", "", "PEM file of the key the client signs private_key_jwt assertions with, no secret is generated")
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	db, err := open(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	key := ""
+	if *publicKey != "" {
+		data, err := os.ReadFile(*publicKey)
+		if err != nil {
+			return err
+		}
+		if _, _, err := issuer.ParsePublicKey(string(data)); err != nil {
+			return err
+		}
+		key = string(data)
+	}
+
+	secret, secretHash := "", ""
+	if !*public && key == "" {
+		secret = rand.RandomAlphaNum(48)
+		secretHash = oauth.HashSecret(secret)
+	}
+	now := time.Now()
+	client := &database.OAuthClientModel{
+		ClientId:               rand.RandomAlphaNum(24),
+		SecretHash:             secretHash,
+		Name:                   *name,
+		RedirectURIs:           strings.Fields(*redirectURIs),
+		PostLogoutRedirectURIs: strings.Fields(*postLogoutURIs),
+		GrantTypes:             strings.Fields(*grantTypes),
+		Scopes:                 strings.Fields(*scopes),
+		PublicKey:              key,
+		Created:                now,
+		Updated:                now,
+	}
+	if err := database.New(db, nil).OAuthClients().Create(context.Background(), client); err != nil {
+		return err
+	}
+	fmt.Printf("client_id:     %s\n", client.ClientId)
+	if secret != "" {
+		fmt.Printf("client_secret: %s\n", secret)
+	}
+	return nil
+}
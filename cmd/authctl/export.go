@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/database"
+)
+
+// exportUsers writes the users with service.WriteUsers, the same export the admin endpoint streams
+func exportUsers(args []string) error {
+	fs := flag.NewFlagSet("export-users", flag.ExitOnError)
+	dsn := fs.String("dsn", "", "mysql data source name")
+	path := fs.String("keyring", "", "keyring file, empty when phones are stored unencrypted")
+	format := fs.String("format", service.UsersExportJSONL, "csv or jsonl")
+	fields := fs.String("fields", "", "comma separated fields, all by default: "+strings.Join(service.UsersExportFields, ", "))
+	userType := fs.String("type", "", "type of the exported users, all types by default")
+	file := fs.String("file", "-", "file the users are written to, - writes stdout")
+	fs.Parse(args)
+
+	var selected []string
+	if *fields != "" {
+		selected = strings.Split(*fields, ",")
+	}
+	if err := service.UsersExportFieldsValid(selected); err != nil {
+		return err
+	}
+	if *format != service.UsersExportCSV && *format != service.UsersExportJSONL {
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	db, repo, err := openDatabase(*dsn, *path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	out := os.Stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	filter := database.UsersFilter{Type: *userType}
+	if err := service.WriteUsers(context.Background(), repo, w, *format, selected, filter); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/password"
+)
+
+// importedUser is the row of import-users, either the password or the hash of another system is set
+type importedUser struct {
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	Password      string `json:"password"`
+	PasswordHash  string `json:"password_hash"`
+	Salt          string `json:"salt"`
+	Type          string `json:"type"`
+	StatusId      int64  `json:"status_id"`
+	EmailVerified bool   `json:"email_verified"`
+
+	line int
+}
+
+// rowError is the error of a single row, the import goes on with the next row
+type rowError struct {
+	line  int
+	email string
+	err   error
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+// importUsers creates the users of -file in batches, each batch is inserted in a transaction.
+// The rows which can't be imported are reported and skipped
+func importUsers(args []string) error {
+	fs := flag.NewFlagSet("import-users", flag.ExitOnError)
+	dsn := fs.String("dsn", "", "mysql data source name")
+	path := fs.String("keyring", "", "keyring file, empty when phones are stored unencrypted")
+	file := fs.String("file", "", "CSV file with a header row or JSON lines file, - reads stdin")
+	format := fs.String("format", "", "csv or jsonl, guessed from the file extension by default")
+	batch := fs.Int("batch", 100, "users inserted in one transaction")
+	dryRun := fs.Bool("dry-run", false, "check the rows without creating users")
+	report := fs.String("report", "", "CSV file the failed rows are written to, stderr by default")
+	fs.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+	if *batch < 1 {
+		return fmt.Errorf("-batch must be positive")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+	in := os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var next func() (*importedUser, error)
+	switch *format {
+	case "csv":
+		next = csvRows(in)
+	case "jsonl", "json", "ndjson", "":
+		next = jsonRows(in)
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	failed := newImportReport(os.Stderr)
+	if *report != "" {
+		f, err := os.Create(*report)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		failed = newImportReport(f)
+	}
+	defer failed.Flush()
+
+	db, repo, err := openDatabase(*dsn, *path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	imported := 0
+	seen := map[string]int{}
+	var pending []*importedUser
+	flush := func() error {
+		if !*dryRun {
+			n, err := insertBatch(ctx, repo, pending, failed)
+			if err != nil {
+				return err
+			}
+			imported += n
+		} else {
+			imported += len(pending)
+		}
+		pending = pending[:0]
+		return nil
+	}
+
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			failed.add(rowErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkImported(ctx, repo, row, seen); err != nil {
+			failed.add(&rowError{line: row.line, email: row.Email, err: err})
+			continue
+		}
+		if pending = append(pending, row); len(pending) == *batch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%s %d users, %d rows failed\n", verb, imported, failed.count)
+	if failed.count > 0 {
+		return fmt.Errorf("%d rows failed", failed.count)
+	}
+	return nil
+}
+
+// checkImported validates the row and hashes its password, seen maps emails to the lines they were read from
+func checkImported(ctx context.Context, repo database.Database, row *importedUser, seen map[string]int) error {
+	row.Email = strings.TrimSpace(row.Email)
+	if !govalidator.IsEmail(row.Email) {
+		return fmt.Errorf("email is invalid")
+	}
+	key := strings.ToLower(row.Email)
+	if line, ok := seen[key]; ok {
+		return fmt.Errorf("email is a duplicate of line %d", line)
+	}
+	seen[key] = row.line
+
+	switch row.Type {
+	case "":
+		row.Type = database.UserTypeUser
+	case database.UserTypeUser, database.UserTypeAdmin:
+	default:
+		return fmt.Errorf("type %s is not allowed", row.Type)
+	}
+	if row.StatusId != database.UserStatusActive && row.StatusId != database.UserStatusSuspended {
+		return fmt.Errorf("status_id %d is unknown", row.StatusId)
+	}
+
+	switch {
+	case row.Password != "" && row.PasswordHash != "":
+		return fmt.Errorf("either password or password_hash must be set")
+	case row.Password != "":
+		hash, err := password.Hash(row.Password)
+		if err != nil {
+			return err
+		}
+		row.PasswordHash, row.Salt, row.Password = hash, "", ""
+	case row.PasswordHash == "":
+		return fmt.Errorf("password or password_hash is required")
+	case password.Format(row.PasswordHash, row.Salt) == "":
+		return fmt.Errorf("password_hash is in an unknown format")
+	}
+
+	_, err := repo.Auth().FindByEmail(ctx, row.Email)
+	if err == nil {
+		return database.ErrDuplicateEmail
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// insertBatch creates the users of rows in one transaction. When it fails the rows are
+// inserted one by one to find the failing ones, the others are still imported
+func insertBatch(ctx context.Context, repo database.Database, rows []*importedUser, failed *importReport) (int, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	err := repo.Tx(ctx, func(tx database.Database) error {
+		for _, row := range rows {
+			if err := insertImported(ctx, tx, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return len(rows), nil
+	}
+	if len(rows) == 1 {
+		failed.add(&rowError{line: rows[0].line, email: rows[0].Email, err: err})
+		return 0, nil
+	}
+
+	imported := 0
+	for _, row := range rows {
+		n, err := insertBatch(ctx, repo, []*importedUser{row}, failed)
+		if err != nil {
+			return imported, err
+		}
+		imported += n
+	}
+	return imported, nil
+}
+
+func insertImported(ctx context.Context, tx database.Database, row *importedUser) error {
+	now := time.Now()
+	model := &database.AuthModel{
+		Email_Auth:           row.Email,
+		Phone_Auth:           row.Phone,
+		Password_Auth:        row.PasswordHash,
+		Salt_Auth:            row.Salt,
+		PasswordChanged_Auth: now,
+		IsEmailVerified_Auth: row.EmailVerified,
+		Created_Auth:         now,
+		Updated_Auth:         now,
+		StatusId_Users:       row.StatusId,
+		Type_Users:           row.Type,
+		Created_Users:        now,
+		Updated_Users:        now,
+	}
+	if err := tx.Auth().Create(ctx, model); err != nil {
+		return err
+	}
+	// the history has no salt, salted hashes would never match
+	if row.Salt != "" {
+		return nil
+	}
+	return tx.PasswordHistory().Create(ctx, &database.PasswordHistoryModel{
+		UserId:  model.UserId_Auth,
+		Hash:    row.PasswordHash,
+		Created: now,
+	})
+}
+
+// jsonRows reads a user per line, empty lines are skipped
+func jsonRows(r io.Reader) func() (*importedUser, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	return func() (*importedUser, error) {
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			row := &importedUser{line: line}
+			if err := json.Unmarshal(scanner.Bytes(), row); err != nil {
+				return nil, &rowError{line: line, err: err}
+			}
+			return row, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
+
+// csvRows reads a user per record, the columns are named by the header with the json names of importedUser
+func csvRows(r io.Reader) func() (*importedUser, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var header []string
+	return func() (*importedUser, error) {
+		if header == nil {
+			h, err := reader.Read()
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			for i := range h {
+				h[i] = strings.ToLower(strings.TrimSpace(h[i]))
+			}
+			header = h
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &rowError{line: parseErr.StartLine, err: parseErr.Err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		row := &importedUser{line: line}
+		for i, value := range record {
+			if i >= len(header) {
+				return nil, &rowError{line: line, err: fmt.Errorf("row has more columns than the header")}
+			}
+			if err := row.set(header[i], value); err != nil {
+				return nil, &rowError{line: line, email: row.Email, err: err}
+			}
+		}
+		return row, nil
+	}
+}
+
+func (u *importedUser) set(column, value string) error {
+	var err error
+	switch column {
+	case "email":
+		u.Email = value
+	case "phone":
+		u.Phone = value
+	case "password":
+		u.Password = value
+	case "password_hash":
+		u.PasswordHash = value
+	case "salt":
+		u.Salt = value
+	case "type":
+		u.Type = value
+	case "status_id":
+		if value != "" {
+			u.StatusId, err = strconv.ParseInt(value, 10, 64)
+		}
+	case "email_verified":
+		if value != "" {
+			u.EmailVerified, err = strconv.ParseBool(value)
+		}
+	default:
+		return fmt.Errorf("column %s is unknown", column)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", column, err)
+	}
+	return nil
+}
+
+// importReport writes the failed rows as CSV of line, email and error
+type importReport struct {
+	*csv.Writer
+	count int
+}
+
+func newImportReport(w io.Writer) *importReport {
+	return &importReport{Writer: csv.NewWriter(w)}
+}
+
+func (r *importReport) add(err *rowError) {
+	if r.count == 0 {
+		r.Write([]string{"line", "email", "error"})
+	}
+	r.count++
+	r.Write([]string{strconv.Itoa(err.line), err.email, err.err.Error()})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/password"
+)
+
+// readAll returns the rows and the lines of the row errors
+func readAll(t *testing.T, next func() (*importedUser, error)) ([]*importedUser, []int) {
+	t.Helper()
+	var rows []*importedUser
+	var failed []int
+	for {
+		row, err := next()
+		if err == io.EOF {
+			return rows, failed
+		}
+		var rowErr *rowError
+		if errors.As(err, &rowErr) {
+			failed = append(failed, rowErr.line)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	in := "Email, Password_Hash,salt,status_id,email_verified\n" +
+		"a@example.com,hash,salt,1,true\n" +
+		"b@example.com,hash,,,\n" +
+		"c@example.com,hash,,one,\n" +
+		"d@example.com,hash,,0,false,extra\n" +
+		"\"e@example.com,hash\n"
+	rows, failed := readAll(t, csvRows(strings.NewReader(in)))
+	if len(rows) != 2 || len(failed) != 3 {
+		t.Fatalf("rows = %d, failed = %v", len(rows), failed)
+	}
+	if a := rows[0]; a.Email != "a@example.com" || a.PasswordHash != "hash" || a.Salt != "salt" || a.StatusId != 1 || !a.EmailVerified || a.line != 2 {
+		t.Errorf("row = %+v", a)
+	}
+	if b := rows[1]; b.StatusId != 0 || b.EmailVerified || b.line != 3 {
+		t.Errorf("row = %+v", b)
+	}
+	if failed[0] != 4 || failed[1] != 5 {
+		t.Errorf("failed lines = %v", failed)
+	}
+
+	if _, failed := readAll(t, csvRows(strings.NewReader("email,unknown\na@example.com,x\n"))); len(failed) != 1 {
+		t.Errorf("unknown column: failed = %v", failed)
+	}
+}
+
+func TestJSONRows(t *testing.T) {
+	in := `{"email":"a@example.com","password":"secret","type":"admin"}` + "\n\n" +
+		`{"email":` + "\n" +
+		`{"email":"b@example.com","status_id":1}` + "\n"
+	rows, failed := readAll(t, jsonRows(strings.NewReader(in)))
+	if len(rows) != 2 || len(failed) != 1 || failed[0] != 3 {
+		t.Fatalf("rows = %d, failed = %v", len(rows), failed)
+	}
+	if rows[0].Type != database.UserTypeAdmin || rows[0].Password != "secret" || rows[1].line != 4 || rows[1].StatusId != 1 {
+		t.Errorf("rows = %+v, %+v", rows[0], rows[1])
+	}
+}
+
+func TestCheckImported(t *testing.T) {
+	ctx := context.Background()
+	repo := database.NewMemory()
+	if _, err := newUser(ctx, repo, "existing@example.com", "Xy7!kq2Lmn#p", database.UserTypeUser); err != nil {
+		t.Fatal(err)
+	}
+	const phpass = "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0"
+	seen := map[string]int{"seen@example.com": 1}
+
+	for _, tc := range []struct {
+		name string
+		row  importedUser
+		ok   bool
+	}{
+		{"password", importedUser{Email: " a@example.com ", Password: "secret"}, true},
+		{"legacy hash", importedUser{Email: "b@example.com", PasswordHash: phpass}, true},
+		{"suspended admin", importedUser{Email: "c@example.com", PasswordHash: phpass, Type: database.UserTypeAdmin, StatusId: database.UserStatusSuspended}, true},
+		{"invalid email", importedUser{Email: "user", Password: "secret"}, false},
+		{"duplicate in the file", importedUser{Email: "SEEN@example.com", Password: "secret"}, false},
+		{"existing user", importedUser{Email: "existing@example.com", Password: "secret"}, false},
+		{"service type", importedUser{Email: "d@example.com", Password: "secret", Type: database.UserTypeService}, false},
+		{"unknown status", importedUser{Email: "e@example.com", Password: "secret", StatusId: 7}, false},
+		{"password and hash", importedUser{Email: "f@example.com", Password: "secret", PasswordHash: phpass}, false},
+		{"no password", importedUser{Email: "g@example.com"}, false},
+		{"unknown hash", importedUser{Email: "h@example.com", PasswordHash: "5f4dcc3b5aa765d61d8327deb882cf99"}, false},
+	} {
+		row := tc.row
+		if err := checkImported(ctx, repo, &row, seen); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+
+	row := importedUser{Email: "i@example.com", Password: "secret"}
+	if err := checkImported(ctx, repo, &row, seen); err != nil {
+		t.Fatal(err)
+	}
+	if row.Password != "" || row.Type != database.UserTypeUser {
+		t.Errorf("row = %+v", row)
+	}
+	if ok, _ := password.Verify(row.PasswordHash, row.Salt, "secret", false); !ok {
+		t.Error("password is not hashed")
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := database.NewMemory()
+	rows := []*importedUser{
+		{Email: "a@example.com", PasswordHash: "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", Type: database.UserTypeUser, line: 2},
+		// the duplicate fails the transaction, the other rows are inserted one by one
+		{Email: "a@example.com", PasswordHash: "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", Type: database.UserTypeUser, line: 3},
+		{Email: "b@example.com", PasswordHash: "d1c6f9b1f6f2d1a9f0c0e5d3c1e9a2b8f2c4d6e8a0b2c4d6e8f0a2b4c6d8e0f2", Salt: "salt", Type: database.UserTypeUser, line: 4},
+	}
+	var out bytes.Buffer
+	report := newImportReport(&out)
+
+	imported, err := insertBatch(ctx, repo, rows, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.Flush()
+	if imported != 2 || report.count != 1 {
+		t.Errorf("imported = %d, failed = %d", imported, report.count)
+	}
+	if !strings.HasPrefix(out.String(), "line,email,error\n3,a@example.com,") {
+		t.Errorf("report = %q", out.String())
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := repo.Auth().FindByEmail(ctx, email); err != nil {
+			t.Errorf("%s: %v", email, err)
+		}
+	}
+}
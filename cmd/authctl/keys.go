@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/keyring"
+)
+
+func generateKey(args []string) error {
+	fs := flag.NewFlagSet("generate-key", flag.ExitOnError)
+	fs.Parse(args)
+
+	key, err := keyring.Generate()
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+// rotateKeys re-encrypts stale values, it is run after a new active key is added to the keyring
+// and the service is restarted with it. Old keys may be removed from the keyring afterwards
+func rotateKeys(args []string) error {
+	fs := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	dsn := fs.String("dsn", "", "mysql data source name, e.g. user:password@tcp(localhost:3306)/auth?parseTime=true")
+	path := fs.String("keyring", "", "keyring file")
+	fs.Parse(args)
+
+	if *path == "" {
+		return fmt.Errorf("-keyring is required")
+	}
+	k, err := keyring.Load(*path)
+	if err != nil {
+		return err
+	}
+	db, err := open(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	updated, err := database.Reencrypt(context.Background(), db, k)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("re-encrypted %d values with key %s\n", updated, k.ActiveKey())
+	return nil
+}
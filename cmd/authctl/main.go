@@ -0,0 +1,142 @@
+// authctl is the maintenance tool of the auth plugin database
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/keyring"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"migrate": {
+		usage: "creates missing tables and upgrades the tables of older releases",
+		run:   migrate,
+	},
+	"create-user": {
+		usage: "creates a user or, with -admin, an administrator",
+		run:   createUser,
+	},
+	"reset-password": {
+		usage: "sets the password of the user and signs out all sessions",
+		run:   resetPassword,
+	},
+	"reset-mfa": {
+		usage: "removes the MFA secret, phone and recovery codes of the user",
+		run:   resetMfa,
+	},
+	"lock": {
+		usage: "suspends the user and signs out all sessions",
+		run:   lock,
+	},
+	"unlock": {
+		usage: "reinstates the suspended user",
+		run:   unlock,
+	},
+	"sessions": {
+		usage: "lists the sessions of the user",
+		run:   sessions,
+	},
+	"export-users": {
+		usage: "writes the users as CSV or JSON lines with the selected fields",
+		run:   exportUsers,
+	},
+	"import-users": {
+		usage: "creates the users of CSV or JSON lines, password hashes of other systems are kept",
+		run:   importUsers,
+	},
+	"create-client": {
+		usage: "registers an OAuth client and prints its credentials",
+		run:   createClient,
+	},
+	"generate-key": {
+		usage: "prints a new random master key",
+		run:   generateKey,
+	},
+	"rotate-keys": {
+		usage: "re-encrypts phones, mfa secrets and recovery codes with the active master key",
+		run:   rotateKeys,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "authctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: authctl <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].usage)
+	}
+}
+
+// open connects to the database of dsn flag
+func open(dsn string) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("-dsn is required")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openDatabase connects to the database, phones and mfa secrets are encrypted with
+// the keyring of the path and stored unencrypted when it is empty
+func openDatabase(dsn, path string) (*sql.DB, database.Database, error) {
+	cipher, err := loadCipher(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := open(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, database.New(db, cipher), nil
+}
+
+// loadCipher loads the keyring of the path, nil is returned for the empty path
+func loadCipher(path string) (database.Cipher, error) {
+	if path == "" {
+		return nil, nil
+	}
+	k, err := keyring.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
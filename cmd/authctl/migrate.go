@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+// migrate runs database.Migrate, the keyring is needed to fill the phone hashes of encrypted phones
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dsn := fs.String("dsn", "", "mysql data source name")
+	path := fs.String("keyring", "", "keyring file, empty when phones are stored unencrypted")
+	fs.Parse(args)
+
+	cipher, err := loadCipher(*path)
+	if err != nil {
+		return err
+	}
+	db, err := open(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := database.Migrate(context.Background(), db, cipher)
+	for _, change := range applied {
+		fmt.Println(change)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("the schema is up to date")
+	}
+	return nil
+}
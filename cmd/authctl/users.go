@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/password"
+)
+
+// userFlags are the flags of the commands changing a single user
+type userFlags struct {
+	dsn     *string
+	keyring *string
+	email   *string
+}
+
+func newUserFlags(fs *flag.FlagSet) userFlags {
+	return userFlags{
+		dsn:     fs.String("dsn", "", "mysql data source name"),
+		keyring: fs.String("keyring", "", "keyring file, empty when phones and mfa secrets are stored unencrypted"),
+		email:   fs.String("email", "", "email of the user"),
+	}
+}
+
+// find opens the database and finds the user of -email
+func (f userFlags) find(ctx context.Context) (func() error, database.Database, *database.AuthModel, error) {
+	if *f.email == "" {
+		return nil, nil, nil, fmt.Errorf("-email is required")
+	}
+	db, repo, err := openDatabase(*f.dsn, *f.keyring)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	model, err := repo.Auth().FindByEmail(ctx, *f.email)
+	if errors.Is(err, database.ErrNotFound) {
+		db.Close()
+		return nil, nil, nil, fmt.Errorf("user %s not found", *f.email)
+	}
+	if err != nil {
+		db.Close()
+		return nil, nil, nil, err
+	}
+	return db.Close, repo, model, nil
+}
+
+func createUser(args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ExitOnError)
+	f := newUserFlags(fs)
+	pw := fs.String("password", "", "password of the user")
+	admin := fs.Bool("admin", false, "create an administrator")
+	fs.Parse(args)
+
+	if *f.email == "" || *pw == "" {
+		return fmt.Errorf("-email and -password are required")
+	}
+	db, repo, err := openDatabase(*f.dsn, *f.keyring)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	userType := database.UserTypeUser
+	if *admin {
+		userType = database.UserTypeAdmin
+	}
+	model, err := newUser(context.Background(), repo, *f.email, *pw, userType)
+	if errors.Is(err, database.ErrDuplicateEmail) {
+		return fmt.Errorf("user %s already exists", *f.email)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %s %d\n", userType, model.UserId_Auth)
+	return nil
+}
+
+// newUser creates the active user with the password, it is recorded in the password history
+func newUser(ctx context.Context, repo database.Database, email, pw, userType string) (*database.AuthModel, error) {
+	hash, err := password.Hash(pw)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	model := &database.AuthModel{
+		Email_Auth:           email,
+		Password_Auth:        hash,
+		PasswordChanged_Auth: now,
+		Created_Auth:         now,
+		Updated_Auth:         now,
+		StatusId_Users:       database.UserStatusActive,
+		Type_Users:           userType,
+		Created_Users:        now,
+		Updated_Users:        now,
+	}
+	err = repo.Tx(ctx, func(tx database.Database) error {
+		if err := tx.Auth().Create(ctx, model); err != nil {
+			return err
+		}
+		return tx.PasswordHistory().Create(ctx, &database.PasswordHistoryModel{
+			UserId:  model.UserId_Auth,
+			Hash:    hash,
+			Created: now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func resetPassword(args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	f := newUserFlags(fs)
+	pw := fs.String("password", "", "new password of the user")
+	ttl := fs.Duration("token-ttl", time.Hour, "access token lifetime, jwt.ttl of the plugin")
+	fs.Parse(args)
+
+	if *pw == "" {
+		return fmt.Errorf("-password is required")
+	}
+	ctx := context.Background()
+	closeDb, repo, model, err := f.find(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	hash, err := password.Hash(*pw)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	model.Password_Auth = hash
+	model.Salt_Auth = ""
+	model.PasswordChanged_Auth = now
+	model.Updated_Auth = now
+	err = repo.Tx(ctx, func(tx database.Database) error {
+		if err := tx.Auth().Update(ctx, model); err != nil {
+			return err
+		}
+		return tx.PasswordHistory().Create(ctx, &database.PasswordHistoryModel{
+			UserId:  model.UserId_Auth,
+			Hash:    hash,
+			Created: now,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	revoked, err := revokeSessions(ctx, repo, model.UserId_Auth, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("password of %s is reset, %d sessions signed out\n", model.Email_Auth, revoked)
+	return nil
+}
+
+func resetMfa(args []string) error {
+	fs := flag.NewFlagSet("reset-mfa", flag.ExitOnError)
+	f := newUserFlags(fs)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	closeDb, repo, model, err := f.find(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	err = repo.Tx(ctx, func(tx database.Database) error {
+		user, err := tx.Users().FindByID(ctx, model.UserId_Auth)
+		if err != nil {
+			return err
+		}
+		if err := tx.Mfa().Delete(ctx, user.Id); err != nil {
+			return err
+		}
+		user.MfaType = ""
+		user.Updated = time.Now()
+		return tx.Users().Update(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("mfa of %s is reset\n", model.Email_Auth)
+	return nil
+}
+
+func lock(args []string) error {
+	fs := flag.NewFlagSet("lock", flag.ExitOnError)
+	f := newUserFlags(fs)
+	ttl := fs.Duration("token-ttl", time.Hour, "access token lifetime, jwt.ttl of the plugin")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	closeDb, repo, model, err := f.find(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	if err := setStatus(ctx, repo, model.UserId_Auth, database.UserStatusSuspended); err != nil {
+		return err
+	}
+	revoked, err := revokeSessions(ctx, repo, model.UserId_Auth, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is locked, %d sessions signed out\n", model.Email_Auth, revoked)
+	return nil
+}
+
+func unlock(args []string) error {
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	f := newUserFlags(fs)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	closeDb, repo, model, err := f.find(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	if err := setStatus(ctx, repo, model.UserId_Auth, database.UserStatusActive); err != nil {
+		return err
+	}
+	fmt.Printf("%s is unlocked\n", model.Email_Auth)
+	return nil
+}
+
+func setStatus(ctx context.Context, repo database.Database, userId uint64, status int64) error {
+	user, err := repo.Users().FindByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+	user.StatusId = status
+	user.Updated = time.Now()
+	return repo.Users().Update(ctx, user)
+}
+
+// revokeSessions denies the tokens of the open sessions and revokes the refresh tokens of the user.
+// The session store of the plugin is not reachable from here, the denylist alone rejects the tokens
+func revokeSessions(ctx context.Context, repo database.Database, userId uint64, ttl time.Duration) (revoked int, err error) {
+	if err := repo.RefreshTokens().RevokeByUser(ctx, userId); err != nil {
+		return 0, err
+	}
+	history, err := repo.AuthenticationHistory().FindByUserID(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for _, h := range history {
+		if h.Secret == "" || !h.LoggedOut.IsZero() {
+			continue
+		}
+		if expires := h.LoggedIn.Add(ttl); expires.After(now) {
+			if err := repo.RevokedTokens().Revoke(ctx, h.Secret, expires); err != nil {
+				return revoked, err
+			}
+		}
+		h.LoggedOut = now
+		if err := repo.AuthenticationHistory().Update(ctx, &h); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+func sessions(args []string) error {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	f := newUserFlags(fs)
+	all := fs.Bool("all", false, "list signed out sessions as well")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	closeDb, repo, model, err := f.find(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	history, err := repo.AuthenticationHistory().FindByUserID(ctx, model.UserId_Auth)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	fmt.Fprintf(w, "%-8s %-25s %-25s %s\n", "ID", "SIGNED IN", "SIGNED OUT", "META")
+	for _, h := range history {
+		if !*all && !h.LoggedOut.IsZero() {
+			continue
+		}
+		out := "-"
+		if !h.LoggedOut.IsZero() {
+			out = h.LoggedOut.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%-8d %-25s %-25s %s\n", h.Id, h.LoggedIn.Format(time.RFC3339), out, h.Meta)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/password"
+)
+
+func TestNewUser(t *testing.T) {
+	ctx := context.Background()
+	repo := database.NewMemory()
+
+	model, err := newUser(ctx, repo, "admin@example.com", "Xy7!kq2Lmn#p", database.UserTypeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := repo.Auth().FindByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Type_Users != database.UserTypeAdmin || found.StatusId_Users != database.UserStatusActive {
+		t.Errorf("user = %q, %d", found.Type_Users, found.StatusId_Users)
+	}
+	if ok, _ := password.Verify(found.Password_Auth, found.Salt_Auth, "Xy7!kq2Lmn#p", false); !ok {
+		t.Error("password is not set")
+	}
+	if history, err := repo.PasswordHistory().Recent(ctx, model.UserId_Auth, 10); err != nil || len(history) != 1 {
+		t.Errorf("password history = %v, %v", history, err)
+	}
+	if _, err := newUser(ctx, repo, "admin@example.com", "Xy7!kq2Lmn#p", database.UserTypeUser); !errors.Is(err, database.ErrDuplicateEmail) {
+		t.Errorf("err = %v, want ErrDuplicateEmail", err)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	repo := database.NewMemory()
+	model, err := newUser(ctx, repo, "user@example.com", "Xy7!kq2Lmn#p", database.UserTypeUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, h := range []database.AuthenticationHistoryModel{
+		{Secret: "open", LoggedIn: now.Add(-time.Minute)},
+		// the token of the old session expired, it is only signed out
+		{Secret: "expired", LoggedIn: now.Add(-2 * time.Hour)},
+		{Secret: "closed", LoggedIn: now.Add(-time.Minute), LoggedOut: now},
+	} {
+		h.UserId = model.UserId_Auth
+		if err := repo.AuthenticationHistory().Create(ctx, &h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revoked, err := revokeSessions(ctx, repo, model.UserId_Auth, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 2 {
+		t.Errorf("revoked = %d, want 2", revoked)
+	}
+	for secret, want := range map[string]bool{"open": true, "expired": false, "closed": false} {
+		if denied, err := repo.RevokedTokens().IsRevoked(ctx, secret); err != nil || denied != want {
+			t.Errorf("%s: denied = %v, %v, want %v", secret, denied, err, want)
+		}
+		if h, err := repo.AuthenticationHistory().FindBySecret(ctx, secret); err != nil || h.LoggedOut.IsZero() {
+			t.Errorf("%s: session is not signed out", secret)
+		}
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := database.NewMemory()
+	model, err := newUser(ctx, repo, "user@example.com", "Xy7!kq2Lmn#p", database.UserTypeUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []int64{database.UserStatusSuspended, database.UserStatusActive} {
+		if err := setStatus(ctx, repo, model.UserId_Auth, status); err != nil {
+			t.Fatal(err)
+		}
+		if user, err := repo.Users().FindByID(ctx, model.UserId_Auth); err != nil || user.StatusId != status {
+			t.Errorf("status = %v, %v, want %d", user, err, status)
+		}
+	}
+	if err := setStatus(ctx, repo, model.UserId_Auth+1, database.UserStatusSuspended); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+}
+
+// the commands check their flags before connecting to the database
+func TestCommandFlags(t *testing.T) {
+	for name, args := range map[string][]string{
+		"create-user":    {"-email", "user@example.com"},
+		"reset-password": {"-email", "user@example.com"},
+		"reset-mfa":      {},
+		"lock":           {},
+		"unlock":         {},
+		"sessions":       {},
+		"export-users":   {"-fields", "email,password"},
+		"import-users":   {},
+		"create-client":  {},
+		"rotate-keys":    {},
+		"migrate":        {},
+	} {
+		if err := commands[name].run(args); err == nil {
+			t.Errorf("%s %v: no error", name, args)
+		}
+	}
+}
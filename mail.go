@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+
+	"github.com/nori-io/nori-common/interfaces"
+
+	"github.com/nori-io/auth/service/templates"
+)
+
+// htmlMail is implemented by the mail plugins which can send the text and HTML bodies as alternatives
+type htmlMail interface {
+	SendHTML(from string, to []string, subject, text, html string) error
+}
+
+// mailer sends service emails through the mail plugin, the HTML body is dropped
+// when the plugin sends plain text only
+type mailer struct {
+	mail interfaces.Mail
+	from func() string
+}
+
+func (m mailer) Send(_ context.Context, to string, message templates.Message) error {
+	if h, ok := m.mail.(htmlMail); ok && message.HTML != "" {
+		return h.SendHTML(m.from(), []string{to}, message.Subject, message.Text, message.HTML)
+	}
+	return m.mail.Send(m.from(), []string{to}, message.Subject, message.Text)
+}
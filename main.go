@@ -2,26 +2,46 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"log"
+	"net"
 
 	cfg "github.com/nori-io/nori-common/config"
 	"github.com/nori-io/nori-common/meta"
 	noriPlugin "github.com/nori-io/nori-common/plugin"
+	"google.golang.org/grpc"
 
 	"github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/breach"
 	"github.com/nori-io/auth/service/database"
 	"github.com/nori-io/auth/service/database/sqlScripts"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/issuer"
+	"github.com/nori-io/auth/service/keyring"
+	"github.com/nori-io/auth/service/oauth"
+	"github.com/nori-io/auth/service/password"
+	"github.com/nori-io/auth/service/rpc"
+	"github.com/nori-io/auth/service/templates"
 )
 
 type plugin struct {
 	instance service.Service
 	config   *service.Config
+	mailFrom func() string
+	breach   *breach.Checker
+	purge    *service.PurgeJob
+	keyring  func() string
+	native   func() string
+	issuer   *issuer.Issuer
+	tokens   issuer.Config
+	denylist func() string
+	oauth    *oauth.Config
+	grpcAddr func() string
+	grpc     *grpc.Server
+	messages *i18n.Catalog
 }
 
 var (
 	Plugin plugin
-	ctx= context.Background()
+	ctx    = context.Background()
 )
 
 func (p *plugin) Init(_ context.Context, configManager cfg.Manager) error {
@@ -31,10 +51,88 @@ func (p *plugin) Init(_ context.Context, configManager cfg.Manager) error {
 	p.config = &service.Config{
 		Sub: cm.String("jwt.sub", "jwt.sub value"),
 		Iss: cm.String("jwt.iss", "jwt.iss value"),
+
+		DeletionGracePeriod: cm.String("deletion.grace_period", "time before the deleted account is purged, e.g. 720h"),
+		PurgeInterval:       cm.String("deletion.purge_interval", "how often deleted accounts are purged, e.g. 1h"),
+
+		ResetURL: cm.String("password.reset_url", "password reset page, the token is appended to it"),
+		ResetTTL: cm.String("password.reset_ttl", "password reset link lifetime, e.g. 1h"),
+
+		PasswordHistory:    cm.Int("password.history", "number of recent passwords which can't be reused, 0 disables the check"),
+		PasswordMaxAge:     cm.String("password.max_age", "password lifetime, e.g. 2160h, empty disables expiry"),
+		PlaintextPasswords: cm.Bool("password.plaintext_migration", "accept the plain text passwords of the accounts created before hashing and hash them on sign in, disable once they signed in"),
+		TokenTTL:           cm.String("jwt.ttl", "access token lifetime, e.g. 1h"),
+		RefreshMaxAge:      cm.String("jwt.refresh_max_age", "how long after the sign in the token can be refreshed, e.g. 720h"),
+		Password: password.Config{
+			MinLength:          cm.Int("password.min_length", "minimal password length"),
+			MaxLength:          cm.Int("password.max_length", "maximal password length, passwords over 72 bytes are rejected anyway"),
+			RequireUpper:       cm.Bool("password.require_upper", "password must contain an uppercase letter"),
+			RequireLower:       cm.Bool("password.require_lower", "password must contain a lowercase letter"),
+			RequireDigit:       cm.Bool("password.require_digit", "password must contain a digit"),
+			RequireSymbol:      cm.Bool("password.require_symbol", "password must contain a symbol"),
+			Banned:             cm.String("password.banned", "comma separated list of banned passwords"),
+			BannedFile:         cm.String("password.banned_file", "file with a banned password per line"),
+			MaxEmailSimilarity: cm.Int("password.max_email_similarity", "allowed similarity of password to email in percents, 0 disables the check"),
+			MinScore:           cm.Int("password.min_score", "minimal password strength score from 0 to 4"),
+		},
+	}
+	p.mailFrom = cm.String("mail.from", "sender address of the emails")
+	p.messages = i18n.New(i18n.Config{
+		Dir:           cm.String("i18n.dir", "directory with <locale>.json files overriding the error messages"),
+		DefaultLocale: cm.String("i18n.default_locale", "language of the error messages missing in the user's languages, en by default"),
+	})
+	p.config.Templates = templates.Config{
+		Dir:           cm.String("mail.templates_dir", "directory with a directory per locale overriding the email templates"),
+		DefaultLocale: cm.String("mail.default_locale", "language of the emails when the user's languages have no templates, en by default"),
+	}
+	p.config.SignUp = service.SignUpConfig{
+		Fields:            cm.String("signup.fields", "comma separated optional sign up fields: name, username, locale, timezone, attributes"),
+		Required:          cm.String("signup.required", "comma separated sign up fields which must be filled"),
+		ReservedUsernames: cm.String("signup.reserved_usernames", "comma separated usernames nobody can take in addition to the builtin ones"),
+		Attributes:        cm.String("signup.attributes", "comma separated keys of the custom attributes accepted on sign up"),
+		TermsVersion:      cm.String("signup.terms_version", "current terms of service version the user must accept on sign up, empty disables the check"),
 	}
+	p.breach = breach.NewChecker(breach.Config{
+		Source:          cm.String("breach.source", "Pwned Passwords SHA-1 file or range directory"),
+		Index:           cm.String("breach.index", "breached passwords index path, empty disables the check"),
+		Threshold:       cm.Int("breach.threshold", "breach count a password is rejected with"),
+		RecheckOnSignIn: cm.Bool("breach.recheck_on_signin", "check the password on every sign in"),
+	})
+	p.native = cm.String("jwt.issuer", "token issuer: registry (default) or native")
+	p.tokens = issuer.Config{
+		Algorithms: cm.String("jwt.algorithms", "native issuer signing algorithms: RS256, ES256, EdDSA, comma separated, the first one signs access tokens"),
+		Iss:        p.config.Iss,
+		TTL:        p.config.TokenTTL,
+		Rotation:   cm.String("jwt.rotation", "native issuer signing key lifetime, e.g. 720h"),
+	}
+	p.denylist = cm.String("revocation.store", "revoked tokens store: sql (default) or memory, memory one is not shared between instances")
+	p.oauth = &oauth.Config{
+		Issuer:     p.config.Iss,
+		ConsentURL: cm.String("oauth.consent_url", "consent page of the CMS frontend, the authorization request query is appended to it"),
+		LogoutURL:  cm.String("oauth.logout_url", "logout page of the CMS frontend, it signs the user out and follows redirect_to"),
+		DeviceURL:  cm.String("oauth.device_url", "device flow verification page of the CMS frontend where the user enters the code"),
+		Scopes:     cm.StringMap("oauth.scopes", "supported scopes mapped to descriptions shown on the consent page"),
+		RefreshTTL: cm.String("oauth.refresh_ttl", "refresh token lifetime, e.g. 720h"),
+		TokenTTL:   p.config.TokenTTL,
+	}
+	p.grpcAddr = cm.String("grpc.address", "listen address of the gRPC transport, e.g. :9090, empty disables it")
+	p.keyring = cm.String("crypto.keyring", "master keys file encrypting phones and mfa secrets, empty stores them unencrypted")
 	return nil
 }
 
+// cipher loads the keyring, nil is returned when it isn't configured
+func (p *plugin) cipher() (database.Cipher, error) {
+	path := p.keyring()
+	if path == "" {
+		return nil, nil
+	}
+	k, err := keyring.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
 func (p *plugin) Start(_ context.Context, registry noriPlugin.Registry) error {
 
 	if p.instance == nil {
@@ -64,85 +162,112 @@ func (p *plugin) Start(_ context.Context, registry noriPlugin.Registry) error {
 			return err
 		}
 
-		p.instance = service.NewService(
-			auth,
-			session,
-			p.config,
-			registry.Logger(p.Meta()),
-			database.DB(db.GetDB()),
-		)
-		service.Transport(auth, transport, session,
-			http, p.instance, registry.Logger(p.Meta()))
-
-		sql1, err := registry.Sql()
+		mail, err := registry.Mail()
 		if err != nil {
 			return err
 		}
-		db1 := sql1.GetDB()
-
-		tx, err := db1.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
 
+		cipher, err := p.cipher()
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		_, execErr := tx.Exec(
-			sqlScripts.CreateTableUsers)
-		if execErr != nil {
-			_ = tx.Rollback()
-			log.Fatal(execErr)
 
+		// installs of the older releases get the columns and tables the queries expect
+		applied, err := database.Migrate(ctx, db.GetDB(), cipher)
+		if err != nil {
+			return err
 		}
-		_, execErr = tx.Exec(
-			sqlScripts.CreateTableAuth)
-		if execErr != nil {
-			_ = tx.Rollback()
-			log.Fatal(execErr)
-		}
-		_, execErr = tx.Exec(
-			sqlScripts.CreateTableAuthProviders)
-		if execErr != nil {
-			_ = tx.Rollback()
-			log.Fatal(execErr)
+		for _, change := range applied {
+			registry.Logger(p.Meta()).Infof("migrate: %s", change)
 		}
 
-		_, execErr = tx.Exec(
-			sqlScripts.CreateTableAuthenticationHistory)
-		if execErr != nil {
-			_ = tx.Rollback()
-			log.Fatal(execErr)
+		// the native issuer replaces the registry Auth and takes session ids from its tokens
+		if p.native() == "native" {
+			p.issuer = issuer.New(database.DB(db.GetDB(), cipher), p.tokens, registry.Logger(p.Meta()))
+			auth = p.issuer
+			transport = p.issuer
+			session = p.issuer.Session(session)
 		}
 
-		_, execErr = tx.Exec(
-			sqlScripts.CreateTableUserMfaPhone)
-		if execErr != nil {
-			_ = tx.Rollback()
-			log.Fatal(execErr)
+		revoked := database.DB(db.GetDB(), cipher).RevokedTokens()
+		if p.denylist() == "memory" {
+			revoked = database.NewMemory().RevokedTokens()
 		}
 
-		_, execErr = tx.Exec(
-			sqlScripts.CreateTableUsersMfaCode)
-		if execErr != nil {
-			_ = tx.Rollback()
-			log.Fatal(execErr)
-		}
-		_, execErr = tx.Exec(
-			sqlScripts.CreateTableUserMfaSecret)
-		if execErr != nil {
-			_ = tx.Rollback()
-			log.Fatal(execErr)
+		p.instance = service.NewService(
+			auth,
+			session,
+			p.config,
+			registry.Logger(p.Meta()),
+			database.DB(db.GetDB(), cipher),
+			mailer{mail: mail, from: p.mailFrom},
+			p.breach,
+			revoked,
+		)
+		service.Transport(auth, transport, session, revoked,
+			http, p.instance, p.messages, registry.Logger(p.Meta()))
+
+		if p.issuer != nil {
+			if err := p.issuer.Start(ctx); err != nil {
+				return err
+			}
+			http.Handle("/.well-known/jwks.json", p.issuer.JWKSHandler()).Methods("GET")
 		}
 
-		if err := tx.Commit(); err != nil {
-			log.Fatal(err)
+		oauth.Transport(auth, transport, session, revoked, http, oauth.NewService(
+			database.DB(db.GetDB(), cipher),
+			p.oauth,
+			p.issuer,
+			auth,
+			transport,
+			session,
+			revoked,
+			registry.Logger(p.Meta()),
+		), registry.Logger(p.Meta()))
+
+		logger := registry.Logger(p.Meta())
+		if addr := p.grpcAddr(); addr != "" {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			p.grpc = grpc.NewServer()
+			rpc.Transport(auth, transport, session, revoked, p.grpc, p.instance, p.messages, logger)
+			go func(server *grpc.Server) {
+				if err := server.Serve(listener); err != nil {
+					logger.Error(err)
+				}
+			}(p.grpc)
 		}
 
-		service.Transport(auth, transport, session,
-			http, p.instance, registry.Logger(p.Meta()))
+		go func() {
+			if err := p.breach.Prepare(); err != nil {
+				logger.Error(err)
+			}
+		}()
+
+		p.purge = service.NewPurgeJob(database.DB(db.GetDB(), cipher), p.config, registry.Logger(p.Meta()))
+		p.purge.Start()
 	}
 	return nil
 }
 
 func (p *plugin) Stop(_ context.Context, _ noriPlugin.Registry) error {
+	if p.grpc != nil {
+		p.grpc.GracefulStop()
+		p.grpc = nil
+	}
+	if p.purge != nil {
+		p.purge.Stop()
+		p.purge = nil
+	}
+	if p.breach != nil {
+		p.breach.Close()
+	}
+	if p.issuer != nil {
+		p.issuer.Stop()
+		p.issuer = nil
+	}
 	p.instance = nil
 	return nil
 }
@@ -0,0 +1,102 @@
+package breach
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrNotReady is returned until the index is opened
+var ErrNotReady = errors.New("breach: index is not ready")
+
+type Config struct {
+	// Source is the Pwned Passwords file or directory the index is built from
+	Source func() string
+	// Index is the path of the built index
+	Index func() string
+	// Threshold is the minimal breach count a password is rejected with
+	Threshold func() int
+	// RecheckOnSignIn enables checking the password on every sign in
+	RecheckOnSignIn func() bool
+}
+
+// Checker is safe for concurrent use, it is disabled when the index path is empty
+type Checker struct {
+	cfg Config
+
+	mu  sync.RWMutex
+	idx *Index
+}
+
+func NewChecker(cfg Config) *Checker {
+	return &Checker{cfg: cfg}
+}
+
+func (c *Checker) Enabled() bool {
+	return c.cfg.Index != nil && c.cfg.Index() != ""
+}
+
+func (c *Checker) RecheckOnSignIn() bool {
+	return c.Enabled() && c.cfg.RecheckOnSignIn != nil && c.cfg.RecheckOnSignIn()
+}
+
+// Prepare opens the index, it is built from the source first when missing.
+// Building the full corpus takes a while, so it is meant to run in background.
+func (c *Checker) Prepare() error {
+	if !c.Enabled() {
+		return nil
+	}
+	path := c.cfg.Index()
+
+	if _, err := os.Stat(path); os.IsNotExist(err) && c.cfg.Source != nil && c.cfg.Source() != "" {
+		if err := Build(c.cfg.Source(), path); err != nil {
+			return err
+		}
+	}
+
+	idx, err := Open(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	old := c.idx
+	c.idx = idx
+	c.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Breached reports whether password was seen in breaches at least threshold times
+func (c *Checker) Breached(password string) (bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.idx == nil {
+		return false, ErrNotReady
+	}
+	count, err := c.idx.Count(password)
+	if err != nil {
+		return false, err
+	}
+	threshold := 1
+	if c.cfg.Threshold != nil && c.cfg.Threshold() > 0 {
+		threshold = c.cfg.Threshold()
+	}
+	return count >= uint32(threshold), nil
+}
+
+func (c *Checker) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.idx == nil {
+		return nil
+	}
+	err := c.idx.Close()
+	c.idx = nil
+	return err
+}
@@ -0,0 +1,64 @@
+package breach
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckerDisabled(t *testing.T) {
+	c := NewChecker(Config{Index: func() string { return "" }, RecheckOnSignIn: func() bool { return true }})
+	if c.Enabled() || c.RecheckOnSignIn() {
+		t.Error("checker without index is enabled")
+	}
+	if err := c.Prepare(); err != nil {
+		t.Errorf("Prepare: %v", err)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "pwned.idx")
+	threshold := 0
+	c := NewChecker(Config{
+		Source:    func() string { return "testdata/pwned.txt" },
+		Index:     func() string { return index },
+		Threshold: func() int { return threshold },
+	})
+	defer c.Close()
+
+	if _, err := c.Breached("password"); !errors.Is(err, ErrNotReady) {
+		t.Errorf("err = %v, want ErrNotReady", err)
+	}
+	// the missing index is built from the source
+	if err := c.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		password  string
+		threshold int
+		breached  bool
+	}{
+		{"password", 0, true},
+		{"letmein", 0, false},
+		{"qwerty", 3912816, true},
+		{"qwerty", 3912817, false},
+	} {
+		threshold = tc.threshold
+		breached, err := c.Breached(tc.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if breached != tc.breached {
+			t.Errorf("Breached(%q) with threshold %d = %v", tc.password, tc.threshold, breached)
+		}
+	}
+
+	// the built index is reopened
+	if err := c.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if breached, err := c.Breached("password"); err != nil || !breached {
+		t.Errorf("Breached = %v, %v after reopen", breached, err)
+	}
+}
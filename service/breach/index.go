@@ -0,0 +1,254 @@
+// Package breach checks passwords against a local copy of the Pwned Passwords corpus
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Index file layout:
+//
+//	magic    [8]byte
+//	count    uint64, number of records
+//	buckets  [65537]uint64, first record of every 2 byte hash prefix
+//	records  count * (sha1 [20]byte, count uint32), sorted by hash
+const (
+	magic       = "HIBPIDX1"
+	bucketCount = 1 << 16
+	hashSize    = sha1.Size
+	recordSize  = hashSize + 4
+	headerSize  = len(magic) + 8 + (bucketCount+1)*8
+)
+
+var ErrUnsorted = errors.New("breach: source is not sorted by hash")
+
+// Index is opened on-disk index, lookups read only the records of a single bucket
+type Index struct {
+	f       *os.File
+	count   uint64
+	buckets []uint64
+}
+
+func Open(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("breach: read index header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		f.Close()
+		return nil, fmt.Errorf("breach: %s is not an index file", path)
+	}
+
+	idx := &Index{
+		f:       f,
+		count:   binary.BigEndian.Uint64(header[len(magic):]),
+		buckets: make([]uint64, bucketCount+1),
+	}
+	table := header[len(magic)+8:]
+	for i := range idx.buckets {
+		idx.buckets[i] = binary.BigEndian.Uint64(table[i*8:])
+	}
+	return idx, nil
+}
+
+func (idx *Index) Close() error {
+	return idx.f.Close()
+}
+
+// Count returns how many times password was seen in breaches, 0 when it is unknown
+func (idx *Index) Count(password string) (uint32, error) {
+	sum := sha1.Sum([]byte(password))
+	return idx.CountHash(sum)
+}
+
+func (idx *Index) CountHash(hash [hashSize]byte) (uint32, error) {
+	// int keeps the end of the last bucket from wrapping to the first one
+	bucket := int(binary.BigEndian.Uint16(hash[:2]))
+	lo, hi := idx.buckets[bucket], idx.buckets[bucket+1]
+	if lo >= hi {
+		return 0, nil
+	}
+
+	records := make([]byte, (hi-lo)*recordSize)
+	if _, err := idx.f.ReadAt(records, int64(headerSize)+int64(lo)*recordSize); err != nil {
+		return 0, fmt.Errorf("breach: read bucket: %w", err)
+	}
+
+	n := int(hi - lo)
+	i := sort.Search(n, func(i int) bool {
+		return bytes.Compare(records[i*recordSize:i*recordSize+hashSize], hash[:]) >= 0
+	})
+	if i < n && bytes.Equal(records[i*recordSize:i*recordSize+hashSize], hash[:]) {
+		return binary.BigEndian.Uint32(records[i*recordSize+hashSize:]), nil
+	}
+	return 0, nil
+}
+
+// Build converts the Pwned Passwords SHA-1 corpus into the index at dst.
+// src is either a single file of "HASH:COUNT" lines or a directory of range
+// buckets named by the 5 hex digits prefix with "SUFFIX:COUNT" lines.
+// Both must be sorted by hash as they are published.
+func Build(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	b := &builder{w: bufio.NewWriterSize(out, 1<<20)}
+	if _, err := b.w.Write(make([]byte, headerSize)); err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		err = b.addDir(src)
+	} else {
+		err = b.addFile(src, "")
+	}
+	if err != nil {
+		return err
+	}
+	if err := b.w.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := out.WriteAt(b.header(), 0); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+type builder struct {
+	w      *bufio.Writer
+	count  uint64
+	last   [hashSize]byte
+	starts [bucketCount + 1]uint64
+	seen   [bucketCount]bool
+}
+
+func (b *builder) addDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prefix := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+		if len(prefix) != 5 {
+			continue
+		}
+		if err := b.addFile(filepath.Join(dir, name), prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) addFile(path, prefix string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("breach: %s: malformed line %q", path, line)
+		}
+		raw, err := hex.DecodeString(prefix + parts[0])
+		if err != nil || len(raw) != hashSize {
+			return fmt.Errorf("breach: %s: malformed hash %q", path, parts[0])
+		}
+		count, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("breach: %s: malformed count %q", path, parts[1])
+		}
+
+		var hash [hashSize]byte
+		copy(hash[:], raw)
+		if err := b.add(hash, uint32(count)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (b *builder) add(hash [hashSize]byte, count uint32) error {
+	if b.count > 0 && bytes.Compare(hash[:], b.last[:]) <= 0 {
+		return ErrUnsorted
+	}
+	bucket := binary.BigEndian.Uint16(hash[:2])
+	if !b.seen[bucket] {
+		b.seen[bucket] = true
+		b.starts[bucket] = b.count
+	}
+
+	var record [recordSize]byte
+	copy(record[:], hash[:])
+	binary.BigEndian.PutUint32(record[hashSize:], count)
+	if _, err := b.w.Write(record[:]); err != nil {
+		return err
+	}
+	b.last = hash
+	b.count++
+	return nil
+}
+
+// header fills start of empty buckets with the start of the next one
+func (b *builder) header() []byte {
+	b.starts[bucketCount] = b.count
+	for i := bucketCount - 1; i >= 0; i-- {
+		if !b.seen[i] {
+			b.starts[i] = b.starts[i+1]
+		}
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint64(header[len(magic):], b.count)
+	table := header[len(magic)+8:]
+	for i, start := range b.starts {
+		binary.BigEndian.PutUint64(table[i*8:], start)
+	}
+	return header
+}
@@ -0,0 +1,120 @@
+package breach
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// build builds the index of src in a temporary directory and opens it
+func build(t *testing.T, src string) *Index {
+	t.Helper()
+	dst := filepath.Join(t.TempDir(), "pwned.idx")
+	if err := Build(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { idx.Close() })
+	return idx
+}
+
+func hash(t *testing.T, s string) [hashSize]byte {
+	t.Helper()
+	var h [hashSize]byte
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != hashSize {
+		t.Fatalf("invalid hash %q", s)
+	}
+	copy(h[:], raw)
+	return h
+}
+
+func TestIndexFile(t *testing.T) {
+	idx := build(t, "testdata/pwned.txt")
+	if idx.count != 8 {
+		t.Errorf("count = %d, want 8", idx.count)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		hash  string
+		count uint32
+	}{
+		{"first bucket", "0000000000000000000000000000000000000000", 1},
+		{"start of bucket", "5BAA600000000000000000000000000000000000", 7},
+		{"middle of bucket", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8", 9545824},
+		{"end of bucket", "5BAAFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF", 3},
+		{"next bucket", "5BAB000000000000000000000000000000000000", 4},
+		{"last bucket", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF", 2},
+		{"absent in a bucket", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD9", 0},
+		{"absent after a bucket", "5BAB000000000000000000000000000000000001", 0},
+		{"empty bucket", "5BA9FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF", 0},
+		{"empty last bucket", "FFFE000000000000000000000000000000000000", 0},
+	} {
+		count, err := idx.CountHash(hash(t, tc.hash))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if count != tc.count {
+			t.Errorf("%s: count = %d, want %d", tc.name, count, tc.count)
+		}
+	}
+
+	for password, want := range map[string]uint32{"password": 9545824, "123456": 37359195, "qwerty": 3912816, "letmein": 0} {
+		if count, err := idx.Count(password); err != nil || count != want {
+			t.Errorf("Count(%q) = %d, %v, want %d", password, count, err, want)
+		}
+	}
+}
+
+func TestIndexRangeDirectory(t *testing.T) {
+	idx := build(t, "testdata/range")
+	if idx.count != 3 {
+		t.Errorf("count = %d, want 3", idx.count)
+	}
+	for password, want := range map[string]uint32{"password": 9545824, "123456": 37359195, "qwerty": 0} {
+		if count, err := idx.Count(password); err != nil || count != want {
+			t.Errorf("Count(%q) = %d, %v, want %d", password, count, err, want)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unsorted":  "7C4A8D09CA3762AF61E59520943DC26494F8941B:1\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n",
+		"duplicate": "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n",
+		"no count":  "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n",
+		"short":     "5BAA61E4C9B93F3F:1\n",
+		"count":     "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:many\n",
+	} {
+		src, dst := filepath.Join(dir, name+".txt"), filepath.Join(dir, name+".idx")
+		if err := os.WriteFile(src, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := Build(src, dst)
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if sorted := name != "unsorted" && name != "duplicate"; sorted == errors.Is(err, ErrUnsorted) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("%s: index of the failed build is kept", name)
+		}
+	}
+}
+
+func TestOpenNotIndex(t *testing.T) {
+	if _, err := Open("testdata/pwned.txt"); err == nil {
+		t.Error("source file is opened as an index")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.idx")); !os.IsNotExist(err) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+}
@@ -4,83 +4,151 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
-	"strconv"
+	"fmt"
 )
 
-var(
-	ctx= context.Background()
-
+const (
+	authColumns = "a.id, a.user_id, a.phone, a.email, a.password, a.salt, a.created, a.updated, a.is_email_verified, a.is_phone_verified, a.password_changed, " +
+		"u.id, u.kind, u.status_id, u.type, u.created, u.updated, u.mfa_type, u.deletion_scheduled"
+	authFrom = "auth a JOIN users u ON u.id = a.user_id"
 )
 
 type auth struct {
-	db *sql.DB
+	db    executor
+	crypt crypt
 }
 
-func (a *auth) Create(model *AuthModel) error {
+func (a *auth) Create(ctx context.Context, model *AuthModel) error {
+	return inTx(ctx, a.db, func(tx executor) error {
+		res, err := tx.ExecContext(ctx, "INSERT INTO users (kind, status_id, type, created, updated, mfa_type) VALUES(?,?,?,?,?,?)",
+			model.Kind_Users, model.StatusId_Users, model.Type_Users, nullTime(model.Created_Users), nullTime(model.Updated_Users), model.Mfa_type_Users)
+		if err != nil {
+			return fmt.Errorf("insert users: %w", err)
+		}
+		userId, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("insert users: %w", err)
+		}
+
+		phone, err := a.crypt.encrypt(model.Phone_Auth)
+		if err != nil {
+			return fmt.Errorf("insert auth: %w", err)
+		}
+		res, err = tx.ExecContext(ctx, "INSERT INTO auth (user_id, phone, phone_hash, email, password, salt, created, updated, is_email_verified, is_phone_verified, password_changed) VALUES(?,?,?,?,?,?,?,?,?,?,?)",
+			userId, phone, a.crypt.index(model.Phone_Auth), nullString(model.Email_Auth), model.Password_Auth, model.Salt_Auth, nullTime(model.Created_Auth), nullTime(model.Updated_Auth), model.IsEmailVerified_Auth, model.IsPhoneVerified_Auth, nullTime(model.PasswordChanged_Auth))
+		if err != nil {
+			return fmt.Errorf("insert auth: %w", uniqueError(err))
+		}
+		authId, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("insert auth: %w", err)
+		}
+
+		model.Id_Users = uint64(userId)
+		model.UserId_Auth = uint64(userId)
+		model.Id_Auth = uint64(authId)
+		return nil
+	})
+}
 
-	tx, err := a.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+func (a *auth) Update(ctx context.Context, model *AuthModel) error {
+	if model.Id_Auth == 0 {
+		return ErrEmptyModel
+	}
+	phone, err := a.crypt.encrypt(model.Phone_Auth)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("update auth: %w", err)
 	}
-
-	_, execErr:= tx.Exec ("INSERT INTO users (kind, status_id, type, created, updated, mfa_type) VALUES(?,?,?,?,?,?)",
-		model.Kind_Users, model.StatusId_Users, model.Type_Users, model.Created_Users, model.Updated_Users, model.Mfa_type_Users)
-	if execErr != nil {
-		_ = tx.Rollback()
-		log.Fatalf("Insert table 'users' error",execErr)
+	_, err = a.db.ExecContext(ctx, "UPDATE auth SET phone = ?, phone_hash = ?, email = ?, password = ?, salt = ?, updated = ?, is_email_verified = ?, is_phone_verified = ?, password_changed = ? WHERE id = ?",
+		phone, a.crypt.index(model.Phone_Auth), nullString(model.Email_Auth), model.Password_Auth, model.Salt_Auth, nullTime(model.Updated_Auth), model.IsEmailVerified_Auth, model.IsPhoneVerified_Auth, nullTime(model.PasswordChanged_Auth), model.Id_Auth)
+	if err != nil {
+		return fmt.Errorf("update auth: %w", uniqueError(err))
 	}
+	return nil
+}
 
-    lastid,err:=a.db.Exec("select * from users where id = (select max(id) from users")
+func (a *auth) Delete(ctx context.Context, id uint64) error {
+	_, err := a.db.ExecContext(ctx, "DELETE FROM auth WHERE id = ?", id)
 	if err != nil {
-		log.Fatalf("Select table 'users' error ",err)
+		return fmt.Errorf("delete auth: %w", err)
 	}
-	number,err:=lastid.LastInsertId()
+	return nil
+}
+
+func (a *auth) FindByID(ctx context.Context, id uint64) (model *AuthModel, err error) {
+	model, err = a.find(ctx, "a.id = ?", id)
 	if err != nil {
-		log.Fatalf("LastInsertId() taking error",err)
-	}
-	_, execErr = tx.Exec("INSERT INTO auth (user_id, phone, email, password, salt, created, updated, is_email_verified, is_phone_verified) VALUES("+(strconv.FormatInt(number,10))+",?,?,?,?,?,?,?,?)",
-		model.UserId_Auth, model.Phone_Auth, model.Email_Auth, model.Password_Auth, model.Salt_Auth, model.Created_Auth, model.Updated_Auth, model.IsEmailVerified_Auth, model.IsPhoneVerified_Auth)
-	if execErr != nil {
-		_ = tx.Rollback()
-		log.Fatalf("Insert table 'auth' error",execErr)
+		return nil, fmt.Errorf("find auth by id: %w", err)
 	}
+	return model, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		log.Fatalf("Commit transaction error",err)
+func (a *auth) FindByUserID(ctx context.Context, userId uint64) (model *AuthModel, err error) {
+	model, err = a.find(ctx, "a.user_id = ?", userId)
+	if err != nil {
+		return nil, fmt.Errorf("find auth by user id: %w", err)
 	}
-
-	return nil
-
+	return model, nil
 }
 
-func (a *auth) Update(model *AuthModel) error {
-	if model.Id_Auth == 0 {
-		return errors.New("Empty model")
+func (a *auth) FindByEmail(ctx context.Context, email string) (model *AuthModel, err error) {
+	model, err = a.find(ctx, "a.email = ?", email)
+	if err != nil {
+		return nil, fmt.Errorf("find auth by email: %w", err)
 	}
-	_, err := a.db.Exec("UPDATE auth SET profile_user_id = ?, phone = ?, email = ?, password = ? salt = ? created =? WHERE id = ? ",
-		model.UserId_Auth, model.Id_Auth)
-	return err
+	return model, nil
 }
 
-func (a *auth) FindByEmail(email string) (model *AuthModel, err error) {
-	rows, err := a.db.Query("SELECT user_id, phone, email, password, salt, created, updated, is_email_verified, is_phone_verified FROM auth WHERE email = ? LIMIT 1", email)
+func (a *auth) FindByPhone(ctx context.Context, phone string) (model *AuthModel, err error) {
+	model, err = a.find(ctx, "a.phone_hash = ?", a.crypt.index(phone))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("find auth by phone: %w", err)
 	}
-	model = &AuthModel{}
+	return model, nil
+}
 
-	defer rows.Close()
-	for rows.Next() {
-		var m AuthModel
-		rows.Scan(&m.Id_Auth, &m.Email_Auth)
-		model.Id_Auth = m.Id_Auth
-		model.Email_Auth = m.Email_Auth
+func (a *auth) FindByProvider(ctx context.Context, provider, providerUserKey string) (model *AuthModel, err error) {
+	model, err = a.find(ctx, "a.user_id = (SELECT p.user_id FROM auth_providers p WHERE p.provider = ? AND p.provider_user_key = ?)",
+		provider, providerUserKey)
+	if err != nil {
+		return nil, fmt.Errorf("find auth by provider: %w", err)
 	}
+	return model, nil
+}
 
-	if rows.Err() != nil {
-		return nil, rows.Err()
+// find returns the single auth row of not deleted user matching where
+func (a *auth) find(ctx context.Context, where string, args ...interface{}) (*AuthModel, error) {
+	row := a.db.QueryRowContext(ctx, "SELECT "+authColumns+" FROM "+authFrom+" WHERE u.deleted IS NULL AND "+where+" LIMIT 1", args...)
+	model, err := a.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
 	}
+	return model, err
+}
 
-	return model, nil
+func (a *auth) scan(row scanner) (*AuthModel, error) {
+	var (
+		m                          AuthModel
+		phone, email               sql.NullString
+		created, updated           sql.NullTime
+		passwordChanged            sql.NullTime
+		usersCreated, usersUpdated sql.NullTime
+		deletionScheduled          sql.NullTime
+	)
+	err := row.Scan(&m.Id_Auth, &m.UserId_Auth, &phone, &email, &m.Password_Auth, &m.Salt_Auth, &created, &updated, &m.IsEmailVerified_Auth, &m.IsPhoneVerified_Auth, &passwordChanged,
+		&m.Id_Users, &m.Kind_Users, &m.StatusId_Users, &m.Type_Users, &usersCreated, &usersUpdated, &m.Mfa_type_Users, &deletionScheduled)
+	if err != nil {
+		return nil, err
+	}
+	if m.Phone_Auth, err = a.crypt.decrypt(phone); err != nil {
+		return nil, err
+	}
+	m.Email_Auth = email.String
+	m.Created_Auth = created.Time
+	m.Updated_Auth = updated.Time
+	m.PasswordChanged_Auth = passwordChanged.Time
+	m.Created_Users = usersCreated.Time
+	m.Updated_Users = usersUpdated.Time
+	m.DeletionScheduled_Users = timePtr(deletionScheduled)
+	return &m, nil
 }
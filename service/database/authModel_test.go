@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newAuthModel(email string) *AuthModel {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &AuthModel{
+		Email_Auth:           email,
+		Phone_Auth:           "+10000000000",
+		Password_Auth:        "hash",
+		Salt_Auth:            "salt",
+		Created_Auth:         now,
+		Updated_Auth:         now,
+		IsEmailVerified_Auth: true,
+		PasswordChanged_Auth: now,
+		Kind_Users:           "person",
+		StatusId_Users:       UserStatusActive,
+		Type_Users:           UserTypeUser,
+		Created_Users:        now,
+		Updated_Users:        now,
+	}
+}
+
+// FindByEmail used to scan two of the selected columns, so it returned an empty model
+func TestAuthFindByEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	created := newAuthModel("user@example.com")
+	if err := db.Auth().Create(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Id_Auth == 0 || created.UserId_Auth == 0 {
+		t.Fatalf("ids are not set: %+v", created)
+	}
+
+	found, err := db.Auth().FindByEmail(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Id_Auth != created.Id_Auth || found.UserId_Auth != created.UserId_Auth || found.Id_Users != created.UserId_Auth {
+		t.Errorf("ids = %d, %d, %d, want %d, %d, %d", found.Id_Auth, found.UserId_Auth, found.Id_Users, created.Id_Auth, created.UserId_Auth, created.UserId_Auth)
+	}
+	if found.Email_Auth != created.Email_Auth || found.Phone_Auth != created.Phone_Auth {
+		t.Errorf("contacts = %q, %q", found.Email_Auth, found.Phone_Auth)
+	}
+	if found.Password_Auth != "hash" || found.Salt_Auth != "salt" {
+		t.Errorf("password = %q, salt = %q", found.Password_Auth, found.Salt_Auth)
+	}
+	if !found.IsEmailVerified_Auth || found.IsPhoneVerified_Auth {
+		t.Errorf("verified = %v, %v", found.IsEmailVerified_Auth, found.IsPhoneVerified_Auth)
+	}
+	if !found.Created_Auth.Equal(created.Created_Auth) || !found.PasswordChanged_Auth.Equal(created.PasswordChanged_Auth) {
+		t.Errorf("times = %v, %v, want %v", found.Created_Auth, found.PasswordChanged_Auth, created.Created_Auth)
+	}
+	if found.Kind_Users != "person" || found.Type_Users != UserTypeUser {
+		t.Errorf("user = %q, %q", found.Kind_Users, found.Type_Users)
+	}
+}
+
+func TestAuthFindByEmailNotFound(t *testing.T) {
+	_, err := newSQLite(t).Auth().FindByEmail(context.Background(), "nobody@example.com")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAuthFindByEmailSkipsDeleted(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	model := newAuthModel("deleted@example.com")
+	if err := db.Auth().Create(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Users().SoftDelete(ctx, model.UserId_Auth); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Auth().FindByEmail(ctx, "deleted@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
+
+// the users row of the failed insert must be rolled back with it
+func TestAuthCreateDuplicateEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	if err := db.Auth().Create(ctx, newAuthModel("user@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	duplicate := newAuthModel("user@example.com")
+	duplicate.Phone_Auth = ""
+	if err := db.Auth().Create(ctx, duplicate); err == nil {
+		t.Fatal("duplicate email is accepted")
+	}
+	count, err := db.Users().Count(ctx, UsersFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("users = %d, want 1", count)
+	}
+}
+
+func TestAuthUpdate(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	model := newAuthModel("user@example.com")
+	if err := db.Auth().Create(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+	model.Email_Auth = "new@example.com"
+	model.Password_Auth = "new hash"
+	if err := db.Auth().Update(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := db.Auth().FindByUserID(ctx, model.UserId_Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Email_Auth != "new@example.com" || found.Password_Auth != "new hash" {
+		t.Errorf("auth = %q, %q", found.Email_Auth, found.Password_Auth)
+	}
+	if _, err := db.Auth().FindByEmail(ctx, "user@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old email err = %v, want ErrNotFound", err)
+	}
+}
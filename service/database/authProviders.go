@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+type authProviders struct {
+	db executor
+}
+
+func (a *authProviders) Create(ctx context.Context, model *AuthProvidersModel) error {
+	_, err := a.db.ExecContext(ctx, "INSERT INTO auth_providers (provider, provider_user_key, user_id) VALUES(?,?,?)",
+		model.Provider, model.ProviderUserKey, model.UserId)
+	if err != nil {
+		return fmt.Errorf("insert auth_providers: %w", err)
+	}
+	return nil
+}
+
+func (a *authProviders) Delete(ctx context.Context, provider, providerUserKey string) error {
+	_, err := a.db.ExecContext(ctx, "DELETE FROM auth_providers WHERE provider = ? AND provider_user_key = ?", provider, providerUserKey)
+	if err != nil {
+		return fmt.Errorf("delete auth_providers: %w", err)
+	}
+	return nil
+}
+
+func (a *authProviders) FindByUserID(ctx context.Context, userId uint64) (models []AuthProvidersModel, err error) {
+	rows, err := a.db.QueryContext(ctx, "SELECT provider, provider_user_key, user_id FROM auth_providers WHERE user_id = ?", userId)
+	if err != nil {
+		return nil, fmt.Errorf("find auth_providers by user id: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m AuthProvidersModel
+		if err := rows.Scan(&m.Provider, &m.ProviderUserKey, &m.UserId); err != nil {
+			return nil, fmt.Errorf("find auth_providers by user id: %w", err)
+		}
+		models = append(models, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find auth_providers by user id: %w", err)
+	}
+	return models, nil
+}
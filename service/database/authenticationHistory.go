@@ -1,25 +1,90 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 )
 
+const historyColumns = "id, user_id, logged_in, meta, logged_out, secret, signed_in"
+
 type authenticationHistory struct {
-	db *sql.DB
+	db executor
 }
 
-func (a *authenticationHistory) Create(model *AuthenticationHistoryModel) error {
-	_, err := a.db.Exec("INSERT INTO authentication_history (user_id, logged_in, meta, logged_out, secret) VALUES(?,?,?,?,?)",
-		model.UserId, model.LoggedIn, model.Meta, model.LoggedOut, model.Secret)
-	return err
+func (a *authenticationHistory) Create(ctx context.Context, model *AuthenticationHistoryModel) error {
+	res, err := a.db.ExecContext(ctx, "INSERT INTO authentication_history (user_id, logged_in, meta, logged_out, secret, signed_in) VALUES(?,?,?,?,?,?)",
+		model.UserId, model.LoggedIn, model.Meta, nullTime(model.LoggedOut), nullString(model.Secret), nullTime(model.SignedIn))
+	if err != nil {
+		return fmt.Errorf("insert authentication_history: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert authentication_history: %w", err)
+	}
+	model.Id = id
+	return nil
 }
 
-func (a *authenticationHistory) Update(model *AuthenticationHistoryModel) error {
+func (a *authenticationHistory) Update(ctx context.Context, model *AuthenticationHistoryModel) error {
 	if model.Id == 0 {
-		return errors.New("Empty model")
+		return ErrEmptyModel
+	}
+	_, err := a.db.ExecContext(ctx, "UPDATE authentication_history SET user_id = ?, logged_in = ?, meta = ?, logged_out = ?, secret = ?, signed_in = ? WHERE id = ?",
+		model.UserId, model.LoggedIn, model.Meta, nullTime(model.LoggedOut), nullString(model.Secret), nullTime(model.SignedIn), model.Id)
+	if err != nil {
+		return fmt.Errorf("update authentication_history: %w", err)
+	}
+	return nil
+}
+
+// FindBySecret returns the sign in record of the session secret
+func (a *authenticationHistory) FindBySecret(ctx context.Context, secret string) (model *AuthenticationHistoryModel, err error) {
+	row := a.db.QueryRowContext(ctx, "SELECT "+historyColumns+" FROM authentication_history WHERE secret = ?", secret)
+	model, err = scanHistory(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find authentication_history by secret: %w", err)
+	}
+	return model, nil
+}
+
+// FindByUserID returns sign in records of the user, newest first
+func (a *authenticationHistory) FindByUserID(ctx context.Context, userId uint64) (models []AuthenticationHistoryModel, err error) {
+	rows, err := a.db.QueryContext(ctx, "SELECT "+historyColumns+" FROM authentication_history WHERE user_id = ? ORDER BY id DESC", userId)
+	if err != nil {
+		return nil, fmt.Errorf("find authentication_history by user id: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		model, err := scanHistory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("find authentication_history by user id: %w", err)
+		}
+		models = append(models, *model)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find authentication_history by user id: %w", err)
+	}
+	return models, nil
+}
+
+func scanHistory(row scanner) (*AuthenticationHistoryModel, error) {
+	var (
+		m         AuthenticationHistoryModel
+		loggedOut sql.NullTime
+		secret    sql.NullString
+		signedIn  sql.NullTime
+	)
+	if err := row.Scan(&m.Id, &m.UserId, &m.LoggedIn, &m.Meta, &loggedOut, &secret, &signedIn); err != nil {
+		return nil, err
 	}
-	_, err := a.db.Exec("UPDATE authentication_history SET user_id = ?, logged_in = ?, meta = ?, logged_out = ? secret = ?  WHERE id = ? ",
-		model.UserId, model.LoggedIn, model.Meta, model.LoggedOut, model.Secret, model.Id)
-	return err
+	m.LoggedOut = loggedOut.Time
+	m.Secret = secret.String
+	m.SignedIn = signedIn.Time
+	return &m, nil
 }
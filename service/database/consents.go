@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type consents struct {
+	db executor
+}
+
+func (c *consents) Create(ctx context.Context, model *ConsentModel) error {
+	res, err := c.db.ExecContext(ctx, "INSERT INTO user_consents (user_id, kind, version, granted, revoked) VALUES(?,?,?,?,?)",
+		model.UserId, model.Kind, model.Version, model.Granted, nullTimePtr(model.Revoked))
+	if err != nil {
+		return fmt.Errorf("insert user_consents: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert user_consents: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (c *consents) Revoke(ctx context.Context, id uint64) error {
+	_, err := c.db.ExecContext(ctx, "UPDATE user_consents SET revoked = ? WHERE id = ? AND revoked IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("revoke user_consents: %w", err)
+	}
+	return nil
+}
+
+func (c *consents) FindByUserID(ctx context.Context, userId uint64) (models []ConsentModel, err error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT id, user_id, kind, version, granted, revoked FROM user_consents WHERE user_id = ? ORDER BY id", userId)
+	if err != nil {
+		return nil, fmt.Errorf("find user_consents by user id: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			m       ConsentModel
+			revoked sql.NullTime
+		)
+		if err := rows.Scan(&m.Id, &m.UserId, &m.Kind, &m.Version, &m.Granted, &revoked); err != nil {
+			return nil, fmt.Errorf("find user_consents by user id: %w", err)
+		}
+		m.Revoked = timePtr(revoked)
+		models = append(models, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find user_consents by user id: %w", err)
+	}
+	return models, nil
+}
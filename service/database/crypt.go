@@ -0,0 +1,61 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+)
+
+// Cipher encrypts sensitive columns, it is implemented by keyring.Keyring
+type Cipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+	// Stale reports whether ciphertext must be re-encrypted with the current key
+	Stale(ciphertext string) bool
+	BlindIndex(value string) string
+}
+
+// crypt applies Cipher to column values, without Cipher values are stored as is
+type crypt struct {
+	cipher Cipher
+}
+
+func (c crypt) encrypt(value string) (sql.NullString, error) {
+	if value == "" {
+		return sql.NullString{}, nil
+	}
+	if c.cipher == nil {
+		return nullString(value), nil
+	}
+	ciphertext, err := c.cipher.Encrypt(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return nullString(ciphertext), nil
+}
+
+func (c crypt) decrypt(value sql.NullString) (string, error) {
+	if !value.Valid || c.cipher == nil {
+		return value.String, nil
+	}
+	return c.cipher.Decrypt(value.String)
+}
+
+// index returns the blind index of value used for lookups of encrypted columns
+func (c crypt) index(value string) sql.NullString {
+	if value == "" {
+		return sql.NullString{}
+	}
+	if c.cipher == nil {
+		sum := sha256.Sum256([]byte(value))
+		return nullString(hex.EncodeToString(sum[:]))
+	}
+	return nullString(c.cipher.BlindIndex(value))
+}
+
+func (c crypt) stale(value string) bool {
+	if c.cipher == nil {
+		return false
+	}
+	return c.cipher.Stale(value)
+}
@@ -1,61 +1,265 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"sync"
+	"time"
 )
 
 type Database interface {
 	Users() Users
 	AuthenticationHistory() AuthenticationHistory
 	Auth() Auth
+	AuthProviders() AuthProviders
+	Mfa() Mfa
+	Consents() Consents
+	Profiles() Profiles
+	PasswordResets() PasswordResets
+	PasswordHistory() PasswordHistory
+	SigningKeys() SigningKeys
+	RevokedTokens() RevokedTokens
+	OAuthClients() OAuthClients
+	RefreshTokens() RefreshTokens
+	OAuthCodes() OAuthCodes
+	DeviceCodes() DeviceCodes
+	// Tx runs fn in a single transaction, repositories of tx share it
+	Tx(ctx context.Context, fn func(tx Database) error) error
 }
 
 type AuthenticationHistory interface {
-	Create(*AuthenticationHistoryModel) error
-	Update(*AuthenticationHistoryModel) error
+	Create(ctx context.Context, model *AuthenticationHistoryModel) error
+	Update(ctx context.Context, model *AuthenticationHistoryModel) error
+	FindBySecret(ctx context.Context, secret string) (model *AuthenticationHistoryModel, err error)
+	FindByUserID(ctx context.Context, userId uint64) (models []AuthenticationHistoryModel, err error)
 }
 
 type Users interface {
-	Create(*UsersModel) error
-	Update(*UsersModel) error
+	Create(ctx context.Context, model *UsersModel) error
+	Update(ctx context.Context, model *UsersModel) error
+	Delete(ctx context.Context, id uint64) error
+	SoftDelete(ctx context.Context, id uint64) error
+	ScheduleDeletion(ctx context.Context, id uint64, at time.Time) error
+	CancelDeletion(ctx context.Context, id uint64) error
+	FindByID(ctx context.Context, id uint64) (model *UsersModel, err error)
+	List(ctx context.Context, filter UsersFilter) (models []UsersModel, next uint64, err error)
+	Count(ctx context.Context, filter UsersFilter) (count uint64, err error)
 }
 
+// Auth finders skip soft deleted users and return ErrNotFound when nothing matches
 type Auth interface {
-	Create(model *AuthModel) error
-	Update(*AuthModel) error
-	FindByEmail(email string) (model *AuthModel, err error)
+	Create(ctx context.Context, model *AuthModel) error
+	Update(ctx context.Context, model *AuthModel) error
+	Delete(ctx context.Context, id uint64) error
+	FindByID(ctx context.Context, id uint64) (model *AuthModel, err error)
+	FindByUserID(ctx context.Context, userId uint64) (model *AuthModel, err error)
+	FindByEmail(ctx context.Context, email string) (model *AuthModel, err error)
+	FindByPhone(ctx context.Context, phone string) (model *AuthModel, err error)
+	FindByProvider(ctx context.Context, provider, providerUserKey string) (model *AuthModel, err error)
+}
+
+type AuthProviders interface {
+	Create(ctx context.Context, model *AuthProvidersModel) error
+	Delete(ctx context.Context, provider, providerUserKey string) error
+	FindByUserID(ctx context.Context, userId uint64) (models []AuthProvidersModel, err error)
+}
+
+type Mfa interface {
+	State(ctx context.Context, userId uint64) (model *MfaStateModel, err error)
+	SetSecret(ctx context.Context, userId uint64, secret string) error
+	Secret(ctx context.Context, userId uint64) (secret string, err error)
+	SetPhone(ctx context.Context, userId uint64, phone string) error
+	Phone(ctx context.Context, userId uint64) (phone string, err error)
+	// SetCodes replaces recovery codes of the user
+	SetCodes(ctx context.Context, userId uint64, codes []string) error
+	Codes(ctx context.Context, userId uint64) (codes []string, err error)
+	// Delete removes secret, phone and recovery codes of the user
+	Delete(ctx context.Context, userId uint64) error
+}
+
+type PasswordResets interface {
+	Create(ctx context.Context, model *PasswordResetModel) error
+	FindByTokenHash(ctx context.Context, tokenHash string) (model *PasswordResetModel, err error)
+	// Use marks the reset used, ErrNotFound is returned when it was already used
+	Use(ctx context.Context, id uint64) error
+}
+
+type PasswordHistory interface {
+	Create(ctx context.Context, model *PasswordHistoryModel) error
+	// Recent returns up to limit newest hashes of the user
+	Recent(ctx context.Context, userId uint64, limit int) (models []PasswordHistoryModel, err error)
+	// Prune removes all but keep newest hashes of the user
+	Prune(ctx context.Context, userId uint64, keep int) error
+}
+
+type SigningKeys interface {
+	Create(ctx context.Context, model *SigningKeyModel) error
+	Delete(ctx context.Context, id uint64) error
+	// List returns all keys ordered by activation time
+	List(ctx context.Context) (models []SigningKeyModel, err error)
+}
+
+// RevokedTokens is the denylist of token ids, entries are kept until the token expires
+type RevokedTokens interface {
+	Revoke(ctx context.Context, jti string, expires time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// Purge removes entries expired before the time
+	Purge(ctx context.Context, before time.Time) (purged int64, err error)
+}
+
+type OAuthClients interface {
+	Create(ctx context.Context, model *OAuthClientModel) error
+	Update(ctx context.Context, model *OAuthClientModel) error
+	Delete(ctx context.Context, id uint64) error
+	FindByClientID(ctx context.Context, clientId string) (model *OAuthClientModel, err error)
+	List(ctx context.Context) (models []OAuthClientModel, err error)
+}
+
+type RefreshTokens interface {
+	Create(ctx context.Context, model *RefreshTokenModel) error
+	FindByTokenHash(ctx context.Context, tokenHash string) (model *RefreshTokenModel, err error)
+	// Revoke marks the token revoked, ErrNotFound is returned when it was already revoked
+	Revoke(ctx context.Context, id uint64) error
+	RevokeByUser(ctx context.Context, userId uint64) error
+	RevokeByClient(ctx context.Context, userId uint64, clientId string) error
+}
+
+type OAuthCodes interface {
+	Create(ctx context.Context, model *OAuthCodeModel) error
+	FindByCodeHash(ctx context.Context, codeHash string) (model *OAuthCodeModel, err error)
+	// Use marks the code used, ErrNotFound is returned when it was already used
+	Use(ctx context.Context, id uint64) error
+}
 
+type DeviceCodes interface {
+	Create(ctx context.Context, model *DeviceCodeModel) error
+	FindByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (model *DeviceCodeModel, err error)
+	FindByUserCodeHash(ctx context.Context, userCodeHash string) (model *DeviceCodeModel, err error)
+	// Approve and Deny return ErrNotFound when the code was already decided
+	Approve(ctx context.Context, id, userId uint64) error
+	Deny(ctx context.Context, id uint64) error
+	// Poll records the time the client polled at and the interval it must wait
+	Poll(ctx context.Context, id uint64, at time.Time, interval int) error
+	// Use marks the approved code used, ErrNotFound is returned when it was already used
+	Use(ctx context.Context, id uint64) error
+	// Purge removes the codes expired before the time
+	Purge(ctx context.Context, before time.Time) (purged int64, err error)
 }
+
+type Consents interface {
+	Create(ctx context.Context, model *ConsentModel) error
+	Revoke(ctx context.Context, id uint64) error
+	FindByUserID(ctx context.Context, userId uint64) (models []ConsentModel, err error)
+}
+
+// Profiles return ErrDuplicateUsername when the username is taken
+type Profiles interface {
+	Create(ctx context.Context, model *ProfileModel) error
+	Update(ctx context.Context, model *ProfileModel) error
+	FindByUserID(ctx context.Context, userId uint64) (model *ProfileModel, err error)
+	FindByUsername(ctx context.Context, username string) (model *ProfileModel, err error)
+}
+
+// executor is implemented by both *sql.DB and *sql.Tx
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type database struct {
-	db                    *sql.DB
+	db                    executor
+	crypt                 crypt
 	users                 *users
 	authenticationHistory *authenticationHistory
 	auth                  *auth
+	authProviders         *authProviders
+	mfa                   *mfa
+	consents              *consents
+	profiles              *profiles
+	passwordResets        *passwordResets
+	passwordHistory       *passwordHistory
+	signingKeys           *signingKeys
+	revokedTokens         *revokedTokens
+	oauthClients          *oauthClients
+	refreshTokens         *refreshTokens
+	oauthCodes            *oauthCodes
+	deviceCodes           *deviceCodes
 }
 
-var instance *database
+var instance Database
 var once sync.Once
 
 // Create Database using singltone pattern
-func DB(db *sql.DB) Database {
+func DB(db *sql.DB, cipher Cipher) Database {
 	once.Do(func() {
-		instance = &database{
-			db: db,
-			users: &users{
-				db: db,
-			},
-			authenticationHistory: &authenticationHistory{
-				db: db,
-			},
-			auth: &auth{
-				db: db,
-			},
-		}
+		instance = New(db, cipher)
 	})
 	return instance
 }
 
+// New creates Database bound to db, every call returns a separate instance.
+// Phones, mfa secrets and recovery codes are encrypted with cipher, nil cipher stores them as is
+func New(db *sql.DB, cipher Cipher) Database {
+	return newDatabase(db, crypt{cipher: cipher})
+}
+
+func newDatabase(db executor, c crypt) *database {
+	return &database{
+		db:    db,
+		crypt: c,
+		users: &users{
+			db: db,
+		},
+		authenticationHistory: &authenticationHistory{
+			db: db,
+		},
+		auth: &auth{
+			db:    db,
+			crypt: c,
+		},
+		authProviders: &authProviders{
+			db: db,
+		},
+		mfa: &mfa{
+			db:    db,
+			crypt: c,
+		},
+		consents: &consents{
+			db: db,
+		},
+		profiles: &profiles{
+			db: db,
+		},
+		passwordResets: &passwordResets{
+			db: db,
+		},
+		passwordHistory: &passwordHistory{
+			db: db,
+		},
+		signingKeys: &signingKeys{
+			db:    db,
+			crypt: c,
+		},
+		revokedTokens: &revokedTokens{
+			db: db,
+		},
+		oauthClients: &oauthClients{
+			db: db,
+		},
+		refreshTokens: &refreshTokens{
+			db: db,
+		},
+		oauthCodes: &oauthCodes{
+			db: db,
+		},
+		deviceCodes: &deviceCodes{
+			db: db,
+		},
+	}
+}
+
 func (db *database) Users() Users {
 	return db.users
 }
@@ -67,3 +271,78 @@ func (db *database) AuthenticationHistory() AuthenticationHistory {
 func (db *database) Auth() Auth {
 	return db.auth
 }
+
+func (db *database) AuthProviders() AuthProviders {
+	return db.authProviders
+}
+
+func (db *database) Mfa() Mfa {
+	return db.mfa
+}
+
+func (db *database) Consents() Consents {
+	return db.consents
+}
+
+func (db *database) Profiles() Profiles {
+	return db.profiles
+}
+
+func (db *database) PasswordResets() PasswordResets {
+	return db.passwordResets
+}
+
+func (db *database) PasswordHistory() PasswordHistory {
+	return db.passwordHistory
+}
+
+func (db *database) SigningKeys() SigningKeys {
+	return db.signingKeys
+}
+
+func (db *database) RevokedTokens() RevokedTokens {
+	return db.revokedTokens
+}
+
+func (db *database) OAuthClients() OAuthClients {
+	return db.oauthClients
+}
+
+func (db *database) RefreshTokens() RefreshTokens {
+	return db.refreshTokens
+}
+
+func (db *database) OAuthCodes() OAuthCodes {
+	return db.oauthCodes
+}
+
+func (db *database) DeviceCodes() DeviceCodes {
+	return db.deviceCodes
+}
+
+func (db *database) Tx(ctx context.Context, fn func(tx Database) error) error {
+	return inTx(ctx, db.db, func(tx executor) error {
+		return fn(newDatabase(tx, db.crypt))
+	})
+}
+
+// inTx runs fn in a new transaction, db that is already a transaction is reused
+func inTx(ctx context.Context, db executor, fn func(tx executor) error) error {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
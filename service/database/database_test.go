@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema mirrors sqlScripts for the tables the repository tests use,
+// the MySQL upserts of mfa and revoked tokens are not covered here
+var sqliteSchema = []string{
+	`CREATE TABLE users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  kind VARCHAR(255) NOT NULL,
+  status_id INTEGER NOT NULL,
+  type VARCHAR(64) NOT NULL,
+  created DATETIME NULL,
+  updated DATETIME NULL,
+  mfa_type VARCHAR(8) NOT NULL,
+  deleted DATETIME NULL,
+  deletion_scheduled DATETIME NULL)`,
+	`CREATE TABLE auth (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL UNIQUE REFERENCES users (id) ON DELETE CASCADE,
+  phone VARCHAR(255) NULL,
+  phone_hash CHAR(64) NULL UNIQUE,
+  email VARCHAR(255) NULL UNIQUE,
+  password VARCHAR(255) NOT NULL,
+  salt VARCHAR(65) NOT NULL,
+  created DATETIME NULL,
+  updated DATETIME NULL,
+  is_email_verified TINYINT(1) NOT NULL DEFAULT 0,
+  is_phone_verified TINYINT(1) NOT NULL DEFAULT 0,
+  password_changed DATETIME NULL)`,
+	`CREATE TABLE auth_providers (
+  provider VARCHAR(64) NOT NULL,
+  provider_user_key VARCHAR(128) NOT NULL,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  PRIMARY KEY (provider, provider_user_key))`,
+	`CREATE TABLE user_mfa_secret (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL UNIQUE,
+  secret VARCHAR(512) NOT NULL)`,
+	`CREATE TABLE user_mfa_phone (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL UNIQUE,
+  phone VARCHAR(255) NOT NULL,
+  phone_hash CHAR(64) NOT NULL UNIQUE)`,
+	`CREATE TABLE users_mfa_code (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  code VARCHAR(255) NOT NULL)`,
+	`CREATE TABLE user_consents (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  kind VARCHAR(64) NOT NULL,
+  version VARCHAR(255) NOT NULL,
+  granted DATETIME NOT NULL,
+  revoked DATETIME NULL)`,
+	`CREATE TABLE user_profiles (
+  user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  display_name VARCHAR(255) NOT NULL DEFAULT '',
+  username VARCHAR(64) NULL UNIQUE,
+  locale VARCHAR(35) NOT NULL DEFAULT '',
+  timezone VARCHAR(64) NOT NULL DEFAULT '',
+  attributes TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  updated DATETIME NOT NULL)`,
+	`CREATE TABLE password_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  hash VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL)`,
+	`CREATE TABLE signing_keys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  kid VARCHAR(64) NOT NULL UNIQUE,
+  alg VARCHAR(16) NOT NULL,
+  private_key TEXT NOT NULL,
+  activates DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  retires DATETIME NOT NULL)`,
+}
+
+// newSQLite returns Database over a fresh SQLite file removed with the test
+func newSQLite(t *testing.T) Database {
+	t.Helper()
+	return New(openSQLite(t), nil)
+}
+
+// openSQLite creates the schema in a fresh SQLite file removed with the test
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "auth.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// the writes of a transaction must not wait for another connection
+	db.SetMaxOpenConns(1)
+
+	for _, script := range sqliteSchema {
+		if _, err := db.Exec(script); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
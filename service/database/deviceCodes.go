@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const deviceCodeColumns = "id, device_code_hash, user_code_hash, client_id, scope, user_id, poll_interval, created, expires, last_polled, approved, denied, used"
+
+type deviceCodes struct {
+	db executor
+}
+
+func (d *deviceCodes) Create(ctx context.Context, model *DeviceCodeModel) error {
+	res, err := d.db.ExecContext(ctx, "INSERT INTO oauth_device_codes (device_code_hash, user_code_hash, client_id, scope, poll_interval, created, expires) VALUES(?,?,?,?,?,?,?)",
+		model.DeviceCodeHash, model.UserCodeHash, model.ClientId, model.Scope, model.Interval, model.Created, model.Expires)
+	if err != nil {
+		return fmt.Errorf("insert oauth_device_codes: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert oauth_device_codes: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (d *deviceCodes) FindByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (model *DeviceCodeModel, err error) {
+	row := d.db.QueryRowContext(ctx, "SELECT "+deviceCodeColumns+" FROM oauth_device_codes WHERE device_code_hash = ?", deviceCodeHash)
+	model, err = scanDeviceCode(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find oauth_device_codes by device code: %w", err)
+	}
+	return model, nil
+}
+
+func (d *deviceCodes) FindByUserCodeHash(ctx context.Context, userCodeHash string) (model *DeviceCodeModel, err error) {
+	row := d.db.QueryRowContext(ctx, "SELECT "+deviceCodeColumns+" FROM oauth_device_codes WHERE user_code_hash = ?", userCodeHash)
+	model, err = scanDeviceCode(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find oauth_device_codes by user code: %w", err)
+	}
+	return model, nil
+}
+
+// Approve grants the pending code to the user, ErrNotFound is returned when it isn't pending
+func (d *deviceCodes) Approve(ctx context.Context, id, userId uint64) error {
+	res, err := d.db.ExecContext(ctx, "UPDATE oauth_device_codes SET user_id = ?, approved = ? WHERE id = ? AND approved IS NULL AND denied IS NULL",
+		userId, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("approve oauth_device_codes: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("approve oauth_device_codes: %w", ErrNotFound)
+	}
+	return nil
+}
+
+// Deny rejects the pending code, ErrNotFound is returned when it isn't pending
+func (d *deviceCodes) Deny(ctx context.Context, id uint64) error {
+	res, err := d.db.ExecContext(ctx, "UPDATE oauth_device_codes SET denied = ? WHERE id = ? AND approved IS NULL AND denied IS NULL",
+		time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("deny oauth_device_codes: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("deny oauth_device_codes: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (d *deviceCodes) Poll(ctx context.Context, id uint64, at time.Time, interval int) error {
+	_, err := d.db.ExecContext(ctx, "UPDATE oauth_device_codes SET last_polled = ?, poll_interval = ? WHERE id = ?", at, interval, id)
+	if err != nil {
+		return fmt.Errorf("poll oauth_device_codes: %w", err)
+	}
+	return nil
+}
+
+// Use marks the approved code used, ErrNotFound is returned when it was already used
+func (d *deviceCodes) Use(ctx context.Context, id uint64) error {
+	res, err := d.db.ExecContext(ctx, "UPDATE oauth_device_codes SET used = ? WHERE id = ? AND approved IS NOT NULL AND used IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("use oauth_device_codes: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("use oauth_device_codes: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (d *deviceCodes) Purge(ctx context.Context, before time.Time) (purged int64, err error) {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM oauth_device_codes WHERE expires <= ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("purge oauth_device_codes: %w", err)
+	}
+	purged, _ = res.RowsAffected()
+	return purged, nil
+}
+
+func scanDeviceCode(row scanner) (*DeviceCodeModel, error) {
+	var (
+		m                                  DeviceCodeModel
+		userId                             sql.NullInt64
+		lastPolled, approved, denied, used sql.NullTime
+	)
+	if err := row.Scan(&m.Id, &m.DeviceCodeHash, &m.UserCodeHash, &m.ClientId, &m.Scope, &userId, &m.Interval,
+		&m.Created, &m.Expires, &lastPolled, &approved, &denied, &used); err != nil {
+		return nil, err
+	}
+	m.UserId = uint64(userId.Int64)
+	m.LastPolled = timePtr(lastPolled)
+	m.Approved = timePtr(approved)
+	m.Denied = timePtr(denied)
+	m.Used = timePtr(used)
+	return &m, nil
+}
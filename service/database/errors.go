@@ -0,0 +1,37 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrDuplicateEmail    = errors.New("email already exists")
+	ErrDuplicatePhone    = errors.New("phone already exists")
+	ErrDuplicateUsername = errors.New("username already exists")
+	ErrNotFound          = errors.New("not found")
+	ErrEmptyModel        = errors.New("empty model")
+)
+
+// mysql error "Duplicate entry '%s' for key %s"
+const errDuplicateEntry = 1062
+
+// uniqueError converts unique index violation to the typed error, other errors are returned as is
+func uniqueError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != errDuplicateEntry {
+		return err
+	}
+	message := strings.ToLower(mysqlErr.Message)
+	switch {
+	case strings.Contains(message, "email_unique"):
+		return ErrDuplicateEmail
+	case strings.Contains(message, "phone_hash_unique"):
+		return ErrDuplicatePhone
+	case strings.Contains(message, "username_unique"):
+		return ErrDuplicateUsername
+	}
+	return err
+}
@@ -0,0 +1,1361 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memory keeps all tables in maps, it is used in tests and for prototyping
+type memory struct {
+	mu sync.RWMutex
+	t  *memoryTables
+
+	usersRepo         *memoryUsers
+	authRepo          *memoryAuth
+	authProvidersRepo *memoryAuthProviders
+	historyRepo       *memoryAuthenticationHistory
+	mfaRepo           *memoryMfa
+	consentsRepo      *memoryConsents
+	profilesRepo      *memoryProfiles
+	resetsRepo        *memoryPasswordResets
+	pwHistoryRepo     *memoryPasswordHistory
+	signingKeysRepo   *memorySigningKeys
+	revokedRepo       *memoryRevokedTokens
+	clientsRepo       *memoryOAuthClients
+	refreshRepo       *memoryRefreshTokens
+	codesRepo         *memoryOAuthCodes
+	deviceRepo        *memoryDeviceCodes
+}
+
+type memoryTables struct {
+	users      map[uint64]UsersModel
+	auth       map[uint64]AuthModel
+	providers  map[[2]string]AuthProvidersModel
+	history    map[int64]AuthenticationHistoryModel
+	consents   map[uint64]ConsentModel
+	profiles   map[uint64]ProfileModel
+	resets     map[uint64]PasswordResetModel
+	pwHistory  map[uint64]PasswordHistoryModel
+	mfaSecret  map[uint64]string
+	mfaPhone   map[uint64]string
+	mfaCodes   map[uint64][]string
+	keys       map[uint64]SigningKeyModel
+	revoked    map[string]time.Time
+	clients    map[uint64]OAuthClientModel
+	refresh    map[uint64]RefreshTokenModel
+	codes      map[uint64]OAuthCodeModel
+	devices    map[uint64]DeviceCodeModel
+	userSeq    uint64
+	authSeq    uint64
+	historySeq int64
+	consentSeq uint64
+	resetSeq   uint64
+	pwHistSeq  uint64
+	keySeq     uint64
+	clientSeq  uint64
+	refreshSeq uint64
+	codeSeq    uint64
+	deviceSeq  uint64
+}
+
+// memoryTx is passed to Tx callback, nested Tx calls join the running one
+type memoryTx struct {
+	*memory
+}
+
+type memoryUsers struct {
+	m *memory
+}
+
+type memoryAuth struct {
+	m *memory
+}
+
+type memoryAuthProviders struct {
+	m *memory
+}
+
+type memoryAuthenticationHistory struct {
+	m *memory
+}
+
+type memoryMfa struct {
+	m *memory
+}
+
+type memoryConsents struct {
+	m *memory
+}
+
+type memoryProfiles struct {
+	m *memory
+}
+
+type memoryPasswordResets struct {
+	m *memory
+}
+
+type memoryPasswordHistory struct {
+	m *memory
+}
+
+type memorySigningKeys struct {
+	m *memory
+}
+
+type memoryRevokedTokens struct {
+	m *memory
+}
+
+type memoryOAuthClients struct {
+	m *memory
+}
+
+type memoryRefreshTokens struct {
+	m *memory
+}
+
+type memoryOAuthCodes struct {
+	m *memory
+}
+
+type memoryDeviceCodes struct {
+	m *memory
+}
+
+// NewMemory creates in-memory Database, every call returns a separate instance
+func NewMemory() Database {
+	return newMemory(&memoryTables{
+		users:     map[uint64]UsersModel{},
+		auth:      map[uint64]AuthModel{},
+		providers: map[[2]string]AuthProvidersModel{},
+		history:   map[int64]AuthenticationHistoryModel{},
+		consents:  map[uint64]ConsentModel{},
+		profiles:  map[uint64]ProfileModel{},
+		resets:    map[uint64]PasswordResetModel{},
+		pwHistory: map[uint64]PasswordHistoryModel{},
+		mfaSecret: map[uint64]string{},
+		mfaPhone:  map[uint64]string{},
+		mfaCodes:  map[uint64][]string{},
+		keys:      map[uint64]SigningKeyModel{},
+		revoked:   map[string]time.Time{},
+		clients:   map[uint64]OAuthClientModel{},
+		refresh:   map[uint64]RefreshTokenModel{},
+		codes:     map[uint64]OAuthCodeModel{},
+		devices:   map[uint64]DeviceCodeModel{},
+	})
+}
+
+// newMemory builds the repositories over t
+func newMemory(t *memoryTables) *memory {
+	m := &memory{t: t}
+	m.usersRepo = &memoryUsers{m: m}
+	m.authRepo = &memoryAuth{m: m}
+	m.authProvidersRepo = &memoryAuthProviders{m: m}
+	m.historyRepo = &memoryAuthenticationHistory{m: m}
+	m.mfaRepo = &memoryMfa{m: m}
+	m.consentsRepo = &memoryConsents{m: m}
+	m.profilesRepo = &memoryProfiles{m: m}
+	m.resetsRepo = &memoryPasswordResets{m: m}
+	m.pwHistoryRepo = &memoryPasswordHistory{m: m}
+	m.signingKeysRepo = &memorySigningKeys{m: m}
+	m.revokedRepo = &memoryRevokedTokens{m: m}
+	m.clientsRepo = &memoryOAuthClients{m: m}
+	m.refreshRepo = &memoryRefreshTokens{m: m}
+	m.codesRepo = &memoryOAuthCodes{m: m}
+	m.deviceRepo = &memoryDeviceCodes{m: m}
+	return m
+}
+
+func (m *memory) Users() Users {
+	return m.usersRepo
+}
+
+func (m *memory) AuthenticationHistory() AuthenticationHistory {
+	return m.historyRepo
+}
+
+func (m *memory) Auth() Auth {
+	return m.authRepo
+}
+
+func (m *memory) AuthProviders() AuthProviders {
+	return m.authProvidersRepo
+}
+
+func (m *memory) Mfa() Mfa {
+	return m.mfaRepo
+}
+
+func (m *memory) Consents() Consents {
+	return m.consentsRepo
+}
+
+func (m *memory) Profiles() Profiles {
+	return m.profilesRepo
+}
+
+func (m *memory) PasswordResets() PasswordResets {
+	return m.resetsRepo
+}
+
+func (m *memory) PasswordHistory() PasswordHistory {
+	return m.pwHistoryRepo
+}
+
+func (m *memory) SigningKeys() SigningKeys {
+	return m.signingKeysRepo
+}
+
+func (m *memory) RevokedTokens() RevokedTokens {
+	return m.revokedRepo
+}
+
+func (m *memory) OAuthClients() OAuthClients {
+	return m.clientsRepo
+}
+
+func (m *memory) RefreshTokens() RefreshTokens {
+	return m.refreshRepo
+}
+
+func (m *memory) OAuthCodes() OAuthCodes {
+	return m.codesRepo
+}
+
+func (m *memory) DeviceCodes() DeviceCodes {
+	return m.deviceRepo
+}
+
+// Tx restores all tables when fn fails. The lock is held for the whole transaction, so
+// the writes made outside of it wait instead of being lost with the restored tables
+func (m *memory) Tx(ctx context.Context, fn func(tx Database) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := m.t.clone()
+	// the repositories of tx share the tables and lock their own mutex which is never contended
+	if err := fn(memoryTx{newMemory(m.t)}); err != nil {
+		*m.t = snapshot
+		return err
+	}
+	return nil
+}
+
+func (t memoryTx) Tx(ctx context.Context, fn func(tx Database) error) error {
+	return fn(t)
+}
+
+func (t memoryTables) clone() memoryTables {
+	c := t
+	c.users = make(map[uint64]UsersModel, len(t.users))
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+	c.auth = make(map[uint64]AuthModel, len(t.auth))
+	for k, v := range t.auth {
+		c.auth[k] = v
+	}
+	c.providers = make(map[[2]string]AuthProvidersModel, len(t.providers))
+	for k, v := range t.providers {
+		c.providers[k] = v
+	}
+	c.history = make(map[int64]AuthenticationHistoryModel, len(t.history))
+	for k, v := range t.history {
+		c.history[k] = v
+	}
+	c.consents = make(map[uint64]ConsentModel, len(t.consents))
+	for k, v := range t.consents {
+		c.consents[k] = v
+	}
+	c.profiles = make(map[uint64]ProfileModel, len(t.profiles))
+	for k, v := range t.profiles {
+		c.profiles[k] = v
+	}
+	c.resets = make(map[uint64]PasswordResetModel, len(t.resets))
+	for k, v := range t.resets {
+		c.resets[k] = v
+	}
+	c.pwHistory = make(map[uint64]PasswordHistoryModel, len(t.pwHistory))
+	for k, v := range t.pwHistory {
+		c.pwHistory[k] = v
+	}
+	c.mfaSecret = make(map[uint64]string, len(t.mfaSecret))
+	for k, v := range t.mfaSecret {
+		c.mfaSecret[k] = v
+	}
+	c.mfaPhone = make(map[uint64]string, len(t.mfaPhone))
+	for k, v := range t.mfaPhone {
+		c.mfaPhone[k] = v
+	}
+	c.mfaCodes = make(map[uint64][]string, len(t.mfaCodes))
+	for k, v := range t.mfaCodes {
+		c.mfaCodes[k] = append([]string(nil), v...)
+	}
+	c.keys = make(map[uint64]SigningKeyModel, len(t.keys))
+	for k, v := range t.keys {
+		c.keys[k] = v
+	}
+	c.revoked = make(map[string]time.Time, len(t.revoked))
+	for k, v := range t.revoked {
+		c.revoked[k] = v
+	}
+	c.clients = make(map[uint64]OAuthClientModel, len(t.clients))
+	for k, v := range t.clients {
+		c.clients[k] = v
+	}
+	c.refresh = make(map[uint64]RefreshTokenModel, len(t.refresh))
+	for k, v := range t.refresh {
+		c.refresh[k] = v
+	}
+	c.codes = make(map[uint64]OAuthCodeModel, len(t.codes))
+	for k, v := range t.codes {
+		c.codes[k] = v
+	}
+	c.devices = make(map[uint64]DeviceCodeModel, len(t.devices))
+	for k, v := range t.devices {
+		c.devices[k] = v
+	}
+	return c
+}
+
+// checkUnique must be called with m.mu locked
+func (m *memory) checkUnique(model *AuthModel) error {
+	for id, a := range m.t.auth {
+		if id == model.Id_Auth {
+			continue
+		}
+		if model.Email_Auth != "" && a.Email_Auth == model.Email_Auth {
+			return ErrDuplicateEmail
+		}
+		if model.Phone_Auth != "" && a.Phone_Auth == model.Phone_Auth {
+			return ErrDuplicatePhone
+		}
+	}
+	return nil
+}
+
+// deleteUser removes user with the rows referencing it, must be called with m.mu locked
+func (m *memory) deleteUser(id uint64) {
+	delete(m.t.users, id)
+	for k, a := range m.t.auth {
+		if a.UserId_Auth == id {
+			delete(m.t.auth, k)
+		}
+	}
+	for k, p := range m.t.providers {
+		if p.UserId == id {
+			delete(m.t.providers, k)
+		}
+	}
+	for k, h := range m.t.history {
+		if h.UserId == id {
+			delete(m.t.history, k)
+		}
+	}
+	for k, c := range m.t.consents {
+		if c.UserId == id {
+			delete(m.t.consents, k)
+		}
+	}
+	delete(m.t.profiles, id)
+	for k, r := range m.t.resets {
+		if r.UserId == id {
+			delete(m.t.resets, k)
+		}
+	}
+	for k, h := range m.t.pwHistory {
+		if h.UserId == id {
+			delete(m.t.pwHistory, k)
+		}
+	}
+	delete(m.t.mfaSecret, id)
+	delete(m.t.mfaPhone, id)
+	delete(m.t.mfaCodes, id)
+	for k, r := range m.t.refresh {
+		if r.UserId == id {
+			delete(m.t.refresh, k)
+		}
+	}
+	for k, c := range m.t.codes {
+		if c.UserId == id {
+			delete(m.t.codes, k)
+		}
+	}
+	for k, c := range m.t.clients {
+		if c.OwnerId == id {
+			delete(m.t.clients, k)
+		}
+	}
+	for k, d := range m.t.devices {
+		if d.UserId == id {
+			delete(m.t.devices, k)
+		}
+	}
+}
+
+func (u *memoryUsers) Create(ctx context.Context, model *UsersModel) error {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+
+	u.m.t.userSeq++
+	model.Id = u.m.t.userSeq
+	u.m.t.users[model.Id] = *model
+	return nil
+}
+
+func (u *memoryUsers) Update(ctx context.Context, model *UsersModel) error {
+	if model.Id == 0 {
+		return ErrEmptyModel
+	}
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+
+	old, ok := u.m.t.users[model.Id]
+	if !ok {
+		return fmt.Errorf("update users: %w", ErrNotFound)
+	}
+	model.Created = old.Created
+	model.Deleted = old.Deleted
+	model.DeletionScheduled = old.DeletionScheduled
+	u.m.t.users[model.Id] = *model
+	return nil
+}
+
+func (u *memoryUsers) Delete(ctx context.Context, id uint64) error {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+
+	u.m.deleteUser(id)
+	return nil
+}
+
+func (u *memoryUsers) SoftDelete(ctx context.Context, id uint64) error {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+
+	model, ok := u.m.t.users[id]
+	if !ok || model.Deleted != nil {
+		return fmt.Errorf("soft delete users: %w", ErrNotFound)
+	}
+	now := time.Now()
+	model.Deleted = &now
+	u.m.t.users[id] = model
+	return nil
+}
+
+func (u *memoryUsers) ScheduleDeletion(ctx context.Context, id uint64, at time.Time) error {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+
+	if model, ok := u.m.t.users[id]; ok {
+		model.DeletionScheduled = &at
+		u.m.t.users[id] = model
+	}
+	return nil
+}
+
+func (u *memoryUsers) CancelDeletion(ctx context.Context, id uint64) error {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+
+	if model, ok := u.m.t.users[id]; ok {
+		model.DeletionScheduled = nil
+		u.m.t.users[id] = model
+	}
+	return nil
+}
+
+func (u *memoryUsers) FindByID(ctx context.Context, id uint64) (model *UsersModel, err error) {
+	u.m.mu.RLock()
+	defer u.m.mu.RUnlock()
+
+	m, ok := u.m.t.users[id]
+	if !ok {
+		return nil, fmt.Errorf("find users by id: %w", ErrNotFound)
+	}
+	return &m, nil
+}
+
+func (u *memoryUsers) List(ctx context.Context, filter UsersFilter) (models []UsersModel, next uint64, err error) {
+	u.m.mu.RLock()
+	defer u.m.mu.RUnlock()
+
+	for _, m := range u.m.t.users {
+		if m.Id > filter.Cursor && filter.match(m) {
+			models = append(models, m)
+		}
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Id < models[j].Id
+	})
+
+	limit := filter.limit()
+	if len(models) > limit {
+		models = models[:limit]
+		next = models[limit-1].Id
+	}
+	return models, next, nil
+}
+
+func (u *memoryUsers) Count(ctx context.Context, filter UsersFilter) (count uint64, err error) {
+	u.m.mu.RLock()
+	defer u.m.mu.RUnlock()
+
+	for _, m := range u.m.t.users {
+		if filter.match(m) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (a *memoryAuth) Create(ctx context.Context, model *AuthModel) error {
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+
+	if err := a.m.checkUnique(model); err != nil {
+		return fmt.Errorf("insert auth: %w", err)
+	}
+
+	a.m.t.userSeq++
+	a.m.t.users[a.m.t.userSeq] = UsersModel{
+		Id:       a.m.t.userSeq,
+		Kind:     model.Kind_Users,
+		StatusId: model.StatusId_Users,
+		Type:     model.Type_Users,
+		Created:  model.Created_Users,
+		Updated:  model.Updated_Users,
+		MfaType:  model.Mfa_type_Users,
+	}
+
+	a.m.t.authSeq++
+	model.Id_Auth = a.m.t.authSeq
+	model.Id_Users = a.m.t.userSeq
+	model.UserId_Auth = a.m.t.userSeq
+	a.m.t.auth[model.Id_Auth] = *model
+	return nil
+}
+
+func (a *memoryAuth) Update(ctx context.Context, model *AuthModel) error {
+	if model.Id_Auth == 0 {
+		return ErrEmptyModel
+	}
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+
+	old, ok := a.m.t.auth[model.Id_Auth]
+	if !ok {
+		return fmt.Errorf("update auth: %w", ErrNotFound)
+	}
+	if err := a.m.checkUnique(model); err != nil {
+		return fmt.Errorf("update auth: %w", err)
+	}
+	model.UserId_Auth = old.UserId_Auth
+	model.Created_Auth = old.Created_Auth
+	a.m.t.auth[model.Id_Auth] = *model
+	return nil
+}
+
+func (a *memoryAuth) Delete(ctx context.Context, id uint64) error {
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+
+	delete(a.m.t.auth, id)
+	return nil
+}
+
+func (a *memoryAuth) FindByID(ctx context.Context, id uint64) (model *AuthModel, err error) {
+	model, err = a.find(func(m AuthModel) bool { return m.Id_Auth == id })
+	if err != nil {
+		return nil, fmt.Errorf("find auth by id: %w", err)
+	}
+	return model, nil
+}
+
+func (a *memoryAuth) FindByUserID(ctx context.Context, userId uint64) (model *AuthModel, err error) {
+	model, err = a.find(func(m AuthModel) bool { return m.UserId_Auth == userId })
+	if err != nil {
+		return nil, fmt.Errorf("find auth by user id: %w", err)
+	}
+	return model, nil
+}
+
+func (a *memoryAuth) FindByEmail(ctx context.Context, email string) (model *AuthModel, err error) {
+	model, err = a.find(func(m AuthModel) bool { return m.Email_Auth == email })
+	if err != nil {
+		return nil, fmt.Errorf("find auth by email: %w", err)
+	}
+	return model, nil
+}
+
+func (a *memoryAuth) FindByPhone(ctx context.Context, phone string) (model *AuthModel, err error) {
+	model, err = a.find(func(m AuthModel) bool { return m.Phone_Auth == phone })
+	if err != nil {
+		return nil, fmt.Errorf("find auth by phone: %w", err)
+	}
+	return model, nil
+}
+
+func (a *memoryAuth) FindByProvider(ctx context.Context, provider, providerUserKey string) (model *AuthModel, err error) {
+	a.m.mu.RLock()
+	p, ok := a.m.t.providers[[2]string{provider, providerUserKey}]
+	a.m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("find auth by provider: %w", ErrNotFound)
+	}
+
+	model, err = a.find(func(m AuthModel) bool { return m.UserId_Auth == p.UserId })
+	if err != nil {
+		return nil, fmt.Errorf("find auth by provider: %w", err)
+	}
+	return model, nil
+}
+
+// find returns auth row joined with not deleted user, like the sql version does
+func (a *memoryAuth) find(match func(AuthModel) bool) (*AuthModel, error) {
+	a.m.mu.RLock()
+	defer a.m.mu.RUnlock()
+
+	for _, m := range a.m.t.auth {
+		if !match(m) {
+			continue
+		}
+		u, ok := a.m.t.users[m.UserId_Auth]
+		if !ok || u.Deleted != nil {
+			continue
+		}
+		m.Id_Users = u.Id
+		m.Kind_Users = u.Kind
+		m.StatusId_Users = u.StatusId
+		m.Type_Users = u.Type
+		m.Created_Users = u.Created
+		m.Updated_Users = u.Updated
+		m.Mfa_type_Users = u.MfaType
+		m.DeletionScheduled_Users = u.DeletionScheduled
+		return &m, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (a *memoryAuthProviders) Create(ctx context.Context, model *AuthProvidersModel) error {
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+
+	key := [2]string{model.Provider, model.ProviderUserKey}
+	if _, ok := a.m.t.providers[key]; ok {
+		return fmt.Errorf("insert auth_providers: duplicate %s key", model.Provider)
+	}
+	a.m.t.providers[key] = *model
+	return nil
+}
+
+func (a *memoryAuthProviders) Delete(ctx context.Context, provider, providerUserKey string) error {
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+
+	delete(a.m.t.providers, [2]string{provider, providerUserKey})
+	return nil
+}
+
+func (a *memoryAuthProviders) FindByUserID(ctx context.Context, userId uint64) (models []AuthProvidersModel, err error) {
+	a.m.mu.RLock()
+	defer a.m.mu.RUnlock()
+
+	for _, p := range a.m.t.providers {
+		if p.UserId == userId {
+			models = append(models, p)
+		}
+	}
+	return models, nil
+}
+
+func (h *memoryAuthenticationHistory) Create(ctx context.Context, model *AuthenticationHistoryModel) error {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+
+	h.m.t.historySeq++
+	model.Id = h.m.t.historySeq
+	h.m.t.history[model.Id] = *model
+	return nil
+}
+
+func (h *memoryAuthenticationHistory) Update(ctx context.Context, model *AuthenticationHistoryModel) error {
+	if model.Id == 0 {
+		return ErrEmptyModel
+	}
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+
+	if _, ok := h.m.t.history[model.Id]; !ok {
+		return fmt.Errorf("update authentication_history: %w", ErrNotFound)
+	}
+	h.m.t.history[model.Id] = *model
+	return nil
+}
+
+func (h *memoryAuthenticationHistory) FindBySecret(ctx context.Context, secret string) (model *AuthenticationHistoryModel, err error) {
+	h.m.mu.RLock()
+	defer h.m.mu.RUnlock()
+
+	for _, m := range h.m.t.history {
+		if secret != "" && m.Secret == secret {
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("find authentication_history by secret: %w", ErrNotFound)
+}
+
+func (h *memoryAuthenticationHistory) FindByUserID(ctx context.Context, userId uint64) (models []AuthenticationHistoryModel, err error) {
+	h.m.mu.RLock()
+	defer h.m.mu.RUnlock()
+
+	for _, m := range h.m.t.history {
+		if m.UserId == userId {
+			models = append(models, m)
+		}
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Id > models[j].Id
+	})
+	return models, nil
+}
+
+func (f *memoryMfa) State(ctx context.Context, userId uint64) (model *MfaStateModel, err error) {
+	f.m.mu.RLock()
+	defer f.m.mu.RUnlock()
+
+	u, ok := f.m.t.users[userId]
+	if !ok {
+		return nil, fmt.Errorf("mfa state: %w", ErrNotFound)
+	}
+	_, secret := f.m.t.mfaSecret[userId]
+	return &MfaStateModel{
+		UserId: userId,
+		Type:   u.MfaType,
+		Secret: secret,
+		Phone:  f.m.t.mfaPhone[userId],
+		Codes:  len(f.m.t.mfaCodes[userId]),
+	}, nil
+}
+
+func (f *memoryMfa) SetSecret(ctx context.Context, userId uint64, secret string) error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	f.m.t.mfaSecret[userId] = secret
+	return nil
+}
+
+func (f *memoryMfa) Secret(ctx context.Context, userId uint64) (secret string, err error) {
+	f.m.mu.RLock()
+	defer f.m.mu.RUnlock()
+
+	secret, ok := f.m.t.mfaSecret[userId]
+	if !ok {
+		return "", fmt.Errorf("find user_mfa_secret: %w", ErrNotFound)
+	}
+	return secret, nil
+}
+
+func (f *memoryMfa) SetPhone(ctx context.Context, userId uint64, phone string) error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	for id, p := range f.m.t.mfaPhone {
+		if id != userId && p == phone {
+			return fmt.Errorf("set user_mfa_phone: %w", ErrDuplicatePhone)
+		}
+	}
+	f.m.t.mfaPhone[userId] = phone
+	return nil
+}
+
+func (f *memoryMfa) Phone(ctx context.Context, userId uint64) (phone string, err error) {
+	f.m.mu.RLock()
+	defer f.m.mu.RUnlock()
+
+	phone, ok := f.m.t.mfaPhone[userId]
+	if !ok {
+		return "", fmt.Errorf("find user_mfa_phone: %w", ErrNotFound)
+	}
+	return phone, nil
+}
+
+func (f *memoryMfa) SetCodes(ctx context.Context, userId uint64, codes []string) error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	f.m.t.mfaCodes[userId] = append([]string(nil), codes...)
+	return nil
+}
+
+func (f *memoryMfa) Codes(ctx context.Context, userId uint64) (codes []string, err error) {
+	f.m.mu.RLock()
+	defer f.m.mu.RUnlock()
+
+	return append([]string(nil), f.m.t.mfaCodes[userId]...), nil
+}
+
+func (f *memoryMfa) Delete(ctx context.Context, userId uint64) error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+
+	delete(f.m.t.mfaSecret, userId)
+	delete(f.m.t.mfaPhone, userId)
+	delete(f.m.t.mfaCodes, userId)
+	return nil
+}
+
+func (c *memoryConsents) Create(ctx context.Context, model *ConsentModel) error {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	c.m.t.consentSeq++
+	model.Id = c.m.t.consentSeq
+	c.m.t.consents[model.Id] = *model
+	return nil
+}
+
+func (c *memoryConsents) Revoke(ctx context.Context, id uint64) error {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	model, ok := c.m.t.consents[id]
+	if !ok || model.Revoked != nil {
+		return nil
+	}
+	now := time.Now()
+	model.Revoked = &now
+	c.m.t.consents[id] = model
+	return nil
+}
+
+func (c *memoryConsents) FindByUserID(ctx context.Context, userId uint64) (models []ConsentModel, err error) {
+	c.m.mu.RLock()
+	defer c.m.mu.RUnlock()
+
+	for _, m := range c.m.t.consents {
+		if m.UserId == userId {
+			models = append(models, m)
+		}
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Id < models[j].Id
+	})
+	return models, nil
+}
+
+func (p *memoryProfiles) Create(ctx context.Context, model *ProfileModel) error {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+
+	if _, ok := p.m.t.profiles[model.UserId]; ok {
+		return fmt.Errorf("insert user_profiles: duplicate user %d", model.UserId)
+	}
+	if err := p.checkUsername(model); err != nil {
+		return fmt.Errorf("insert user_profiles: %w", err)
+	}
+	p.m.t.profiles[model.UserId] = copyProfile(*model)
+	return nil
+}
+
+func (p *memoryProfiles) Update(ctx context.Context, model *ProfileModel) error {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+
+	if _, ok := p.m.t.profiles[model.UserId]; !ok {
+		return fmt.Errorf("update user_profiles: %w", ErrNotFound)
+	}
+	if err := p.checkUsername(model); err != nil {
+		return fmt.Errorf("update user_profiles: %w", err)
+	}
+	p.m.t.profiles[model.UserId] = copyProfile(*model)
+	return nil
+}
+
+func (p *memoryProfiles) FindByUserID(ctx context.Context, userId uint64) (model *ProfileModel, err error) {
+	p.m.mu.RLock()
+	defer p.m.mu.RUnlock()
+
+	m, ok := p.m.t.profiles[userId]
+	if !ok {
+		return nil, fmt.Errorf("find user_profiles by user id: %w", ErrNotFound)
+	}
+	m = copyProfile(m)
+	return &m, nil
+}
+
+func (p *memoryProfiles) FindByUsername(ctx context.Context, username string) (model *ProfileModel, err error) {
+	p.m.mu.RLock()
+	defer p.m.mu.RUnlock()
+
+	for _, m := range p.m.t.profiles {
+		if username != "" && m.Username == username {
+			m = copyProfile(m)
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("find user_profiles by username: %w", ErrNotFound)
+}
+
+// checkUsername must be called with m.mu locked
+func (p *memoryProfiles) checkUsername(model *ProfileModel) error {
+	if model.Username == "" {
+		return nil
+	}
+	for id, m := range p.m.t.profiles {
+		if id != model.UserId && m.Username == model.Username {
+			return ErrDuplicateUsername
+		}
+	}
+	return nil
+}
+
+// copyProfile detaches the attributes from the caller's map
+func copyProfile(m ProfileModel) ProfileModel {
+	if m.Attributes != nil {
+		attributes := make(map[string]string, len(m.Attributes))
+		for k, v := range m.Attributes {
+			attributes[k] = v
+		}
+		m.Attributes = attributes
+	}
+	return m
+}
+
+func (p *memoryPasswordResets) Create(ctx context.Context, model *PasswordResetModel) error {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+
+	p.m.t.resetSeq++
+	model.Id = p.m.t.resetSeq
+	p.m.t.resets[model.Id] = *model
+	return nil
+}
+
+func (p *memoryPasswordResets) FindByTokenHash(ctx context.Context, tokenHash string) (model *PasswordResetModel, err error) {
+	p.m.mu.RLock()
+	defer p.m.mu.RUnlock()
+
+	for _, m := range p.m.t.resets {
+		if m.TokenHash == tokenHash {
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("find password_resets by token: %w", ErrNotFound)
+}
+
+func (p *memoryPasswordResets) Use(ctx context.Context, id uint64) error {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+
+	model, ok := p.m.t.resets[id]
+	if !ok || model.Used != nil {
+		return fmt.Errorf("use password_resets: %w", ErrNotFound)
+	}
+	now := time.Now()
+	model.Used = &now
+	p.m.t.resets[id] = model
+	return nil
+}
+
+func (p *memoryPasswordHistory) Create(ctx context.Context, model *PasswordHistoryModel) error {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+
+	p.m.t.pwHistSeq++
+	model.Id = p.m.t.pwHistSeq
+	p.m.t.pwHistory[model.Id] = *model
+	return nil
+}
+
+func (p *memoryPasswordHistory) Recent(ctx context.Context, userId uint64, limit int) (models []PasswordHistoryModel, err error) {
+	p.m.mu.RLock()
+	defer p.m.mu.RUnlock()
+
+	return p.m.recentPasswords(userId, limit), nil
+}
+
+func (p *memoryPasswordHistory) Prune(ctx context.Context, userId uint64, keep int) error {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+
+	recent := map[uint64]bool{}
+	for _, h := range p.m.recentPasswords(userId, keep) {
+		recent[h.Id] = true
+	}
+	for k, h := range p.m.t.pwHistory {
+		if h.UserId == userId && !recent[k] {
+			delete(p.m.t.pwHistory, k)
+		}
+	}
+	return nil
+}
+
+// recentPasswords must be called with m.mu locked
+func (m *memory) recentPasswords(userId uint64, limit int) []PasswordHistoryModel {
+	var models []PasswordHistoryModel
+	for _, h := range m.t.pwHistory {
+		if h.UserId == userId {
+			models = append(models, h)
+		}
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Id > models[j].Id
+	})
+	if len(models) > limit {
+		models = models[:limit]
+	}
+	return models
+}
+
+func (s *memorySigningKeys) Create(ctx context.Context, model *SigningKeyModel) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.t.keySeq++
+	model.Id = s.m.t.keySeq
+	s.m.t.keys[model.Id] = *model
+	return nil
+}
+
+func (s *memorySigningKeys) Delete(ctx context.Context, id uint64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	delete(s.m.t.keys, id)
+	return nil
+}
+
+func (s *memorySigningKeys) List(ctx context.Context) (models []SigningKeyModel, err error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, k := range s.m.t.keys {
+		models = append(models, k)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		if !models[i].Activates.Equal(models[j].Activates) {
+			return models[i].Activates.Before(models[j].Activates)
+		}
+		return models[i].Id < models[j].Id
+	})
+	return models, nil
+}
+
+// Revoke drops expired entries, so the memory denylist doesn't need Purge calls
+func (r *memoryRevokedTokens) Revoke(ctx context.Context, jti string, expires time.Time) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.purgeRevoked(time.Now())
+	if expires.After(r.m.t.revoked[jti]) {
+		r.m.t.revoked[jti] = expires
+	}
+	return nil
+}
+
+func (r *memoryRevokedTokens) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+
+	expires, ok := r.m.t.revoked[jti]
+	return ok && expires.After(time.Now()), nil
+}
+
+func (r *memoryRevokedTokens) Purge(ctx context.Context, before time.Time) (purged int64, err error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	return r.m.purgeRevoked(before), nil
+}
+
+// purgeRevoked must be called with m.mu locked
+func (m *memory) purgeRevoked(before time.Time) (purged int64) {
+	for jti, expires := range m.t.revoked {
+		if !expires.After(before) {
+			delete(m.t.revoked, jti)
+			purged++
+		}
+	}
+	return purged
+}
+
+func (o *memoryOAuthClients) Create(ctx context.Context, model *OAuthClientModel) error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+
+	o.m.t.clientSeq++
+	model.Id = o.m.t.clientSeq
+	o.m.t.clients[model.Id] = *model
+	return nil
+}
+
+func (o *memoryOAuthClients) Update(ctx context.Context, model *OAuthClientModel) error {
+	if model.Id == 0 {
+		return ErrEmptyModel
+	}
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+
+	c, ok := o.m.t.clients[model.Id]
+	if !ok {
+		return fmt.Errorf("update oauth_clients: %w", ErrNotFound)
+	}
+	c.SecretHash = model.SecretHash
+	c.Name = model.Name
+	c.RedirectURIs = model.RedirectURIs
+	c.PostLogoutRedirectURIs = model.PostLogoutRedirectURIs
+	c.GrantTypes = model.GrantTypes
+	c.Scopes = model.Scopes
+	c.PublicKey = model.PublicKey
+	c.Updated = model.Updated
+	o.m.t.clients[model.Id] = c
+	return nil
+}
+
+func (o *memoryOAuthClients) Delete(ctx context.Context, id uint64) error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+
+	delete(o.m.t.clients, id)
+	return nil
+}
+
+func (o *memoryOAuthClients) FindByClientID(ctx context.Context, clientId string) (model *OAuthClientModel, err error) {
+	o.m.mu.RLock()
+	defer o.m.mu.RUnlock()
+
+	for _, c := range o.m.t.clients {
+		if c.ClientId == clientId {
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("find oauth_clients by client id: %w", ErrNotFound)
+}
+
+func (o *memoryOAuthClients) List(ctx context.Context) (models []OAuthClientModel, err error) {
+	o.m.mu.RLock()
+	defer o.m.mu.RUnlock()
+
+	for _, c := range o.m.t.clients {
+		models = append(models, c)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Id < models[j].Id
+	})
+	return models, nil
+}
+
+func (r *memoryRefreshTokens) Create(ctx context.Context, model *RefreshTokenModel) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.t.refreshSeq++
+	model.Id = r.m.t.refreshSeq
+	r.m.t.refresh[model.Id] = *model
+	return nil
+}
+
+func (r *memoryRefreshTokens) FindByTokenHash(ctx context.Context, tokenHash string) (model *RefreshTokenModel, err error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+
+	for _, t := range r.m.t.refresh {
+		if t.TokenHash == tokenHash {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("find oauth_refresh_tokens by token: %w", ErrNotFound)
+}
+
+func (r *memoryRefreshTokens) Revoke(ctx context.Context, id uint64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	t, ok := r.m.t.refresh[id]
+	if !ok || t.Revoked != nil {
+		return fmt.Errorf("revoke oauth_refresh_tokens: %w", ErrNotFound)
+	}
+	now := time.Now()
+	t.Revoked = &now
+	r.m.t.refresh[id] = t
+	return nil
+}
+
+func (r *memoryRefreshTokens) RevokeByUser(ctx context.Context, userId uint64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.m.t.refresh {
+		if t.UserId == userId && t.Revoked == nil {
+			t.Revoked = &now
+			r.m.t.refresh[id] = t
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshTokens) RevokeByClient(ctx context.Context, userId uint64, clientId string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.m.t.refresh {
+		if t.UserId == userId && t.ClientId == clientId && t.Revoked == nil {
+			t.Revoked = &now
+			r.m.t.refresh[id] = t
+		}
+	}
+	return nil
+}
+
+func (o *memoryOAuthCodes) Create(ctx context.Context, model *OAuthCodeModel) error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+
+	o.m.t.codeSeq++
+	model.Id = o.m.t.codeSeq
+	o.m.t.codes[model.Id] = *model
+	return nil
+}
+
+func (o *memoryOAuthCodes) FindByCodeHash(ctx context.Context, codeHash string) (model *OAuthCodeModel, err error) {
+	o.m.mu.RLock()
+	defer o.m.mu.RUnlock()
+
+	for _, c := range o.m.t.codes {
+		if c.CodeHash == codeHash {
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("find oauth_codes by code: %w", ErrNotFound)
+}
+
+func (o *memoryOAuthCodes) Use(ctx context.Context, id uint64) error {
+	o.m.mu.Lock()
+	defer o.m.mu.Unlock()
+
+	c, ok := o.m.t.codes[id]
+	if !ok || c.Used != nil {
+		return fmt.Errorf("use oauth_codes: %w", ErrNotFound)
+	}
+	now := time.Now()
+	c.Used = &now
+	o.m.t.codes[id] = c
+	return nil
+}
+
+func (d *memoryDeviceCodes) Create(ctx context.Context, model *DeviceCodeModel) error {
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+
+	for _, c := range d.m.t.devices {
+		if c.DeviceCodeHash == model.DeviceCodeHash || c.UserCodeHash == model.UserCodeHash {
+			return fmt.Errorf("insert oauth_device_codes: duplicate code")
+		}
+	}
+	d.m.t.deviceSeq++
+	model.Id = d.m.t.deviceSeq
+	d.m.t.devices[model.Id] = *model
+	return nil
+}
+
+func (d *memoryDeviceCodes) FindByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (model *DeviceCodeModel, err error) {
+	d.m.mu.RLock()
+	defer d.m.mu.RUnlock()
+
+	for _, c := range d.m.t.devices {
+		if c.DeviceCodeHash == deviceCodeHash {
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("find oauth_device_codes by device code: %w", ErrNotFound)
+}
+
+func (d *memoryDeviceCodes) FindByUserCodeHash(ctx context.Context, userCodeHash string) (model *DeviceCodeModel, err error) {
+	d.m.mu.RLock()
+	defer d.m.mu.RUnlock()
+
+	for _, c := range d.m.t.devices {
+		if c.UserCodeHash == userCodeHash {
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("find oauth_device_codes by user code: %w", ErrNotFound)
+}
+
+func (d *memoryDeviceCodes) Approve(ctx context.Context, id, userId uint64) error {
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+
+	c, ok := d.m.t.devices[id]
+	if !ok || c.Approved != nil || c.Denied != nil {
+		return fmt.Errorf("approve oauth_device_codes: %w", ErrNotFound)
+	}
+	now := time.Now()
+	c.UserId = userId
+	c.Approved = &now
+	d.m.t.devices[id] = c
+	return nil
+}
+
+func (d *memoryDeviceCodes) Deny(ctx context.Context, id uint64) error {
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+
+	c, ok := d.m.t.devices[id]
+	if !ok || c.Approved != nil || c.Denied != nil {
+		return fmt.Errorf("deny oauth_device_codes: %w", ErrNotFound)
+	}
+	now := time.Now()
+	c.Denied = &now
+	d.m.t.devices[id] = c
+	return nil
+}
+
+func (d *memoryDeviceCodes) Poll(ctx context.Context, id uint64, at time.Time, interval int) error {
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+
+	c, ok := d.m.t.devices[id]
+	if !ok {
+		return nil
+	}
+	c.LastPolled = &at
+	c.Interval = interval
+	d.m.t.devices[id] = c
+	return nil
+}
+
+func (d *memoryDeviceCodes) Use(ctx context.Context, id uint64) error {
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+
+	c, ok := d.m.t.devices[id]
+	if !ok || c.Approved == nil || c.Used != nil {
+		return fmt.Errorf("use oauth_device_codes: %w", ErrNotFound)
+	}
+	now := time.Now()
+	c.Used = &now
+	d.m.t.devices[id] = c
+	return nil
+}
+
+func (d *memoryDeviceCodes) Purge(ctx context.Context, before time.Time) (purged int64, err error) {
+	d.m.mu.Lock()
+	defer d.m.mu.Unlock()
+
+	for id, c := range d.m.t.devices {
+		if !c.Expires.After(before) {
+			delete(d.m.t.devices, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryTxRollback(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemory()
+	failed := errors.New("failed")
+
+	err := db.Tx(ctx, func(tx Database) error {
+		if err := tx.Auth().Create(ctx, newAuthModel("tx@example.com")); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("err = %v, want %v", err, failed)
+	}
+	if _, err := db.Auth().FindByEmail(ctx, "tx@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("write of the failed transaction is kept, err = %v", err)
+	}
+}
+
+// the writes made outside of the failed transaction must survive its rollback
+func TestMemoryTxKeepsConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemory()
+	started := make(chan struct{})
+	written := make(chan error)
+
+	go func() {
+		<-started
+		written <- db.Auth().Create(ctx, newAuthModel("outside@example.com"))
+	}()
+	err := db.Tx(ctx, func(tx Database) error {
+		close(started)
+		// give the concurrent write the time to run if it is not blocked
+		time.Sleep(50 * time.Millisecond)
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("transaction succeeded")
+	}
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Auth().FindByEmail(ctx, "outside@example.com"); err != nil {
+		t.Errorf("concurrent write is lost: %v", err)
+	}
+}
+
+func TestMemoryOAuthClientsUpdateNotFound(t *testing.T) {
+	err := NewMemory().OAuthClients().Update(context.Background(), &OAuthClientModel{Id: 1, Name: "missing"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type mfa struct {
+	db    executor
+	crypt crypt
+}
+
+func (m *mfa) State(ctx context.Context, userId uint64) (model *MfaStateModel, err error) {
+	var phone sql.NullString
+	model = &MfaStateModel{UserId: userId}
+
+	err = m.db.QueryRowContext(ctx, `SELECT u.mfa_type,
+  (SELECT COUNT(*) FROM user_mfa_secret s WHERE s.user_id = u.id),
+  (SELECT p.phone FROM user_mfa_phone p WHERE p.user_id = u.id),
+  (SELECT COUNT(*) FROM users_mfa_code c WHERE c.user_id = u.id)
+FROM users u WHERE u.id = ?`, userId).Scan(&model.Type, &model.Secret, &phone, &model.Codes)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err == nil {
+		model.Phone, err = m.crypt.decrypt(phone)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mfa state: %w", err)
+	}
+	return model, nil
+}
+
+func (m *mfa) SetSecret(ctx context.Context, userId uint64, secret string) error {
+	value, err := m.crypt.encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("set user_mfa_secret: %w", err)
+	}
+	_, err = m.db.ExecContext(ctx, "INSERT INTO user_mfa_secret (user_id, secret) VALUES(?,?) ON DUPLICATE KEY UPDATE secret = VALUES(secret)",
+		userId, value)
+	if err != nil {
+		return fmt.Errorf("set user_mfa_secret: %w", err)
+	}
+	return nil
+}
+
+func (m *mfa) Secret(ctx context.Context, userId uint64) (secret string, err error) {
+	var value sql.NullString
+	err = m.db.QueryRowContext(ctx, "SELECT secret FROM user_mfa_secret WHERE user_id = ?", userId).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err == nil {
+		secret, err = m.crypt.decrypt(value)
+	}
+	if err != nil {
+		return "", fmt.Errorf("find user_mfa_secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (m *mfa) SetPhone(ctx context.Context, userId uint64, phone string) error {
+	value, err := m.crypt.encrypt(phone)
+	if err != nil {
+		return fmt.Errorf("set user_mfa_phone: %w", err)
+	}
+	_, err = m.db.ExecContext(ctx, "INSERT INTO user_mfa_phone (user_id, phone, phone_hash) VALUES(?,?,?) ON DUPLICATE KEY UPDATE phone = VALUES(phone), phone_hash = VALUES(phone_hash)",
+		userId, value, m.crypt.index(phone))
+	if err != nil {
+		return fmt.Errorf("set user_mfa_phone: %w", uniqueError(err))
+	}
+	return nil
+}
+
+func (m *mfa) Phone(ctx context.Context, userId uint64) (phone string, err error) {
+	var value sql.NullString
+	err = m.db.QueryRowContext(ctx, "SELECT phone FROM user_mfa_phone WHERE user_id = ?", userId).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err == nil {
+		phone, err = m.crypt.decrypt(value)
+	}
+	if err != nil {
+		return "", fmt.Errorf("find user_mfa_phone: %w", err)
+	}
+	return phone, nil
+}
+
+// SetCodes replaces recovery codes of the user
+func (m *mfa) SetCodes(ctx context.Context, userId uint64, codes []string) error {
+	return inTx(ctx, m.db, func(tx executor) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM users_mfa_code WHERE user_id = ?", userId); err != nil {
+			return fmt.Errorf("set users_mfa_code: %w", err)
+		}
+		for _, code := range codes {
+			value, err := m.crypt.encrypt(code)
+			if err != nil {
+				return fmt.Errorf("set users_mfa_code: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO users_mfa_code (user_id, code) VALUES(?,?)", userId, value); err != nil {
+				return fmt.Errorf("set users_mfa_code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *mfa) Codes(ctx context.Context, userId uint64) (codes []string, err error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT code FROM users_mfa_code WHERE user_id = ? ORDER BY id", userId)
+	if err != nil {
+		return nil, fmt.Errorf("find users_mfa_code: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("find users_mfa_code: %w", err)
+		}
+		code, err := m.crypt.decrypt(value)
+		if err != nil {
+			return nil, fmt.Errorf("find users_mfa_code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find users_mfa_code: %w", err)
+	}
+	return codes, nil
+}
+
+// Delete removes secret, phone and recovery codes of the user
+func (m *mfa) Delete(ctx context.Context, userId uint64) error {
+	return inTx(ctx, m.db, func(tx executor) error {
+		for _, table := range []string{"user_mfa_secret", "user_mfa_phone", "users_mfa_code"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userId); err != nil {
+				return fmt.Errorf("delete %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
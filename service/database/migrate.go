@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nori-io/auth/service/database/sqlScripts"
+)
+
+// Migrate creates missing tables and brings the tables created by older releases up to date:
+// it adds columns and indexes, widens the columns of encrypted values and fills the phone
+// blind indexes. Every step checks the schema first, so it is safe to run repeatedly.
+// Returns the applied changes
+func Migrate(ctx context.Context, db *sql.DB, cipher Cipher) (applied []string, err error) {
+	for _, script := range sqlScripts.Tables {
+		if _, err := db.ExecContext(ctx, script); err != nil {
+			return applied, fmt.Errorf("migrate create table: %w", err)
+		}
+	}
+
+	for _, col := range sqlScripts.AddedColumns {
+		exists, err := columnExists(ctx, db, col.Table, col.Name)
+		if err != nil {
+			return applied, err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.Table, col.Name, col.Definition)); err != nil {
+			return applied, fmt.Errorf("migrate add %s.%s: %w", col.Table, col.Name, err)
+		}
+		applied = append(applied, fmt.Sprintf("added column %s.%s", col.Table, col.Name))
+	}
+
+	for _, col := range sqlScripts.WidenedColumns {
+		var length sql.NullInt64
+		err := db.QueryRowContext(ctx, "SELECT CHARACTER_MAXIMUM_LENGTH FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+			col.Table, col.Name).Scan(&length)
+		if err != nil {
+			return applied, fmt.Errorf("migrate widen %s.%s: %w", col.Table, col.Name, err)
+		}
+		if length.Int64 >= int64(col.Length) {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", col.Table, col.Name, col.Definition)); err != nil {
+			return applied, fmt.Errorf("migrate widen %s.%s: %w", col.Table, col.Name, err)
+		}
+		applied = append(applied, fmt.Sprintf("widened column %s.%s", col.Table, col.Name))
+	}
+
+	c := crypt{cipher: cipher}
+	for _, col := range encryptedColumns {
+		if col.hash == "" {
+			continue
+		}
+		n, err := fillBlindIndex(ctx, db, c, col)
+		if err != nil {
+			return applied, fmt.Errorf("migrate fill %s.%s: %w", col.table, col.hash, err)
+		}
+		if n > 0 {
+			applied = append(applied, fmt.Sprintf("filled %d values of %s.%s", n, col.table, col.hash))
+		}
+	}
+
+	for _, col := range sqlScripts.NotNullColumns {
+		var nullable string
+		err := db.QueryRowContext(ctx, "SELECT IS_NULLABLE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+			col.Table, col.Name).Scan(&nullable)
+		if err != nil {
+			return applied, fmt.Errorf("migrate not null %s.%s: %w", col.Table, col.Name, err)
+		}
+		if nullable != "YES" {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", col.Table, col.Name, col.Definition)); err != nil {
+			return applied, fmt.Errorf("migrate not null %s.%s: %w", col.Table, col.Name, err)
+		}
+		applied = append(applied, fmt.Sprintf("made column %s.%s not null", col.Table, col.Name))
+	}
+
+	for _, index := range sqlScripts.Indexes {
+		var count int
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?",
+			index.Table, index.Name).Scan(&count)
+		if err != nil {
+			return applied, fmt.Errorf("migrate index %s.%s: %w", index.Table, index.Name, err)
+		}
+		query, change := "", ""
+		switch {
+		case index.Definition == "" && count > 0:
+			query, change = fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", index.Table, index.Name), "dropped"
+		case index.Definition != "" && count == 0:
+			query, change = fmt.Sprintf("ALTER TABLE %s ADD %s", index.Table, index.Definition), "added"
+		default:
+			continue
+		}
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return applied, fmt.Errorf("migrate index %s.%s: %w", index.Table, index.Name, err)
+		}
+		applied = append(applied, fmt.Sprintf("%s index %s.%s", change, index.Table, index.Name))
+	}
+
+	for _, fk := range sqlScripts.ForeignKeys {
+		var count int
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = ?",
+			fk.Table, fk.Name).Scan(&count)
+		if err != nil {
+			return applied, fmt.Errorf("migrate foreign key %s.%s: %w", fk.Table, fk.Name, err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD %s", fk.Table, fk.Definition)); err != nil {
+			return applied, fmt.Errorf("migrate foreign key %s.%s: %w", fk.Table, fk.Name, err)
+		}
+		applied = append(applied, fmt.Sprintf("added foreign key %s.%s", fk.Table, fk.Name))
+	}
+	return applied, nil
+}
+
+func columnExists(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	var name string
+	err := db.QueryRowContext(ctx, "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+		table, column).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("migrate column %s.%s: %w", table, column, err)
+	}
+	return true, nil
+}
+
+// fillBlindIndex computes the missing blind indexes of the encrypted column, the rows
+// are left from the releases which stored plaintext phones without hashes
+func fillBlindIndex(ctx context.Context, db *sql.DB, c crypt, col encryptedColumn) (filled int, err error) {
+	var lastId uint64
+	for {
+		type row struct {
+			id    uint64
+			value string
+		}
+		var batch []row
+
+		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT id, %s FROM %s WHERE id > ? AND %s IS NULL AND %s IS NOT NULL ORDER BY id LIMIT %d",
+			col.column, col.table, col.hash, col.column, reencryptBatch), lastId)
+		if err != nil {
+			return filled, err
+		}
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.value); err != nil {
+				rows.Close()
+				return filled, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return filled, err
+		}
+		if len(batch) == 0 {
+			return filled, nil
+		}
+
+		for _, r := range batch {
+			lastId = r.id
+			plaintext, err := c.decrypt(nullString(r.value))
+			if err != nil {
+				return filled, fmt.Errorf("id %d: %w", r.id, err)
+			}
+			query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ? AND %s IS NULL", col.table, col.hash, col.hash)
+			if _, err := db.ExecContext(ctx, query, c.index(plaintext), r.id); err != nil {
+				return filled, fmt.Errorf("id %d: %w", r.id, err)
+			}
+			filled++
+		}
+	}
+}
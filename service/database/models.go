@@ -5,54 +5,217 @@ import (
 )
 
 type AuthModel struct {
-	Id_Auth             uint64
-	UserId_Auth         uint64
+	Id_Auth              uint64
+	UserId_Auth          uint64
 	Phone_Auth           string
 	Email_Auth           string
 	Password_Auth        string
-	Salt_Auth             string
-	Created_Auth          time.Time
-	Updated_Auth          time.Time
-	IsEmailVerified_Auth  bool
-	IsPhoneVerified_Auth  bool
-
-	Id_Users       		 uint64
-	Kind_Users  int64
-	StatusId_Users       int64
-	Type_Users           string
-	Created_Users        time.Time
-	Updated_Users        time.Time
-	Mfa_type_Users          string
-
+	Salt_Auth            string
+	Created_Auth         time.Time
+	Updated_Auth         time.Time
+	IsEmailVerified_Auth bool
+	IsPhoneVerified_Auth bool
+	PasswordChanged_Auth time.Time
 
+	Id_Users       uint64
+	Kind_Users     string
+	StatusId_Users int64
+	Type_Users     string
+	Created_Users  time.Time
+	Updated_Users  time.Time
+	Mfa_type_Users string
+	// DeletionScheduled_Users is set when the user asked to delete the account
+	DeletionScheduled_Users *time.Time
 }
 
 type AuthenticationHistoryModel struct {
 	Id        int64
-	UserId    int64
+	UserId    uint64
 	LoggedIn  time.Time
 	Meta      string
 	LoggedOut time.Time
 	Secret    string
+	// SignedIn is when the user signed in with the credentials, the refreshed sessions keep it.
+	// It is zero in the records of the older releases
+	SignedIn time.Time
 }
 
 type AuthProvidersModel struct {
 	Provider        string
 	ProviderUserKey string
-	UserId          int64
+	UserId          uint64
 }
 
+const (
+	UserTypeUser  = "user"
+	UserTypeAdmin = "admin"
+	// UserTypeService is a machine identity, it has no auth and signs in only with its OAuth clients
+	UserTypeService = "service"
+)
+
+// users.status_id values
+const (
+	UserStatusActive    int64 = 0
+	UserStatusSuspended int64 = 1
+)
+
 type UsersModel struct {
-	Id            uint64
-	ProfileTypeId int64
-	StatusId      int64
-	Kind          string
-	Created       time.Time
-	Updated       time.Time
-	Email         string
+	Id       uint64
+	Kind     string
+	StatusId int64
+	Type     string
+	Created  time.Time
+	Updated  time.Time
+	MfaType  string
+	// Deleted is set when the user is soft deleted
+	Deleted *time.Time
+	// DeletionScheduled is the time the account is purged at
+	DeletionScheduled *time.Time
+}
+
+// MfaStateModel describes MFA enrollment of the user, secrets are not loaded
+type MfaStateModel struct {
+	UserId uint64
+	Type   string
+	Secret bool
+	Phone  string
+	Codes  int
+}
+
+type ConsentModel struct {
+	Id      uint64
+	UserId  uint64
+	Kind    string
+	Version string
+	Granted time.Time
+	Revoked *time.Time
+}
+
+// ProfileModel is the profile filled on sign up, Username is kept lowercased and is empty when not chosen
+type ProfileModel struct {
+	UserId      uint64
+	DisplayName string
+	Username    string
+	Locale      string
+	Timezone    string
+	Attributes  map[string]string
+	Created     time.Time
+	Updated     time.Time
+}
+
+// PasswordResetModel keeps sha256 of the reset token, the token itself is only mailed
+type PasswordResetModel struct {
+	Id        uint64
+	UserId    uint64
+	TokenHash string
+	Created   time.Time
+	Expires   time.Time
+	Used      *time.Time
+}
+
+type PasswordHistoryModel struct {
+	Id      uint64
+	UserId  uint64
+	Hash    string
+	Created time.Time
 }
 
 type UserStatusModel struct {
 	Id   int64
 	Name string
 }
+
+// UsersFilter selects users for List and Count, zero fields are not applied
+type UsersFilter struct {
+	Kind           string
+	Type           string
+	StatusId       int64
+	CreatedFrom    time.Time
+	CreatedTo      time.Time
+	IncludeDeleted bool
+	// DeletionDue selects users which deletion is scheduled before it
+	DeletionDue time.Time
+
+	// Cursor is the last id of the previous page, List ignores it when 0
+	Cursor uint64
+	// Limit is the page size, Count ignores it
+	Limit int
+}
+
+// SigningKeyModel is a token signing key of the native issuer. The key signs tokens
+// from Activates till Expires and is published until Retires
+type SigningKeyModel struct {
+	Id         uint64
+	Kid        string
+	Alg        string
+	PrivateKey string
+	Activates  time.Time
+	Expires    time.Time
+	Retires    time.Time
+}
+
+// OAuthClientModel is a registered OAuth client, the secret is kept as sha256
+type OAuthClientModel struct {
+	Id           uint64
+	ClientId     string
+	SecretHash   string
+	Name         string
+	RedirectURIs []string
+	// PostLogoutRedirectURIs are allowed after RP-initiated logout
+	PostLogoutRedirectURIs []string
+	GrantTypes             []string
+	Scopes                 []string
+	// OwnerId is the service account the client credentials grant issues tokens for
+	OwnerId uint64
+	// PublicKey is PEM of the key the client signs private_key_jwt assertions with
+	PublicKey string
+	Created   time.Time
+	Updated   time.Time
+}
+
+// RefreshTokenModel keeps sha256 of the refresh token issued to the client on behalf of the user
+type RefreshTokenModel struct {
+	Id        uint64
+	TokenHash string
+	ClientId  string
+	UserId    uint64
+	Scope     string
+	Created   time.Time
+	Expires   time.Time
+	Revoked   *time.Time
+}
+
+// OAuthCodeModel keeps sha256 of the authorization code and the PKCE challenge it must be redeemed with
+type OAuthCodeModel struct {
+	Id            uint64
+	CodeHash      string
+	ClientId      string
+	UserId        uint64
+	RedirectURI   string
+	Scope         string
+	CodeChallenge string
+	// Nonce of the OpenID Connect request, it is copied to the ID token
+	Nonce   string
+	Created time.Time
+	Expires time.Time
+	Used    *time.Time
+}
+
+// DeviceCodeModel is the device authorization request (RFC 8628), codes are kept as sha256,
+// UserId is set when the user approves the request
+type DeviceCodeModel struct {
+	Id             uint64
+	DeviceCodeHash string
+	UserCodeHash   string
+	ClientId       string
+	Scope          string
+	UserId         uint64
+	// Interval is the minimal polling interval in seconds
+	Interval   int
+	Created    time.Time
+	Expires    time.Time
+	LastPolled *time.Time
+	Approved   *time.Time
+	Denied     *time.Time
+	Used       *time.Time
+}
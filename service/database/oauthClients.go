@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const oauthClientColumns = "id, client_id, secret_hash, name, redirect_uris, post_logout_redirect_uris, grant_types, scopes, owner_id, public_key, created, updated"
+
+type oauthClients struct {
+	db executor
+}
+
+func (o *oauthClients) Create(ctx context.Context, model *OAuthClientModel) error {
+	res, err := o.db.ExecContext(ctx, "INSERT INTO oauth_clients (client_id, secret_hash, name, redirect_uris, post_logout_redirect_uris, grant_types, scopes, owner_id, public_key, created, updated) VALUES(?,?,?,?,?,?,?,?,?,?,?)",
+		model.ClientId, model.SecretHash, model.Name, strings.Join(model.RedirectURIs, " "), strings.Join(model.PostLogoutRedirectURIs, " "), strings.Join(model.GrantTypes, " "), strings.Join(model.Scopes, " "),
+		nullId(model.OwnerId), nullString(model.PublicKey), nullTime(model.Created), nullTime(model.Updated))
+	if err != nil {
+		return fmt.Errorf("insert oauth_clients: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert oauth_clients: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (o *oauthClients) Update(ctx context.Context, model *OAuthClientModel) error {
+	if model.Id == 0 {
+		return ErrEmptyModel
+	}
+	res, err := o.db.ExecContext(ctx, "UPDATE oauth_clients SET secret_hash = ?, name = ?, redirect_uris = ?, post_logout_redirect_uris = ?, grant_types = ?, scopes = ?, public_key = ?, updated = ? WHERE id = ?",
+		model.SecretHash, model.Name, strings.Join(model.RedirectURIs, " "), strings.Join(model.PostLogoutRedirectURIs, " "), strings.Join(model.GrantTypes, " "), strings.Join(model.Scopes, " "),
+		nullString(model.PublicKey), nullTime(model.Updated), model.Id)
+	if err != nil {
+		return fmt.Errorf("update oauth_clients: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update oauth_clients: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	// mysql counts only the changed rows, the client may have been updated with the same values
+	var id uint64
+	err = o.db.QueryRowContext(ctx, "SELECT id FROM oauth_clients WHERE id = ?", model.Id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("update oauth_clients: %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("update oauth_clients: %w", err)
+	}
+	return nil
+}
+
+func (o *oauthClients) Delete(ctx context.Context, id uint64) error {
+	_, err := o.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete oauth_clients: %w", err)
+	}
+	return nil
+}
+
+func (o *oauthClients) FindByClientID(ctx context.Context, clientId string) (model *OAuthClientModel, err error) {
+	row := o.db.QueryRowContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients WHERE client_id = ?", clientId)
+	model, err = scanOAuthClient(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find oauth_clients by client id: %w", err)
+	}
+	return model, nil
+}
+
+func (o *oauthClients) List(ctx context.Context) (models []OAuthClientModel, err error) {
+	rows, err := o.db.QueryContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("list oauth_clients: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		model, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list oauth_clients: %w", err)
+		}
+		models = append(models, *model)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list oauth_clients: %w", err)
+	}
+	return models, nil
+}
+
+func scanOAuthClient(row scanner) (*OAuthClientModel, error) {
+	var (
+		m                                            OAuthClientModel
+		redirectURIs, postLogout, grantTypes, scopes string
+		ownerId                                      sql.NullInt64
+		publicKey                                    sql.NullString
+		created, updated                             sql.NullTime
+	)
+	if err := row.Scan(&m.Id, &m.ClientId, &m.SecretHash, &m.Name, &redirectURIs, &postLogout, &grantTypes, &scopes,
+		&ownerId, &publicKey, &created, &updated); err != nil {
+		return nil, err
+	}
+	m.RedirectURIs = strings.Fields(redirectURIs)
+	m.PostLogoutRedirectURIs = strings.Fields(postLogout)
+	m.GrantTypes = strings.Fields(grantTypes)
+	m.Scopes = strings.Fields(scopes)
+	m.OwnerId = uint64(ownerId.Int64)
+	m.PublicKey = publicKey.String
+	m.Created = created.Time
+	m.Updated = updated.Time
+	return &m, nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type oauthCodes struct {
+	db executor
+}
+
+func (o *oauthCodes) Create(ctx context.Context, model *OAuthCodeModel) error {
+	res, err := o.db.ExecContext(ctx, "INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, created, expires) VALUES(?,?,?,?,?,?,?,?,?)",
+		model.CodeHash, model.ClientId, model.UserId, model.RedirectURI, model.Scope, model.CodeChallenge, model.Nonce, model.Created, model.Expires)
+	if err != nil {
+		return fmt.Errorf("insert oauth_codes: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert oauth_codes: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (o *oauthCodes) FindByCodeHash(ctx context.Context, codeHash string) (model *OAuthCodeModel, err error) {
+	var used sql.NullTime
+	model = &OAuthCodeModel{}
+
+	err = o.db.QueryRowContext(ctx, "SELECT id, code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, created, expires, used FROM oauth_codes WHERE code_hash = ?", codeHash).
+		Scan(&model.Id, &model.CodeHash, &model.ClientId, &model.UserId, &model.RedirectURI, &model.Scope, &model.CodeChallenge, &model.Nonce, &model.Created, &model.Expires, &used)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find oauth_codes by code: %w", err)
+	}
+	model.Used = timePtr(used)
+	return model, nil
+}
+
+// Use marks the code used, ErrNotFound is returned when it was already used
+func (o *oauthCodes) Use(ctx context.Context, id uint64) error {
+	res, err := o.db.ExecContext(ctx, "UPDATE oauth_codes SET used = ? WHERE id = ? AND used IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("use oauth_codes: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("use oauth_codes: %w", ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+type passwordHistory struct {
+	db executor
+}
+
+func (p *passwordHistory) Create(ctx context.Context, model *PasswordHistoryModel) error {
+	res, err := p.db.ExecContext(ctx, "INSERT INTO password_history (user_id, hash, created) VALUES(?,?,?)",
+		model.UserId, model.Hash, model.Created)
+	if err != nil {
+		return fmt.Errorf("insert password_history: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert password_history: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (p *passwordHistory) Recent(ctx context.Context, userId uint64, limit int) (models []PasswordHistoryModel, err error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT id, user_id, hash, created FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?", userId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("recent password_history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m PasswordHistoryModel
+		if err := rows.Scan(&m.Id, &m.UserId, &m.Hash, &m.Created); err != nil {
+			return nil, fmt.Errorf("recent password_history: %w", err)
+		}
+		models = append(models, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("recent password_history: %w", err)
+	}
+	return models, nil
+}
+
+func (p *passwordHistory) Prune(ctx context.Context, userId uint64, keep int) error {
+	// mysql doesn't allow LIMIT in a subquery of the same table, the derived table works around it
+	_, err := p.db.ExecContext(ctx, `DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+  SELECT id FROM (SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?) AS keep)`,
+		userId, userId, keep)
+	if err != nil {
+		return fmt.Errorf("prune password_history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type passwordResets struct {
+	db executor
+}
+
+func (p *passwordResets) Create(ctx context.Context, model *PasswordResetModel) error {
+	res, err := p.db.ExecContext(ctx, "INSERT INTO password_resets (user_id, token_hash, created, expires) VALUES(?,?,?,?)",
+		model.UserId, model.TokenHash, model.Created, model.Expires)
+	if err != nil {
+		return fmt.Errorf("insert password_resets: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert password_resets: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (p *passwordResets) FindByTokenHash(ctx context.Context, tokenHash string) (model *PasswordResetModel, err error) {
+	var used sql.NullTime
+	model = &PasswordResetModel{}
+
+	err = p.db.QueryRowContext(ctx, "SELECT id, user_id, token_hash, created, expires, used FROM password_resets WHERE token_hash = ?", tokenHash).
+		Scan(&model.Id, &model.UserId, &model.TokenHash, &model.Created, &model.Expires, &used)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find password_resets by token: %w", err)
+	}
+	model.Used = timePtr(used)
+	return model, nil
+}
+
+func (p *passwordResets) Use(ctx context.Context, id uint64) error {
+	res, err := p.db.ExecContext(ctx, "UPDATE password_resets SET used = ? WHERE id = ? AND used IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("use password_resets: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("use password_resets: %w", ErrNotFound)
+	}
+	return nil
+}
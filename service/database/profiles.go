@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type profiles struct {
+	db executor
+}
+
+const profileColumns = "user_id, display_name, username, locale, timezone, attributes, created, updated"
+
+func (p *profiles) Create(ctx context.Context, model *ProfileModel) error {
+	attributes, err := marshalAttributes(model.Attributes)
+	if err != nil {
+		return fmt.Errorf("insert user_profiles: %w", err)
+	}
+	_, err = p.db.ExecContext(ctx, "INSERT INTO user_profiles ("+profileColumns+") VALUES(?,?,?,?,?,?,?,?)",
+		model.UserId, model.DisplayName, nullString(model.Username), model.Locale, model.Timezone, attributes, model.Created, model.Updated)
+	if err != nil {
+		return fmt.Errorf("insert user_profiles: %w", uniqueError(err))
+	}
+	return nil
+}
+
+func (p *profiles) Update(ctx context.Context, model *ProfileModel) error {
+	attributes, err := marshalAttributes(model.Attributes)
+	if err != nil {
+		return fmt.Errorf("update user_profiles: %w", err)
+	}
+	_, err = p.db.ExecContext(ctx, "UPDATE user_profiles SET display_name = ?, username = ?, locale = ?, timezone = ?, attributes = ?, updated = ? WHERE user_id = ?",
+		model.DisplayName, nullString(model.Username), model.Locale, model.Timezone, attributes, model.Updated, model.UserId)
+	if err != nil {
+		return fmt.Errorf("update user_profiles: %w", uniqueError(err))
+	}
+	return nil
+}
+
+func (p *profiles) FindByUserID(ctx context.Context, userId uint64) (model *ProfileModel, err error) {
+	row := p.db.QueryRowContext(ctx, "SELECT "+profileColumns+" FROM user_profiles WHERE user_id = ?", userId)
+	model, err = scanProfile(row)
+	if err != nil {
+		return nil, fmt.Errorf("find user_profiles by user id: %w", err)
+	}
+	return model, nil
+}
+
+func (p *profiles) FindByUsername(ctx context.Context, username string) (model *ProfileModel, err error) {
+	row := p.db.QueryRowContext(ctx, "SELECT "+profileColumns+" FROM user_profiles WHERE username = ?", username)
+	model, err = scanProfile(row)
+	if err != nil {
+		return nil, fmt.Errorf("find user_profiles by username: %w", err)
+	}
+	return model, nil
+}
+
+func scanProfile(row scanner) (*ProfileModel, error) {
+	var (
+		m          ProfileModel
+		username   sql.NullString
+		attributes string
+	)
+	err := row.Scan(&m.UserId, &m.DisplayName, &username, &m.Locale, &m.Timezone, &attributes, &m.Created, &m.Updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Username = username.String
+	if attributes != "" {
+		if err := json.Unmarshal([]byte(attributes), &m.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+// marshalAttributes stores the attributes as a JSON object, nil is stored as an empty one
+func marshalAttributes(attributes map[string]string) (string, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(attributes)
+	return string(b), err
+}
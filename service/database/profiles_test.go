@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProfiles(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	var users []uint64
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		model := newAuthModel(email)
+		model.Phone_Auth = ""
+		if err := db.Auth().Create(ctx, model); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, model.UserId_Auth)
+	}
+
+	profile := &ProfileModel{
+		UserId:      users[0],
+		DisplayName: "John Doe",
+		Username:    "john.doe",
+		Locale:      "pt-BR",
+		Timezone:    "Europe/Berlin",
+		Attributes:  map[string]string{"company": "ACME"},
+		Created:     now,
+		Updated:     now,
+	}
+	if err := db.Profiles().Create(ctx, profile); err != nil {
+		t.Fatal(err)
+	}
+	found, err := db.Profiles().FindByUsername(ctx, "john.doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.UserId != users[0] || found.DisplayName != "John Doe" || found.Locale != "pt-BR" || found.Timezone != "Europe/Berlin" {
+		t.Errorf("profile = %+v", found)
+	}
+	if found.Attributes["company"] != "ACME" || !found.Created.Equal(now) {
+		t.Errorf("attributes = %v, created = %v", found.Attributes, found.Created)
+	}
+
+	// profiles without username don't collide
+	for _, id := range users[1:] {
+		if err := db.Profiles().Create(ctx, &ProfileModel{UserId: id, Created: now, Updated: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Profiles().Create(ctx, &ProfileModel{UserId: users[1], Username: "john.doe", Created: now, Updated: now}); err == nil {
+		t.Error("taken username is accepted")
+	}
+
+	empty, err := db.Profiles().FindByUserID(ctx, users[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Username != "" || len(empty.Attributes) != 0 {
+		t.Errorf("empty profile = %+v", empty)
+	}
+	if _, err := db.Profiles().FindByUserID(ctx, 1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// encrypted column, hash is the blind index column of it if any
+type encryptedColumn struct {
+	table, column, hash string
+}
+
+var encryptedColumns = []encryptedColumn{
+	{table: "auth", column: "phone", hash: "phone_hash"},
+	{table: "user_mfa_phone", column: "phone", hash: "phone_hash"},
+	{table: "user_mfa_secret", column: "secret"},
+	{table: "users_mfa_code", column: "code"},
+	{table: "signing_keys", column: "private_key"},
+}
+
+const reencryptBatch = 500
+
+// Reencrypt rewrites all encrypted values that are not encrypted with the active key of cipher,
+// plaintext values left from installations without cipher are encrypted as well.
+// Rows are processed in small batches and every row is updated only if it wasn't changed meanwhile,
+// so it is safe to run while the service is serving requests. Returns number of updated rows
+func Reencrypt(ctx context.Context, db *sql.DB, cipher Cipher) (updated int, err error) {
+	c := crypt{cipher: cipher}
+	for _, col := range encryptedColumns {
+		n, err := reencryptColumn(ctx, db, c, col)
+		updated += n
+		if err != nil {
+			return updated, fmt.Errorf("reencrypt %s.%s: %w", col.table, col.column, err)
+		}
+	}
+	return updated, nil
+}
+
+func reencryptColumn(ctx context.Context, db *sql.DB, c crypt, col encryptedColumn) (updated int, err error) {
+	var lastId uint64
+	for {
+		type row struct {
+			id    uint64
+			value string
+		}
+		var batch []row
+
+		rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT id, %s FROM %s WHERE id > ? AND %s IS NOT NULL ORDER BY id LIMIT %d",
+			col.column, col.table, col.column, reencryptBatch), lastId)
+		if err != nil {
+			return updated, err
+		}
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.value); err != nil {
+				rows.Close()
+				return updated, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		for _, r := range batch {
+			lastId = r.id
+			if !c.stale(r.value) {
+				continue
+			}
+			plaintext, err := c.decrypt(nullString(r.value))
+			if err != nil {
+				return updated, fmt.Errorf("id %d: %w", r.id, err)
+			}
+			value, err := c.encrypt(plaintext)
+			if err != nil {
+				return updated, fmt.Errorf("id %d: %w", r.id, err)
+			}
+
+			query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ? AND %s = ?", col.table, col.column, col.column)
+			args := []interface{}{value, r.id, r.value}
+			if col.hash != "" {
+				query = fmt.Sprintf("UPDATE %s SET %s = ?, %s = ? WHERE id = ? AND %s = ?", col.table, col.column, col.hash, col.column)
+				args = []interface{}{value, c.index(plaintext), r.id, r.value}
+			}
+			res, err := db.ExecContext(ctx, query, args...)
+			if err != nil {
+				return updated, fmt.Errorf("id %d: %w", r.id, err)
+			}
+			if n, err := res.RowsAffected(); err == nil && n > 0 {
+				updated++
+			}
+		}
+	}
+}
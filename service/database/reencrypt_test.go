@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCipher "encrypts" values with the key name, it is enough to tell the keys apart
+type testCipher struct {
+	key string
+}
+
+func (c testCipher) Encrypt(plaintext string) (string, error) {
+	return "enc." + c.key + "." + base64.RawURLEncoding.EncodeToString([]byte(plaintext)), nil
+}
+
+func (c testCipher) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, "enc.") {
+		return ciphertext, nil
+	}
+	parts := strings.Split(ciphertext, ".")
+	plaintext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	return string(plaintext), err
+}
+
+func (c testCipher) Stale(ciphertext string) bool {
+	return ciphertext != "" && !strings.HasPrefix(ciphertext, "enc."+c.key+".")
+}
+
+func (c testCipher) BlindIndex(value string) string {
+	sum := sha256.Sum256([]byte("index:" + value))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestReencrypt(t *testing.T) {
+	ctx := context.Background()
+	sqlDB := openSQLite(t)
+
+	// the phone is stored before the encryption was enabled
+	model := newAuthModel("user@example.com")
+	if err := New(sqlDB, nil).Auth().Create(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+	old := testCipher{key: "old"}
+	secret, _ := old.Encrypt("JBSWY3DPEHPK3PXP")
+	code, _ := old.Encrypt("recovery")
+	now := time.Now()
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"INSERT INTO user_mfa_secret (user_id, secret) VALUES(?,?)", []interface{}{model.UserId_Auth, secret}},
+		{"INSERT INTO users_mfa_code (user_id, code) VALUES(?,?)", []interface{}{model.UserId_Auth, code}},
+		{"INSERT INTO signing_keys (kid, alg, private_key, activates, expires, retires) VALUES(?,?,?,?,?,?)", []interface{}{"kid", "ES256", "private", now, now, now}},
+	} {
+		if _, err := sqlDB.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current := testCipher{key: "new"}
+	updated, err := Reencrypt(ctx, sqlDB, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 4 {
+		t.Errorf("updated = %d, want 4", updated)
+	}
+	if updated, err := Reencrypt(ctx, sqlDB, current); err != nil || updated != 0 {
+		t.Errorf("second run updated = %d, %v", updated, err)
+	}
+
+	for _, query := range []string{
+		"SELECT phone FROM auth",
+		"SELECT secret FROM user_mfa_secret",
+		"SELECT code FROM users_mfa_code",
+		"SELECT private_key FROM signing_keys",
+	} {
+		var value string
+		if err := sqlDB.QueryRow(query).Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		if current.Stale(value) {
+			t.Errorf("%s: %q is not encrypted with the active key", query, value)
+		}
+	}
+
+	db := New(sqlDB, current)
+	// the blind index is rebuilt with the plaintext phone
+	found, err := db.Auth().FindByPhone(ctx, model.Phone_Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Phone_Auth != model.Phone_Auth {
+		t.Errorf("phone = %q, want %q", found.Phone_Auth, model.Phone_Auth)
+	}
+	if value, err := db.Mfa().Secret(ctx, model.UserId_Auth); err != nil || value != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("secret = %q, %v", value, err)
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type refreshTokens struct {
+	db executor
+}
+
+func (r *refreshTokens) Create(ctx context.Context, model *RefreshTokenModel) error {
+	res, err := r.db.ExecContext(ctx, "INSERT INTO oauth_refresh_tokens (token_hash, client_id, user_id, scope, created, expires) VALUES(?,?,?,?,?,?)",
+		model.TokenHash, model.ClientId, model.UserId, model.Scope, model.Created, model.Expires)
+	if err != nil {
+		return fmt.Errorf("insert oauth_refresh_tokens: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert oauth_refresh_tokens: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (r *refreshTokens) FindByTokenHash(ctx context.Context, tokenHash string) (model *RefreshTokenModel, err error) {
+	var revoked sql.NullTime
+	model = &RefreshTokenModel{}
+
+	err = r.db.QueryRowContext(ctx, "SELECT id, token_hash, client_id, user_id, scope, created, expires, revoked FROM oauth_refresh_tokens WHERE token_hash = ?", tokenHash).
+		Scan(&model.Id, &model.TokenHash, &model.ClientId, &model.UserId, &model.Scope, &model.Created, &model.Expires, &revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find oauth_refresh_tokens by token: %w", err)
+	}
+	model.Revoked = timePtr(revoked)
+	return model, nil
+}
+
+// Revoke marks the token revoked, ErrNotFound is returned when it was already revoked
+func (r *refreshTokens) Revoke(ctx context.Context, id uint64) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE oauth_refresh_tokens SET revoked = ? WHERE id = ? AND revoked IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("revoke oauth_refresh_tokens: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("revoke oauth_refresh_tokens: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r *refreshTokens) RevokeByUser(ctx context.Context, userId uint64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE oauth_refresh_tokens SET revoked = ? WHERE user_id = ? AND revoked IS NULL", time.Now(), userId)
+	if err != nil {
+		return fmt.Errorf("revoke oauth_refresh_tokens by user: %w", err)
+	}
+	return nil
+}
+
+func (r *refreshTokens) RevokeByClient(ctx context.Context, userId uint64, clientId string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE oauth_refresh_tokens SET revoked = ? WHERE user_id = ? AND client_id = ? AND revoked IS NULL", time.Now(), userId, clientId)
+	if err != nil {
+		return fmt.Errorf("revoke oauth_refresh_tokens by client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type revokedTokens struct {
+	db executor
+}
+
+func (r *revokedTokens) Revoke(ctx context.Context, jti string, expires time.Time) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO revoked_tokens (jti, expires) VALUES(?,?) ON DUPLICATE KEY UPDATE expires = GREATEST(expires, VALUES(expires))",
+		jti, expires)
+	if err != nil {
+		return fmt.Errorf("insert revoked_tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *revokedTokens) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var one int
+	err := r.db.QueryRowContext(ctx, "SELECT 1 FROM revoked_tokens WHERE jti = ? AND expires > ?", jti, time.Now()).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("find revoked_tokens: %w", err)
+	}
+	return true, nil
+}
+
+func (r *revokedTokens) Purge(ctx context.Context, before time.Time) (purged int64, err error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires <= ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("purge revoked_tokens: %w", err)
+	}
+	purged, _ = res.RowsAffected()
+	return purged, nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const signingKeyColumns = "id, kid, alg, private_key, activates, expires, retires"
+
+type signingKeys struct {
+	db    executor
+	crypt crypt
+}
+
+func (s *signingKeys) Create(ctx context.Context, model *SigningKeyModel) error {
+	privateKey, err := s.crypt.encrypt(model.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("insert signing_keys: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx, "INSERT INTO signing_keys (kid, alg, private_key, activates, expires, retires) VALUES(?,?,?,?,?,?)",
+		model.Kid, model.Alg, privateKey, model.Activates, model.Expires, model.Retires)
+	if err != nil {
+		return fmt.Errorf("insert signing_keys: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert signing_keys: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
+}
+
+func (s *signingKeys) Delete(ctx context.Context, id uint64) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM signing_keys WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete signing_keys: %w", err)
+	}
+	return nil
+}
+
+// List returns all keys ordered by activation time
+func (s *signingKeys) List(ctx context.Context) (models []SigningKeyModel, err error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+signingKeyColumns+" FROM signing_keys ORDER BY activates, id")
+	if err != nil {
+		return nil, fmt.Errorf("list signing_keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m SigningKeyModel
+		var privateKey sql.NullString
+		if err := rows.Scan(&m.Id, &m.Kid, &m.Alg, &privateKey, &m.Activates, &m.Expires, &m.Retires); err != nil {
+			return nil, fmt.Errorf("list signing_keys: %w", err)
+		}
+		if m.PrivateKey, err = s.crypt.decrypt(privateKey); err != nil {
+			return nil, fmt.Errorf("list signing_keys: %w", err)
+		}
+		models = append(models, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list signing_keys: %w", err)
+	}
+	return models, nil
+}
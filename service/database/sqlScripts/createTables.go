@@ -9,7 +9,11 @@ const (
   created DATETIME NULL,
   updated DATETIME NULL,
   mfa_type VARCHAR(8) NOT NULL,
-  PRIMARY KEY (id))
+  deleted DATETIME NULL,
+  deletion_scheduled DATETIME NULL,
+  PRIMARY KEY (id),
+  INDEX deleted_idx (deleted ASC),
+  INDEX deletion_scheduled_idx (deletion_scheduled ASC))
 ENGINE = InnoDB;
 `
 	CreateTableAuthenticationHistory = ` 
@@ -19,7 +23,10 @@ CREATE TABLE IF NOT EXISTS authentication_history (
   logged_in DATETIME NOT NULL,
   meta VARCHAR(255) NOT NULL,
   logged_out DATETIME NULL,
+  secret VARCHAR(255) NULL,
+  signed_in DATETIME NULL,
   INDEX user_id_idx (user_id ASC),
+  UNIQUE INDEX secret_unique (secret ASC),
   PRIMARY KEY (id),
   CONSTRAINT authentication_history_user_id
     FOREIGN KEY (user_id)
@@ -32,17 +39,19 @@ ENGINE = InnoDB;
 CREATE TABLE IF NOT EXISTS auth (
   id INT UNSIGNED NOT NULL AUTO_INCREMENT,
   user_id INT UNSIGNED NOT NULL,
-  phone VARCHAR(45) NULL,
+  phone VARCHAR(255) NULL,
+  phone_hash CHAR(64) NULL,
   email VARCHAR(255) NULL,
-  password VARCHAR(65) NOT NULL,
+  password VARCHAR(255) NOT NULL,
   salt VARCHAR(65) NOT NULL,
   created DATETIME NULL,
   updated DATETIME NULL,
   is_email_verified TINYINT(1) NOT NULL DEFAULT 0,
   is_phone_verified TINYINT(1) NOT NULL DEFAULT 0,
+  password_changed DATETIME NULL,
   PRIMARY KEY (id),
   INDEX user_id_idx (user_id ASC),
-  UNIQUE INDEX phone_unique (phone ASC),
+  UNIQUE INDEX phone_hash_unique (phone_hash ASC),
   UNIQUE INDEX email_unique (email ASC),
   UNIQUE INDEX user_id_unique (user_id ASC),
   CONSTRAINT auth_user_id_fk
@@ -56,7 +65,7 @@ ENGINE = InnoDB;
 CREATE TABLE IF NOT EXISTS user_mfa_secret (
   id INT UNSIGNED NOT NULL AUTO_INCREMENT,
   user_id INT UNSIGNED NOT NULL,
-  secret VARCHAR(255) NOT NULL,
+  secret VARCHAR(512) NOT NULL,
   PRIMARY KEY (id),
   UNIQUE INDEX user_id_UNIQUE (user_id ASC),
   INDEX user_id_idx (user_id ASC),
@@ -72,10 +81,11 @@ ENGINE = InnoDB;
 CREATE TABLE IF NOT EXISTS user_mfa_phone (
   id INT UNSIGNED NOT NULL AUTO_INCREMENT,
   user_id INT UNSIGNED NOT NULL,
-  phone VARCHAR(45) NOT NULL,
+  phone VARCHAR(255) NOT NULL,
+  phone_hash CHAR(64) NOT NULL,
   PRIMARY KEY (id),
   UNIQUE INDEX user_id_UNIQUE (user_id ASC),
-  UNIQUE INDEX phone_UNIQUE (phone ASC),
+  UNIQUE INDEX phone_hash_UNIQUE (phone_hash ASC),
   INDEX user_id_idx (user_id ASC),
   CONSTRAINT user_mfa_phone_user_id
     FOREIGN KEY (user_id)
@@ -88,7 +98,7 @@ ENGINE = InnoDB;
 CREATE TABLE IF NOT EXISTS users_mfa_code (
   id INT UNSIGNED NOT NULL AUTO_INCREMENT,
   user_id INT UNSIGNED NOT NULL,
-  code VARCHAR(45) NOT NULL,
+  code VARCHAR(255) NOT NULL,
   PRIMARY KEY (id),
   INDEX user_id_idx (user_id ASC),
   CONSTRAINT users_mfa_code_user_id_fk
@@ -111,5 +121,188 @@ CREATE TABLE IF NOT EXISTS auth_providers (
     ON DELETE CASCADE
     ON UPDATE CASCADE)
 ENGINE = InnoDB;
+`
+	CreateTableUserConsents = `
+CREATE TABLE IF NOT EXISTS user_consents (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id INT UNSIGNED NOT NULL,
+  kind VARCHAR(64) NOT NULL,
+  version VARCHAR(255) NOT NULL,
+  granted DATETIME NOT NULL,
+  revoked DATETIME NULL,
+  PRIMARY KEY (id),
+  INDEX user_id_idx (user_id ASC),
+  CONSTRAINT user_consents_user_id_fk
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+`
+	CreateTableUserProfiles = `
+CREATE TABLE IF NOT EXISTS user_profiles (
+  user_id INT UNSIGNED NOT NULL,
+  display_name VARCHAR(255) NOT NULL DEFAULT '',
+  username VARCHAR(64) NULL,
+  locale VARCHAR(35) NOT NULL DEFAULT '',
+  timezone VARCHAR(64) NOT NULL DEFAULT '',
+  attributes TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  updated DATETIME NOT NULL,
+  PRIMARY KEY (user_id),
+  UNIQUE INDEX username_unique (username ASC),
+  CONSTRAINT user_profiles_user_id_fk
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+`
+	CreateTablePasswordResets = `
+CREATE TABLE IF NOT EXISTS password_resets (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id INT UNSIGNED NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  used DATETIME NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX token_hash_unique (token_hash ASC),
+  INDEX user_id_idx (user_id ASC),
+  CONSTRAINT password_resets_user_id_fk
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+`
+	CreateTablePasswordHistory = `
+CREATE TABLE IF NOT EXISTS password_history (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id INT UNSIGNED NOT NULL,
+  hash VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  INDEX user_id_idx (user_id ASC),
+  CONSTRAINT password_history_user_id_fk
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+`
+	CreateTableSigningKeys = `
+CREATE TABLE IF NOT EXISTS signing_keys (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  kid VARCHAR(64) NOT NULL,
+  alg VARCHAR(16) NOT NULL,
+  private_key TEXT NOT NULL,
+  activates DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  retires DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX kid_unique (kid ASC))
+ENGINE = InnoDB;
+`
+	CreateTableRevokedTokens = `
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+  jti VARCHAR(255) NOT NULL,
+  expires DATETIME NOT NULL,
+  PRIMARY KEY (jti),
+  INDEX expires_idx (expires ASC))
+ENGINE = InnoDB;
+`
+	CreateTableOAuthClients = `
+CREATE TABLE IF NOT EXISTS oauth_clients (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  client_id VARCHAR(64) NOT NULL,
+  secret_hash CHAR(64) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  redirect_uris TEXT NOT NULL,
+  post_logout_redirect_uris TEXT NOT NULL,
+  grant_types VARCHAR(255) NOT NULL,
+  scopes VARCHAR(1024) NOT NULL,
+  owner_id INT UNSIGNED NULL,
+  public_key TEXT NULL,
+  created DATETIME NULL,
+  updated DATETIME NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX client_id_unique (client_id ASC),
+  INDEX owner_id_idx (owner_id ASC),
+  CONSTRAINT oauth_clients_owner_id_fk
+    FOREIGN KEY (owner_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+`
+	CreateTableOAuthRefreshTokens = `
+CREATE TABLE IF NOT EXISTS oauth_refresh_tokens (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  token_hash CHAR(64) NOT NULL,
+  client_id VARCHAR(64) NOT NULL,
+  user_id INT UNSIGNED NOT NULL,
+  scope VARCHAR(1024) NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  revoked DATETIME NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX token_hash_unique (token_hash ASC),
+  INDEX user_id_idx (user_id ASC),
+  CONSTRAINT oauth_refresh_tokens_user_id_fk
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+`
+	CreateTableOAuthCodes = `
+CREATE TABLE IF NOT EXISTS oauth_codes (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  code_hash CHAR(64) NOT NULL,
+  client_id VARCHAR(64) NOT NULL,
+  user_id INT UNSIGNED NOT NULL,
+  redirect_uri VARCHAR(1024) NOT NULL,
+  scope VARCHAR(1024) NOT NULL,
+  code_challenge VARCHAR(128) NOT NULL,
+  nonce VARCHAR(255) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  used DATETIME NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX code_hash_unique (code_hash ASC),
+  INDEX user_id_idx (user_id ASC),
+  CONSTRAINT oauth_codes_user_id_fk
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+`
+	CreateTableOAuthDeviceCodes = `
+CREATE TABLE IF NOT EXISTS oauth_device_codes (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  device_code_hash CHAR(64) NOT NULL,
+  user_code_hash CHAR(64) NOT NULL,
+  client_id VARCHAR(64) NOT NULL,
+  scope VARCHAR(1024) NOT NULL,
+  user_id INT UNSIGNED NULL,
+  poll_interval INT UNSIGNED NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  last_polled DATETIME NULL,
+  approved DATETIME NULL,
+  denied DATETIME NULL,
+  used DATETIME NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX device_code_hash_unique (device_code_hash ASC),
+  UNIQUE INDEX user_code_hash_unique (user_code_hash ASC),
+  INDEX expires_idx (expires ASC),
+  CONSTRAINT oauth_device_codes_user_id_fk
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
 `
 )
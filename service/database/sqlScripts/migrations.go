@@ -0,0 +1,88 @@
+package sqlScripts
+
+// Tables are the create scripts in the order of the foreign keys
+var Tables = []string{
+	CreateTableUsers,
+	CreateTableAuth,
+	CreateTableAuthProviders,
+	CreateTableAuthenticationHistory,
+	CreateTableUserMfaPhone,
+	CreateTableUsersMfaCode,
+	CreateTableUserMfaSecret,
+	CreateTableUserConsents,
+	CreateTableUserProfiles,
+	CreateTablePasswordResets,
+	CreateTablePasswordHistory,
+	CreateTableSigningKeys,
+	CreateTableRevokedTokens,
+	CreateTableOAuthClients,
+	CreateTableOAuthRefreshTokens,
+	CreateTableOAuthCodes,
+	CreateTableOAuthDeviceCodes,
+}
+
+// Column is the column definition of the table, Length is the minimal length of the widened columns
+type Column struct {
+	Table      string
+	Name       string
+	Definition string
+	Length     int
+}
+
+// AddedColumns were added to the tables after their first release
+var AddedColumns = []Column{
+	{Table: "users", Name: "deleted", Definition: "DATETIME NULL"},
+	{Table: "users", Name: "deletion_scheduled", Definition: "DATETIME NULL"},
+	{Table: "authentication_history", Name: "secret", Definition: "VARCHAR(255) NULL"},
+	{Table: "authentication_history", Name: "signed_in", Definition: "DATETIME NULL"},
+	{Table: "auth", Name: "phone_hash", Definition: "CHAR(64) NULL"},
+	{Table: "auth", Name: "password_changed", Definition: "DATETIME NULL"},
+	// it is made NOT NULL by NotNullColumns when the hashes are filled
+	{Table: "user_mfa_phone", Name: "phone_hash", Definition: "CHAR(64) NULL"},
+	{Table: "oauth_clients", Name: "post_logout_redirect_uris", Definition: "TEXT NOT NULL"},
+	{Table: "oauth_clients", Name: "owner_id", Definition: "INT UNSIGNED NULL"},
+	{Table: "oauth_clients", Name: "public_key", Definition: "TEXT NULL"},
+	{Table: "oauth_codes", Name: "nonce", Definition: "VARCHAR(255) NOT NULL DEFAULT ''"},
+}
+
+// WidenedColumns were too short for encrypted values and the imported password hashes
+var WidenedColumns = []Column{
+	{Table: "auth", Name: "phone", Definition: "VARCHAR(255) NULL", Length: 255},
+	{Table: "auth", Name: "password", Definition: "VARCHAR(255) NOT NULL", Length: 255},
+	{Table: "user_mfa_phone", Name: "phone", Definition: "VARCHAR(255) NOT NULL", Length: 255},
+	{Table: "user_mfa_secret", Name: "secret", Definition: "VARCHAR(512) NOT NULL", Length: 512},
+	{Table: "users_mfa_code", Name: "code", Definition: "VARCHAR(255) NOT NULL", Length: 255},
+}
+
+// NotNullColumns are made NOT NULL after they are filled by the migration
+var NotNullColumns = []Column{
+	{Table: "user_mfa_phone", Name: "phone_hash", Definition: "CHAR(64) NOT NULL"},
+}
+
+// Index is the index or the foreign key of the table, empty Definition drops it
+type Index struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+// Indexes were added or dropped after the first release of the tables
+var Indexes = []Index{
+	{Table: "users", Name: "deleted_idx", Definition: "INDEX deleted_idx (deleted ASC)"},
+	{Table: "users", Name: "deletion_scheduled_idx", Definition: "INDEX deletion_scheduled_idx (deletion_scheduled ASC)"},
+	{Table: "authentication_history", Name: "secret_unique", Definition: "UNIQUE INDEX secret_unique (secret ASC)"},
+	{Table: "auth", Name: "phone_unique"},
+	{Table: "auth", Name: "phone_hash_unique", Definition: "UNIQUE INDEX phone_hash_unique (phone_hash ASC)"},
+	{Table: "user_mfa_phone", Name: "phone_UNIQUE"},
+	{Table: "user_mfa_phone", Name: "phone_hash_UNIQUE", Definition: "UNIQUE INDEX phone_hash_UNIQUE (phone_hash ASC)"},
+	{Table: "oauth_clients", Name: "owner_id_idx", Definition: "INDEX owner_id_idx (owner_id ASC)"},
+}
+
+// ForeignKeys were added after the first release of the tables
+var ForeignKeys = []Index{
+	{Table: "oauth_clients", Name: "oauth_clients_owner_id_fk", Definition: `CONSTRAINT oauth_clients_owner_id_fk
+    FOREIGN KEY (owner_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+    ON UPDATE CASCADE`},
+}
@@ -1,26 +1,220 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
+const usersColumns = "id, kind, status_id, type, created, updated, mfa_type, deleted, deletion_scheduled"
+
 type users struct {
-	db *sql.DB
+	db executor
 }
 
-func (u *users) Create(model *UsersModel) error {
-	_, err := u.db.Exec("INSERT INTO users (profile_type_id, status_id, kind, created, updated, email) VALUES(?,?,?,?,?,?)",
-		model.ProfileTypeId, model.StatusId, model.Kind, model.Created, model.Updated, model.Email)
-	return err
+func (u *users) Create(ctx context.Context, model *UsersModel) error {
+	res, err := u.db.ExecContext(ctx, "INSERT INTO users (kind, status_id, type, created, updated, mfa_type) VALUES(?,?,?,?,?,?)",
+		model.Kind, model.StatusId, model.Type, nullTime(model.Created), nullTime(model.Updated), model.MfaType)
+	if err != nil {
+		return fmt.Errorf("insert users: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("insert users: %w", err)
+	}
+	model.Id = uint64(id)
+	return nil
 }
 
-func (u *users) Update(model *UsersModel) error {
+func (u *users) Update(ctx context.Context, model *UsersModel) error {
 	if model.Id == 0 {
-		return errors.New("Empty model")
+		return ErrEmptyModel
+	}
+	_, err := u.db.ExecContext(ctx, "UPDATE users SET kind = ?, status_id = ?, type = ?, updated = ?, mfa_type = ? WHERE id = ?",
+		model.Kind, model.StatusId, model.Type, nullTime(model.Updated), model.MfaType, model.Id)
+	if err != nil {
+		return fmt.Errorf("update users: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the user with mfa rows, other tables are cleaned by cascade
+func (u *users) Delete(ctx context.Context, id uint64) error {
+	return inTx(ctx, u.db, func(tx executor) error {
+		for _, table := range []string{"user_mfa_secret", "user_mfa_phone", "users_mfa_code"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", id); err != nil {
+				return fmt.Errorf("delete %s: %w", table, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id); err != nil {
+			return fmt.Errorf("delete users: %w", err)
+		}
+		return nil
+	})
+}
+
+func (u *users) ScheduleDeletion(ctx context.Context, id uint64, at time.Time) error {
+	_, err := u.db.ExecContext(ctx, "UPDATE users SET deletion_scheduled = ? WHERE id = ?", at, id)
+	if err != nil {
+		return fmt.Errorf("schedule users deletion: %w", err)
+	}
+	return nil
+}
+
+func (u *users) CancelDeletion(ctx context.Context, id uint64) error {
+	_, err := u.db.ExecContext(ctx, "UPDATE users SET deletion_scheduled = NULL WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("cancel users deletion: %w", err)
+	}
+	return nil
+}
+
+func (u *users) SoftDelete(ctx context.Context, id uint64) error {
+	res, err := u.db.ExecContext(ctx, "UPDATE users SET deleted = ? WHERE id = ? AND deleted IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("soft delete users: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("soft delete users: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (u *users) FindByID(ctx context.Context, id uint64) (model *UsersModel, err error) {
+	row := u.db.QueryRowContext(ctx, "SELECT "+usersColumns+" FROM users WHERE id = ?", id)
+	model, err = scanUsers(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find users by id: %w", err)
+	}
+	return model, nil
+}
+
+// List returns users ordered by id and the cursor of the next page, which is 0 on the last page
+func (u *users) List(ctx context.Context, filter UsersFilter) (models []UsersModel, next uint64, err error) {
+	where, args := filter.where()
+	if filter.Cursor != 0 {
+		where += " AND id > ?"
+		args = append(args, filter.Cursor)
+	}
+	limit := filter.limit()
+	args = append(args, limit+1)
+
+	rows, err := u.db.QueryContext(ctx, "SELECT "+usersColumns+" FROM users WHERE "+where+" ORDER BY id LIMIT ?", args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		model, err := scanUsers(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("list users: %w", err)
+		}
+		models = append(models, *model)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("list users: %w", err)
+	}
+
+	if len(models) > limit {
+		models = models[:limit]
+		next = models[limit-1].Id
+	}
+	return models, next, nil
+}
+
+func (u *users) Count(ctx context.Context, filter UsersFilter) (count uint64, err error) {
+	where, args := filter.where()
+	err = u.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count users: %w", err)
+	}
+	return count, nil
+}
+
+func scanUsers(row scanner) (*UsersModel, error) {
+	var (
+		m                         UsersModel
+		created, updated, deleted sql.NullTime
+		deletionScheduled         sql.NullTime
+	)
+	err := row.Scan(&m.Id, &m.Kind, &m.StatusId, &m.Type, &created, &updated, &m.MfaType, &deleted, &deletionScheduled)
+	if err != nil {
+		return nil, err
+	}
+	m.Created = created.Time
+	m.Updated = updated.Time
+	m.Deleted = timePtr(deleted)
+	m.DeletionScheduled = timePtr(deletionScheduled)
+	return &m, nil
+}
+
+const defaultListLimit = 50
+
+func (f UsersFilter) limit() int {
+	if f.Limit <= 0 {
+		return defaultListLimit
 	}
-	_, err := u.db.Exec("UPDATE users SET profile_type_id = ?, status_id = ?, kind = ?, created = ? updated = ? email =? WHERE id = ? ",
-		model.ProfileTypeId, model.StatusId, model.Kind, model.Created, model.Updated, model.Email, model.Id)
-	return err
+	return f.Limit
 }
 
+func (f UsersFilter) where() (string, []interface{}) {
+	conds := []string{"1 = 1"}
+	var args []interface{}
+
+	if f.Kind != "" {
+		conds = append(conds, "kind = ?")
+		args = append(args, f.Kind)
+	}
+	if f.Type != "" {
+		conds = append(conds, "type = ?")
+		args = append(args, f.Type)
+	}
+	if f.StatusId != 0 {
+		conds = append(conds, "status_id = ?")
+		args = append(args, f.StatusId)
+	}
+	if !f.CreatedFrom.IsZero() {
+		conds = append(conds, "created >= ?")
+		args = append(args, f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		conds = append(conds, "created < ?")
+		args = append(args, f.CreatedTo)
+	}
+	if !f.IncludeDeleted {
+		conds = append(conds, "deleted IS NULL")
+	}
+	if !f.DeletionDue.IsZero() {
+		conds = append(conds, "deletion_scheduled <= ?")
+		args = append(args, f.DeletionDue)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// match reports whether model passes the filter, it mirrors where for the memory database
+func (f UsersFilter) match(model UsersModel) bool {
+	switch {
+	case f.Kind != "" && model.Kind != f.Kind:
+		return false
+	case f.Type != "" && model.Type != f.Type:
+		return false
+	case f.StatusId != 0 && model.StatusId != f.StatusId:
+		return false
+	case !f.CreatedFrom.IsZero() && model.Created.Before(f.CreatedFrom):
+		return false
+	case !f.CreatedTo.IsZero() && !model.Created.Before(f.CreatedTo):
+		return false
+	case !f.IncludeDeleted && model.Deleted != nil:
+		return false
+	case !f.DeletionDue.IsZero() && (model.DeletionScheduled == nil || model.DeletionScheduled.After(f.DeletionDue)):
+		return false
+	}
+	return true
+}
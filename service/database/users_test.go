@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUsersList(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	for i := 0; i < 5; i++ {
+		model := &UsersModel{Type: UserTypeUser, Created: time.Now(), Updated: time.Now()}
+		if i%2 == 1 {
+			model.Type = UserTypeAdmin
+		}
+		if err := db.Users().Create(ctx, model); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []uint64
+	filter := UsersFilter{Limit: 2}
+	for {
+		users, next, err := db.Users().List(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range users {
+			ids = append(ids, u.Id)
+		}
+		if next == 0 {
+			break
+		}
+		filter.Cursor = next
+	}
+	if len(ids) != 5 {
+		t.Fatalf("listed %v, want 5 users", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("ids %v are not ordered", ids)
+		}
+	}
+
+	admins, err := db.Users().Count(ctx, UsersFilter{Type: UserTypeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admins != 2 {
+		t.Errorf("admins = %d, want 2", admins)
+	}
+}
+
+func TestUsersDeletion(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLite(t)
+
+	model := newAuthModel("user@example.com")
+	if err := db.Auth().Create(ctx, model); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := db.Users().ScheduleDeletion(ctx, model.UserId_Auth, at); err != nil {
+		t.Fatal(err)
+	}
+	user, err := db.Users().FindByID(ctx, model.UserId_Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.DeletionScheduled == nil || !user.DeletionScheduled.Equal(at) {
+		t.Fatalf("deletion scheduled = %v, want %v", user.DeletionScheduled, at)
+	}
+	if err := db.Users().CancelDeletion(ctx, model.UserId_Auth); err != nil {
+		t.Fatal(err)
+	}
+	if user, err = db.Users().FindByID(ctx, model.UserId_Auth); err != nil || user.DeletionScheduled != nil {
+		t.Fatalf("deletion scheduled = %v, %v after cancel", user.DeletionScheduled, err)
+	}
+
+	if err := db.Users().Delete(ctx, model.UserId_Auth); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Users().FindByID(ctx, model.UserId_Auth); !errors.Is(err, ErrNotFound) {
+		t.Errorf("user err = %v, want ErrNotFound", err)
+	}
+	if _, err := db.Auth().FindByUserID(ctx, model.UserId_Auth); !errors.Is(err, ErrNotFound) {
+		t.Errorf("auth err = %v, want ErrNotFound", err)
+	}
+}
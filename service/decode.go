@@ -4,27 +4,31 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
 )
 
-func DecodeSignUpRequest(_ context.Context, r *http.Request) (interface{}, error) {
+func DecodeSignUpRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var body SignUpRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		return body, err
 	}
-	if err := body.Validate(); err != nil {
+	if err := body.Validate(ctx); err != nil {
 		return body, err
 	}
 	return body, nil
 }
 
-func DecodeLogInRequest(_ context.Context, r *http.Request) (interface{}, error) {
+func DecodeLogInRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	var body SignInRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		return body, err
 	}
-	if err := body.Validate(); err != nil {
+	if err := body.Validate(ctx); err != nil {
 		return body, err
 	}
 	return body, nil
@@ -34,3 +38,118 @@ func DecodeLogOutRequest(_ context.Context, r *http.Request) (interface{}, error
 	var body SignOutRequest
 	return body, nil
 }
+
+func DecodeDeleteAccountRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var body DeleteAccountRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return body, err
+	}
+	if err := body.Validate(ctx); err != nil {
+		return body, err
+	}
+	return body, nil
+}
+
+func DecodeExportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	body := ExportRequest{
+		Zip: r.URL.Query().Get("format") == "zip",
+	}
+	return body, nil
+}
+
+func DecodeUserExportRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	body := ExportRequest{
+		UserId: id,
+		Zip:    r.URL.Query().Get("format") == "zip",
+	}
+	return body, nil
+}
+
+func DecodeExportUsersRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	body := ExportUsersRequest{
+		Format: query.Get("format"),
+		Type:   query.Get("type"),
+	}
+	if body.Format == "" {
+		body.Format = UsersExportJSONL
+	}
+	if fields := query.Get("fields"); fields != "" {
+		body.Fields = strings.Split(fields, ",")
+	}
+	if err := body.Validate(ctx); err != nil {
+		return body, err
+	}
+	return body, nil
+}
+
+func DecodeMailPreviewRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	body := MailPreviewRequest{
+		Name:   mux.Vars(r)["name"],
+		Locale: r.URL.Query().Get("locale"),
+	}
+	return body, nil
+}
+
+func DecodeChangePasswordRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var body ChangePasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return body, err
+	}
+	if err := body.Validate(ctx); err != nil {
+		return body, err
+	}
+	return body, nil
+}
+
+func DecodeForgotPasswordRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var body ForgotPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return body, err
+	}
+	if err := body.Validate(ctx); err != nil {
+		return body, err
+	}
+	return body, nil
+}
+
+func DecodeResetPasswordRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var body ResetPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return body, err
+	}
+	if err := body.Validate(ctx); err != nil {
+		return body, err
+	}
+	return body, nil
+}
+
+func DecodeSuspendUserRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	body := SuspendUserRequest{
+		UserId:  id,
+		Suspend: strings.HasSuffix(r.URL.Path, "/suspend"),
+	}
+	return body, nil
+}
+
+func DecodeRefreshTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body RefreshTokenRequest
+	return body, nil
+}
+
+func DecodeVerifyTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body VerifyTokenRequest
+	return body, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// EncodeExportResponse sends the export as a downloadable json file or zip archive
+func EncodeExportResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(ExportResponse)
+	name := fmt.Sprintf("user-%d-export", resp.Data.User.Id)
+
+	if !resp.Zip {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		return writeExport(w, resp.Data)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+	archive := zip.NewWriter(w)
+	f, err := archive.Create(name + ".json")
+	if err != nil {
+		return err
+	}
+	if err := writeExport(f, resp.Data); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// EncodeExportUsersResponse streams the users as a downloadable csv or json lines file.
+// The status is sent before the users are read, a database error cuts the file short
+func EncodeExportUsersResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(ExportUsersResponse)
+	if resp.Format == UsersExportCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="users.jsonl"`)
+	}
+	return resp.Write(w)
+}
+
+func writeExport(w io.Writer, export *UserExport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
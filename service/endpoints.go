@@ -34,3 +34,83 @@ func MakeSignOutEndpoint(s Service) endpoint.Endpoint {
 	}
 	return nil
 }
+
+func MakeExportEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(ExportRequest)
+		resp := s.Export(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeExportUsersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(ExportUsersRequest)
+		resp := s.ExportUsers(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeMailPreviewEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(MailPreviewRequest)
+		resp := s.MailPreview(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeDeleteAccountEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(DeleteAccountRequest)
+		resp := s.DeleteAccount(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeChangePasswordEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(ChangePasswordRequest)
+		resp := s.ChangePassword(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeForgotPasswordEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(ForgotPasswordRequest)
+		resp := s.ForgotPassword(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeResetPasswordEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(ResetPasswordRequest)
+		resp := s.ResetPassword(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeSuspendUserEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(SuspendUserRequest)
+		resp := s.SuspendUser(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeRefreshTokenEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(RefreshTokenRequest)
+		resp := s.RefreshToken(ctx, req)
+		return *resp, resp.Error()
+	}
+}
+
+func MakeVerifyTokenEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(VerifyTokenRequest)
+		resp := s.VerifyToken(ctx, req)
+		return *resp, resp.Error()
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/cheebo/gorest"
+
+	"github.com/nori-io/auth/service/i18n"
+)
+
+// codes of the messages in the i18n catalogs, they must not change once released
+const (
+	msgInternal          = "internal_error"
+	msgUnauthorized      = "unauthorized"
+	msgForbidden         = "forbidden"
+	msgUserNotFound      = "user_not_found"
+	msgAccountSuspended  = "account_suspended"
+	msgSuspendSelf       = "suspend_self"
+	msgEmailExists       = "email_exists"
+	msgPasswordIncorrect = "password_incorrect"
+	msgResetLinkInvalid  = "reset_link_invalid"
+	msgPasswordReused    = "password_reused"
+	msgPasswordBreached  = "password_breached"
+	msgTemplateNotFound  = "template_not_found"
+	msgExportFormat      = "export_format_invalid"
+	msgExportFields      = "export_fields_invalid"
+	msgFieldNotAccepted  = "field_not_accepted"
+	msgNameInvalid       = "name_invalid"
+	msgUsernameInvalid   = "username_invalid"
+	msgUsernameReserved  = "username_reserved"
+	msgUsernameTaken     = "username_taken"
+	msgLocaleInvalid     = "locale_invalid"
+	msgTimezoneInvalid   = "timezone_invalid"
+	msgAttributeUnknown  = "attribute_unknown"
+	msgAttributeTooLong  = "attribute_too_long"
+	msgTermsRequired     = "terms_required"
+	msgTermsOutdated     = "terms_outdated"
+	msgSignInRequired    = "sign_in_required"
+	// msgPassword prefixes the rules of the password policy
+	msgPassword = "password_"
+	// msgField prefixes the validators of the valid tags, msgFieldInvalid is used for
+	// the validators without own message
+	msgField        = "field_"
+	msgFieldInvalid = "field_invalid"
+)
+
+// httpError is the error response with status code which has no helper in gorest
+func httpError(code int, message string) error {
+	return rest.ErrFieldResp{
+		Meta: rest.ErrFieldRespMeta{
+			ErrCode:    code,
+			ErrMessage: message,
+		},
+	}
+}
+
+// internalError is rest.ErrorInternal in the languages of ctx
+func internalError(ctx context.Context) error {
+	return rest.ErrorInternal(i18n.Text(ctx, msgInternal))
+}
+
+// validate checks the valid tags of the request, the field errors are in the languages of ctx
+func validate(ctx context.Context, r interface{}) error {
+	_, err := govalidator.ValidateStruct(r)
+	if err == nil {
+		return nil
+	}
+	var errs govalidator.Errors
+	if !errors.As(err, &errs) {
+		return rest.ValidateResponse(err)
+	}
+
+	errField := rest.ErrFieldResp{
+		Meta: rest.ErrFieldRespMeta{
+			ErrCode: 400,
+		},
+	}
+	for _, e := range errs.Errors() {
+		var fieldErr govalidator.Error
+		if !errors.As(e, &fieldErr) {
+			return rest.ValidateResponse(err)
+		}
+		message := i18n.Text(ctx, msgField+fieldErr.Validator)
+		if message == msgField+fieldErr.Validator {
+			message = i18n.Text(ctx, msgFieldInvalid)
+		}
+		errField.AddError(fieldErr.Name, 400, message)
+	}
+	return errField
+}
@@ -0,0 +1,112 @@
+// Package fakes contains in-memory implementations of the nori interfaces
+// used by the plugin, so the service can be wired without the CMS core
+package fakes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cheebo/rand"
+	"github.com/nori-io/nori-common/endpoint"
+)
+
+type ctxKey int
+
+const (
+	ctxToken ctxKey = iota
+	ctxSessionId
+	ctxClaims
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Auth issues opaque random tokens and keeps their claims in memory
+type Auth struct {
+	mu     sync.RWMutex
+	tokens map[string]Claims
+}
+
+type Claims struct {
+	Jti string
+	Sub string
+	Iss string
+	Raw interface{}
+}
+
+func NewAuth() *Auth {
+	return &Auth{
+		tokens: map[string]Claims{},
+	}
+}
+
+func (a *Auth) AccessToken(opts ...interface{}) (string, error) {
+	claims := Claims{}
+	for _, opt := range opts {
+		f, ok := opt.(func(interface{}) interface{})
+		if !ok {
+			continue
+		}
+		claims.Jti, _ = f("jti").(string)
+		claims.Sub, _ = f("sub").(string)
+		claims.Iss, _ = f("iss").(string)
+		claims.Raw = f("raw")
+	}
+	token := rand.RandomAlphaNum(48)
+
+	a.mu.Lock()
+	a.tokens[token] = claims
+	a.mu.Unlock()
+	return token, nil
+}
+
+// Authenticated accepts requests carrying a token issued by AccessToken
+func (a *Auth) Authenticated() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			token, _ := ctx.Value(ctxToken).(string)
+
+			a.mu.RLock()
+			claims, ok := a.tokens[token]
+			a.mu.RUnlock()
+			if !ok {
+				return nil, ErrUnauthorized
+			}
+			ctx = context.WithValue(ctx, ctxSessionId, []byte(claims.Jti))
+			ctx = context.WithValue(ctx, ctxClaims, claims)
+			return next(ctx, request)
+		}
+	}
+}
+
+// Revoke forgets token, so it is not accepted anymore
+func (a *Auth) Revoke(token string) {
+	a.mu.Lock()
+	delete(a.tokens, token)
+	a.mu.Unlock()
+}
+
+// ClaimsFromContext returns claims of the token accepted by Authenticated
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(ctxClaims).(Claims)
+	return claims, ok
+}
+
+// Transport puts bearer token from Authorization header into context
+type Transport struct{}
+
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+func (t *Transport) ToContext() func(context.Context, *http.Request) context.Context {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			return ctx
+		}
+		return context.WithValue(ctx, ctxToken, strings.TrimPrefix(header, "Bearer "))
+	}
+}
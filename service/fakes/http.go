@@ -0,0 +1,16 @@
+package fakes
+
+import (
+	"github.com/gorilla/mux"
+)
+
+// Router is interfaces.Http backed by gorilla mux, it can be served by httptest
+type Router struct {
+	*mux.Router
+}
+
+func NewRouter() *Router {
+	return &Router{
+		Router: mux.NewRouter(),
+	}
+}
@@ -0,0 +1,81 @@
+package fakes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nori-io/nori-common/endpoint"
+	"github.com/nori-io/nori-common/interfaces"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session keeps session states in memory, expiration is checked on read
+type Session struct {
+	mu       sync.RWMutex
+	sessions map[string]session
+}
+
+type session struct {
+	state   interfaces.SessionState
+	expires time.Time
+}
+
+func NewSession() *Session {
+	return &Session{
+		sessions: map[string]session{},
+	}
+}
+
+func (s *Session) Get(key []byte, data *interfaces.SessionState) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sess, ok := s.sessions[string(key)]
+	if !ok || (!sess.expires.IsZero() && sess.expires.Before(time.Now())) {
+		return ErrSessionNotFound
+	}
+	*data = sess.state
+	return nil
+}
+
+func (s *Session) Save(key []byte, data interfaces.SessionState, exp time.Duration) error {
+	sess := session{state: data}
+	if exp > 0 {
+		sess.expires = time.Now().Add(exp)
+	}
+	s.mu.Lock()
+	s.sessions[string(key)] = sess
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Session) Delete(key []byte) error {
+	s.mu.Lock()
+	delete(s.sessions, string(key))
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Session) SessionId(ctx context.Context) []byte {
+	sid, _ := ctx.Value(ctxSessionId).([]byte)
+	return sid
+}
+
+// Verify accepts requests which session is active
+func (s *Session) Verify() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var state interfaces.SessionState
+			if err := s.Get(s.SessionId(ctx), &state); err != nil {
+				return nil, ErrUnauthorized
+			}
+			if state != interfaces.SessionActive {
+				return nil, ErrUnauthorized
+			}
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package i18n keeps the message catalogs of the API errors. Messages are looked up by
+// stable codes, a catalog of a locale may have some of the codes only
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nori-io/auth/service/locale"
+)
+
+const defaultLocale = "en"
+
+//go:embed catalogs/*.json
+var builtin embed.FS
+
+type Config struct {
+	// Dir has <locale>.json files with messages overriding and adding to the built in catalogs
+	Dir func() string
+	// DefaultLocale is used for the codes missing in the catalogs of the user's languages
+	DefaultLocale func() string
+}
+
+// Catalog finds the messages, the nil Catalog has the built in catalogs only
+type Catalog struct {
+	cfg Config
+
+	mu     sync.Mutex
+	dir    string
+	loaded map[string]map[string]string
+}
+
+func New(cfg Config) *Catalog {
+	return &Catalog{cfg: cfg}
+}
+
+// Message returns the message of code in the first of the languages which has it. Then the default
+// locale and English are tried, the code itself is returned when no catalog has it
+func (c *Catalog) Message(languages []string, code string, args ...interface{}) string {
+	// languages may be the slice kept in the context, it is not appended to
+	for _, tag := range locale.Candidates(append(languages[:len(languages):len(languages)], c.defaultLocale()), defaultLocale) {
+		if message, ok := c.messages(tag)[code]; ok {
+			if len(args) > 0 {
+				return fmt.Sprintf(message, args...)
+			}
+			return message
+		}
+	}
+	return code
+}
+
+func (c *Catalog) defaultLocale() string {
+	if c == nil || c.cfg.DefaultLocale == nil || c.cfg.DefaultLocale() == "" {
+		return defaultLocale
+	}
+	return c.cfg.DefaultLocale()
+}
+
+// messages merges the catalog of the directory into the built in one, the merged
+// catalogs are loaded once per configured directory
+func (c *Catalog) messages(tag string) map[string]string {
+	if c == nil {
+		return readCatalog(builtin, "catalogs/"+tag+".json", nil)
+	}
+	dir := ""
+	if c.cfg.Dir != nil {
+		dir = c.cfg.Dir()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if dir != c.dir || c.loaded == nil {
+		c.dir = dir
+		c.loaded = map[string]map[string]string{}
+	}
+	if m, ok := c.loaded[tag]; ok {
+		return m
+	}
+	m := readCatalog(builtin, "catalogs/"+tag+".json", nil)
+	if dir != "" {
+		m = readCatalog(os.DirFS(dir), tag+".json", m)
+	}
+	c.loaded[tag] = m
+	return m
+}
+
+// readCatalog adds the messages of the file to m, missing and broken files add nothing
+func readCatalog(fsys fs.FS, file string, m map[string]string) map[string]string {
+	if m == nil {
+		m = map[string]string{}
+	}
+	b, err := fs.ReadFile(fsys, filepath.ToSlash(file))
+	if err != nil {
+		return m
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(b, &messages); err != nil {
+		return m
+	}
+	for code, message := range messages {
+		m[code] = message
+	}
+	return m
+}
+
+type contextKey struct{}
+
+// WithCatalog returns ctx the messages are looked up in c
+func WithCatalog(ctx context.Context, c *Catalog) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// ToContext keeps c and the languages of the Accept-Language header, it is a before function of the http servers
+func ToContext(c *Catalog) func(context.Context, *http.Request) context.Context {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		return WithCatalog(locale.ToContext(ctx, r), c)
+	}
+}
+
+// Text returns the message of code in the languages of ctx
+func Text(ctx context.Context, code string, args ...interface{}) string {
+	c, _ := ctx.Value(contextKey{}).(*Catalog)
+	return c.Message(locale.FromContext(ctx), code, args...)
+}
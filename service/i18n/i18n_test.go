@@ -0,0 +1,121 @@
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// catalogFile reads the built in catalog of the locale
+func catalogFile(t *testing.T, tag string) map[string]string {
+	t.Helper()
+	b, err := builtin.ReadFile("catalogs/" + tag + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("%s: %v", tag, err)
+	}
+	return m
+}
+
+var verbs = regexp.MustCompile(`%[a-z]`)
+
+func TestCatalogs(t *testing.T) {
+	en := catalogFile(t, "en")
+	entries, err := builtin.ReadDir("catalogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		tag := strings.TrimSuffix(entry.Name(), ".json")
+		if tag == "en" {
+			continue
+		}
+		catalog := catalogFile(t, tag)
+		for code, message := range en {
+			translated, ok := catalog[code]
+			if !ok {
+				t.Errorf("%s: %s is missing", tag, code)
+				continue
+			}
+			// the arguments are the same in every language
+			if got, want := strings.Join(verbs.FindAllString(translated, -1), ""), strings.Join(verbs.FindAllString(message, -1), ""); got != want {
+				t.Errorf("%s: %s has %q, want %q", tag, code, got, want)
+			}
+		}
+		for code := range catalog {
+			if _, ok := en[code]; !ok {
+				t.Errorf("%s: %s is not in English", tag, code)
+			}
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		// the new locale has some of the codes
+		"de.json": `{"forbidden": "Verboten"}`,
+		"ru.json": `{"unauthorized": "Войдите"}`,
+		"fr.json": `{broken`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	en, ru := catalogFile(t, "en"), catalogFile(t, "ru")
+
+	for _, tc := range []struct {
+		name      string
+		languages []string
+		fallback  string
+		code      string
+		want      string
+	}{
+		{"directory locale", []string{"de-AT"}, "", "forbidden", "Verboten"},
+		{"missing code", []string{"de", "ru"}, "", "user_not_found", ru["user_not_found"]},
+		{"built in default", []string{"de"}, "", "user_not_found", en["user_not_found"]},
+		{"configured default", []string{"de"}, "ru", "user_not_found", ru["user_not_found"]},
+		{"overridden", []string{"ru"}, "", "unauthorized", "Войдите"},
+		{"merged", []string{"ru"}, "", "forbidden", ru["forbidden"]},
+		{"broken catalog", []string{"fr"}, "", "forbidden", en["forbidden"]},
+		{"unknown code", []string{"ru"}, "", "no_such_code", "no_such_code"},
+	} {
+		c := New(Config{Dir: func() string { return dir }, DefaultLocale: func() string { return tc.fallback }})
+		if got := c.Message(tc.languages, tc.code); got != tc.want {
+			t.Errorf("%s: message = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	var c *Catalog
+	if got, want := c.Message([]string{"ru"}, "terms_outdated", "2024-05"), fmt.Sprintf(ru["terms_outdated"], "2024-05"); got != want {
+		t.Errorf("nil catalog: message = %q, want %q", got, want)
+	}
+	// the languages of the caller are kept as they are
+	languages := make([]string, 1, 3)
+	languages[0] = "de"
+	c.Message(languages, "forbidden")
+	if extra := languages[:2]; extra[1] != "" {
+		t.Errorf("languages are appended to: %q", extra)
+	}
+}
+
+func TestText(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "de, ru;q=0.5")
+	ctx := ToContext(New(Config{}))(context.Background(), r)
+	if got, want := Text(ctx, "forbidden"), catalogFile(t, "ru")["forbidden"]; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	if got, want := Text(context.Background(), "forbidden"), catalogFile(t, "en")["forbidden"]; got != want {
+		t.Errorf("text without the languages = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package issuer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/nori-io/nori-common/endpoint"
+	"github.com/nori-io/nori-common/interfaces"
+)
+
+type ctxKey int
+
+const (
+	ctxToken ctxKey = iota
+	ctxClaims
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// AccessToken implements interfaces.Auth, claims are requested from the option callbacks
+// the same way the registry Auth does: "jti", "sub", "iss" and "raw"
+func (i *Issuer) AccessToken(opts ...interface{}) (string, error) {
+	claims := Claims{Typ: TypeAccess}
+	for _, opt := range opts {
+		f, ok := opt.(func(interface{}) interface{})
+		if !ok {
+			continue
+		}
+		claims.Jti, _ = f("jti").(string)
+		claims.Sub, _ = f("sub").(string)
+		claims.Iss, _ = f("iss").(string)
+		claims.Raw, _ = f("raw").(map[string]string)
+	}
+	if scope, ok := claims.Raw["scope"]; ok {
+		claims.Scope = scope
+	}
+	return i.Sign(claims)
+}
+
+// Authenticated accepts requests carrying a valid token put in context by ToContext
+func (i *Issuer) Authenticated() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			token, _ := ctx.Value(ctxToken).(string)
+			if token == "" {
+				return nil, ErrUnauthorized
+			}
+			claims, err := i.ParseAccess(token)
+			if err != nil {
+				return nil, ErrUnauthorized
+			}
+			return next(context.WithValue(ctx, ctxClaims, claims), request)
+		}
+	}
+}
+
+// ToContext implements interfaces.HTTPTransport, it puts the bearer token into context
+func (i *Issuer) ToContext() func(context.Context, *http.Request) context.Context {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			return ctx
+		}
+		return context.WithValue(ctx, ctxToken, strings.TrimPrefix(header, "Bearer "))
+	}
+}
+
+// ClaimsFromContext returns claims of the token accepted by Authenticated
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(ctxClaims).(*Claims)
+	return claims, ok
+}
+
+// Session wraps the registry session, so the session id is taken from the tokens of the issuer
+func (i *Issuer) Session(s interfaces.Session) interfaces.Session {
+	return &session{Session: s}
+}
+
+type session struct {
+	interfaces.Session
+}
+
+func (s *session) SessionId(ctx context.Context) []byte {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []byte(claims.Jti)
+}
+
+// Verify accepts requests of active sessions only
+func (s *session) Verify() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			sid := s.SessionId(ctx)
+			if len(sid) == 0 {
+				return nil, ErrUnauthorized
+			}
+			var state interfaces.SessionState
+			if err := s.Get(sid, &state); err != nil || state != interfaces.SessionActive {
+				return nil, ErrUnauthorized
+			}
+			return next(ctx, request)
+		}
+	}
+}
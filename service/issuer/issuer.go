@@ -0,0 +1,359 @@
+// Package issuer is the native token issuer, it signs JWT access tokens with
+// keys kept in the database and rotates them on schedule.
+//
+// Every configured algorithm has its own chain of keys. A key signs tokens from
+// its activation till expiry and is published in JWKS until the last token signed
+// with it expires. The next key is created ahead of the current one expiry, so every
+// instance loads it before it is used.
+package issuer
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheebo/rand"
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+const (
+	defaultTTL      = time.Hour
+	defaultRotation = 30 * 24 * time.Hour
+	// refreshInterval is how often keys are reloaded and rotated
+	refreshInterval = 5 * time.Minute
+	// successors are created this long before the current key expires
+	rotationMargin = 3 * refreshInterval
+)
+
+type Config struct {
+	// Algorithms is comma separated list of RS256, ES256 and EdDSA, the first one signs access tokens
+	Algorithms func() string
+	// Iss is checked in the accepted tokens when not empty
+	Iss func() string
+	// TTL is the access token lifetime, e.g. 15m
+	TTL func() string
+	// Rotation is how long a key signs tokens, e.g. 720h
+	Rotation func() string
+}
+
+type key struct {
+	id        uint64
+	kid       string
+	alg       string
+	private   crypto.Signer
+	activates time.Time
+	expires   time.Time
+	retires   time.Time
+}
+
+type Issuer struct {
+	cfg Config
+	db  database.Database
+	log *logrus.Logger
+
+	mu   sync.RWMutex
+	keys []*key
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(db database.Database, cfg Config, log *logrus.Logger) *Issuer {
+	return &Issuer{
+		cfg: cfg,
+		db:  db,
+		log: log,
+	}
+}
+
+// Algorithms returns the configured algorithms, RS256 by default
+func (i *Issuer) Algorithms() []string {
+	var algs []string
+	if i.cfg.Algorithms != nil {
+		for _, alg := range strings.Split(i.cfg.Algorithms(), ",") {
+			if alg = strings.TrimSpace(alg); alg != "" {
+				algs = append(algs, alg)
+			}
+		}
+	}
+	if len(algs) == 0 {
+		algs = []string{RS256}
+	}
+	return algs
+}
+
+func (i *Issuer) TTL() time.Duration {
+	return duration(i.cfg.TTL, defaultTTL)
+}
+
+func (i *Issuer) rotation() time.Duration {
+	d := duration(i.cfg.Rotation, defaultRotation)
+	if d < 2*rotationMargin {
+		d = 2 * rotationMargin
+	}
+	return d
+}
+
+// Start rotates keys and keeps rotating them in background
+func (i *Issuer) Start(ctx context.Context) error {
+	for _, alg := range i.Algorithms() {
+		if alg != RS256 && alg != ES256 && alg != EdDSA {
+			return fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+		}
+	}
+	if err := i.Rotate(ctx); err != nil {
+		return err
+	}
+
+	i.stop = make(chan struct{})
+	i.done = make(chan struct{})
+	go func() {
+		defer close(i.done)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := i.Rotate(context.Background()); err != nil {
+					i.log.Error(err)
+				}
+			case <-i.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (i *Issuer) Stop() {
+	if i.stop == nil {
+		return
+	}
+	close(i.stop)
+	<-i.done
+	i.stop = nil
+}
+
+// Rotate removes retired keys, creates successors of the expiring ones and reloads all keys
+func (i *Issuer) Rotate(ctx context.Context) error {
+	now := time.Now()
+	models, err := i.db.SigningKeys().List(ctx)
+	if err != nil {
+		return err
+	}
+
+	latest := map[string]time.Time{}
+	live := models[:0]
+	for _, m := range models {
+		if m.Retires.Before(now) {
+			if err := i.db.SigningKeys().Delete(ctx, m.Id); err != nil {
+				return err
+			}
+			continue
+		}
+		if m.Expires.After(latest[m.Alg]) {
+			latest[m.Alg] = m.Expires
+		}
+		live = append(live, m)
+	}
+
+	for _, alg := range i.Algorithms() {
+		expires, ok := latest[alg]
+		if ok && expires.After(now.Add(rotationMargin)) {
+			continue
+		}
+		activates := now
+		if ok && expires.After(now) {
+			activates = expires
+		}
+		m, err := i.createKey(ctx, alg, activates)
+		if err != nil {
+			return err
+		}
+		live = append(live, *m)
+	}
+
+	keys := make([]*key, 0, len(live))
+	for _, m := range live {
+		private, err := decodeKey(m.Alg, m.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("issuer: key %s: %w", m.Kid, err)
+		}
+		keys = append(keys, &key{
+			id:        m.Id,
+			kid:       m.Kid,
+			alg:       m.Alg,
+			private:   private,
+			activates: m.Activates,
+			expires:   m.Expires,
+			retires:   m.Retires,
+		})
+	}
+
+	i.mu.Lock()
+	i.keys = keys
+	i.mu.Unlock()
+	return nil
+}
+
+func (i *Issuer) createKey(ctx context.Context, alg string, activates time.Time) (*database.SigningKeyModel, error) {
+	private, err := generateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := encodeKey(private)
+	if err != nil {
+		return nil, err
+	}
+	expires := activates.Add(i.rotation())
+	m := &database.SigningKeyModel{
+		Kid:        rand.RandomAlphaNum(16),
+		Alg:        alg,
+		PrivateKey: encoded,
+		Activates:  activates,
+		Expires:    expires,
+		Retires:    expires.Add(i.TTL()),
+	}
+	if err := i.db.SigningKeys().Create(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// signingKey returns the active key of alg, when rotation failed the newest activated key is used
+func (i *Issuer) signingKey(alg string, now time.Time) *key {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var found *key
+	for _, k := range i.keys {
+		if k.alg != alg || k.activates.After(now) || k.retires.Before(now) {
+			continue
+		}
+		if found == nil || k.activates.After(found.activates) {
+			found = k
+		}
+	}
+	return found
+}
+
+func (i *Issuer) lookup(kid string) *key {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, k := range i.keys {
+		if k.kid == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// Sign signs claims with the first configured algorithm, empty exp and iat are filled
+func (i *Issuer) Sign(claims Claims) (string, error) {
+	return i.SignWith(i.Algorithms()[0], claims)
+}
+
+func (i *Issuer) SignWith(alg string, claims Claims) (string, error) {
+	now := time.Now()
+	k := i.signingKey(alg, now)
+	if k == nil {
+		return "", fmt.Errorf("%w: no %s key", ErrUnknownKey, alg)
+	}
+	if claims.Iat == 0 {
+		claims.Iat = now.Unix()
+	}
+	if claims.Exp == 0 {
+		claims.Exp = now.Add(i.TTL()).Unix()
+	}
+	if claims.Iss == "" && i.cfg.Iss != nil {
+		claims.Iss = i.cfg.Iss()
+	}
+	return encode(k, claims)
+}
+
+// Parse verifies the token and returns its claims
+func (i *Issuer) Parse(token string) (*Claims, error) {
+	claims, err := decode(token, i.lookup)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.valid(time.Now()); err != nil {
+		return nil, err
+	}
+	if i.cfg.Iss != nil && i.cfg.Iss() != "" && claims.Iss != i.cfg.Iss() {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseAccess verifies the token and accepts only the access tokens
+func (i *Issuer) ParseAccess(token string) (*Claims, error) {
+	claims, err := i.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Typ != TypeAccess {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseHint verifies the token issued by us but accepts expired ones, it is used
+// for hints like id_token_hint where only the subject and the audience matter
+func (i *Issuer) ParseHint(token string) (*Claims, error) {
+	claims, err := decode(token, i.lookup)
+	if err != nil {
+		return nil, err
+	}
+	if i.cfg.Iss != nil && i.cfg.Iss() != "" && claims.Iss != i.cfg.Iss() {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// JWKS returns public keys of all not retired keys, including the scheduled ones
+func (i *Issuer) JWKS() JWKS {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	now := time.Now()
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range i.keys {
+		if k.retires.Before(now) {
+			continue
+		}
+		set.Keys = append(set.Keys, publicJWK(k.kid, k.alg, k.private.Public()))
+	}
+	return set
+}
+
+// JWKSHandler serves JWKS, it is mounted on /.well-known/jwks.json
+func (i *Issuer) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(refreshInterval.Seconds())))
+		if err := json.NewEncoder(w).Encode(i.JWKS()); err != nil {
+			i.log.Error(err)
+		}
+	})
+}
+
+func duration(f func() string, def time.Duration) time.Duration {
+	if f == nil || f() == "" {
+		return def
+	}
+	d, err := time.ParseDuration(f())
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
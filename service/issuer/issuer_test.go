@@ -0,0 +1,238 @@
+package issuer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+const testIss = "https://auth.example.com"
+
+func newIssuer(t *testing.T, db database.Database, algs string) *Issuer {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	i := New(db, Config{
+		Algorithms: func() string { return algs },
+		Iss:        func() string { return testIss },
+	}, logger)
+	if err := i.Rotate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// kid returns the key id from the token header
+func kid(t *testing.T, token string) string {
+	t.Helper()
+	var h header
+	if err := unmarshal(strings.Split(token, ".")[0], &h); err != nil {
+		t.Fatal(err)
+	}
+	return h.Kid
+}
+
+// jwkPublic restores the public key from its JWK the way the clients do
+func jwkPublic(t *testing.T, jwk JWK) crypto.PublicKey {
+	t.Helper()
+	decode := func(s string) []byte {
+		data, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	switch jwk.Kty {
+	case "RSA":
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(decode(jwk.N)), E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64())}
+	case "EC":
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(decode(jwk.X)), Y: new(big.Int).SetBytes(decode(jwk.Y))}
+	case "OKP":
+		return ed25519.PublicKey(decode(jwk.X))
+	}
+	t.Fatalf("unknown kty %q", jwk.Kty)
+	return nil
+}
+
+func TestSignParse(t *testing.T) {
+	i := newIssuer(t, database.NewMemory(), "EdDSA, ES256, RS256")
+	jwks := i.JWKS()
+	if len(jwks.Keys) != 3 {
+		t.Fatalf("JWKS has %d keys, want 3", len(jwks.Keys))
+	}
+
+	for _, alg := range i.Algorithms() {
+		token, err := i.SignWith(alg, Claims{Sub: "1", Jti: "sid", Typ: TypeAccess})
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		claims, err := i.Parse(token)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if claims.Sub != "1" || claims.Jti != "sid" || claims.Iss != testIss || claims.Exp-claims.Iat != int64(defaultTTL.Seconds()) {
+			t.Errorf("%s: claims = %+v", alg, claims)
+		}
+
+		// the token is verified with the published key
+		var published *JWK
+		for n := range jwks.Keys {
+			if jwks.Keys[n].Kid == kid(t, token) {
+				published = &jwks.Keys[n]
+			}
+		}
+		if published == nil || published.Alg != alg || published.Use != "sig" {
+			t.Fatalf("%s: signing key is not published: %+v", alg, published)
+		}
+		public := jwkPublic(t, *published)
+		if _, err := decodeWith(token, func(string) (string, crypto.PublicKey) { return alg, public }); err != nil {
+			t.Errorf("%s: token is not verified with JWKS: %v", alg, err)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	i := newIssuer(t, database.NewMemory(), "ES256")
+	other := newIssuer(t, database.NewMemory(), "ES256")
+	sign := func(signer *Issuer, claims Claims) string {
+		token, err := signer.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(i, Claims{Sub: "1"})
+	parts := strings.Split(valid, ".")
+	payload, _ := json.Marshal(Claims{Sub: "2", Iss: testIss, Exp: time.Now().Add(time.Hour).Unix()})
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"expired", sign(i, Claims{Sub: "1", Exp: time.Now().Add(-time.Hour).Unix()}), ErrExpiredToken},
+		{"not yet valid", sign(i, Claims{Sub: "1", Nbf: time.Now().Add(time.Hour).Unix()}), ErrInvalidToken},
+		{"other issuer", sign(i, Claims{Sub: "1", Iss: "https://other.example.com"}), ErrInvalidToken},
+		{"unknown key", sign(other, Claims{Sub: "1"}), ErrUnknownKey},
+		{"modified payload", parts[0] + "." + b64(payload) + "." + parts[2], ErrInvalidToken},
+		{"no signature", parts[0] + "." + parts[1] + ".", ErrInvalidToken},
+		{"not a token", "token", ErrInvalidToken},
+	} {
+		if _, err := i.Parse(tc.token); !errors.Is(err, tc.err) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.err)
+		}
+	}
+
+	// the hints may be expired
+	if claims, err := i.ParseHint(sign(i, Claims{Sub: "1", Exp: time.Now().Add(-time.Hour).Unix()})); err != nil || claims.Sub != "1" {
+		t.Errorf("ParseHint of the expired token = %+v, %v", claims, err)
+	}
+	if _, err := i.ParseAccess(sign(i, Claims{Sub: "1", Typ: TypeID})); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseAccess of the ID token: err = %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	db := database.NewMemory()
+	now := time.Now()
+
+	private, err := generateKey(ES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := encodeKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*database.SigningKeyModel{
+		// the key expiring before the next rotation gets a successor
+		{Kid: "expiring", Alg: ES256, PrivateKey: encoded, Activates: now.Add(-time.Hour), Expires: now.Add(time.Minute), Retires: now.Add(time.Hour)},
+		{Kid: "retired", Alg: ES256, PrivateKey: encoded, Activates: now.Add(-3 * time.Hour), Expires: now.Add(-2 * time.Hour), Retires: now.Add(-time.Hour)},
+	} {
+		if err := db.SigningKeys().Create(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i := newIssuer(t, db, "ES256")
+	models, err := db.SigningKeys().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kids := map[string]database.SigningKeyModel{}
+	for _, m := range models {
+		kids[m.Kid] = m
+	}
+	if _, ok := kids["retired"]; ok || len(kids) != 2 {
+		t.Fatalf("keys = %v, want the expiring key and its successor", kids)
+	}
+	for k, m := range kids {
+		if k != "expiring" && !m.Activates.Equal(kids["expiring"].Expires) {
+			t.Errorf("successor activates at %v, want %v", m.Activates, kids["expiring"].Expires)
+		}
+	}
+
+	// the successor is published ahead but doesn't sign until it activates
+	if n := len(i.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS has %d keys, want 2", n)
+	}
+	token, err := i.Sign(Claims{Sub: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := kid(t, token); k != "expiring" {
+		t.Errorf("token is signed with %q", k)
+	}
+
+	// another instance loads the same keys
+	if _, err := newIssuer(t, db, "ES256").Parse(token); err != nil {
+		t.Errorf("token is not accepted by another instance: %v", err)
+	}
+	// a successor is created once
+	if err := i.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if models, _ := db.SigningKeys().List(ctx); len(models) != 2 {
+		t.Errorf("%d keys after the second rotation", len(models))
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	i := newIssuer(t, database.NewMemory(), "EdDSA")
+	w := httptest.NewRecorder()
+	i.JWKSHandler().ServeHTTP(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=") {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	var set JWKS
+	if err := json.NewDecoder(w.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "OKP" || set.Keys[0].Crv != "Ed25519" || set.Keys[0].X == "" {
+		t.Errorf("JWKS = %+v", set)
+	}
+	if strings.Contains(w.Body.String(), "PRIVATE") || strings.Contains(w.Body.String(), `"d"`) {
+		t.Errorf("private key is published: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,205 @@
+package issuer
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("issuer: invalid token")
+	ErrExpiredToken = errors.New("issuer: token is expired")
+	ErrUnknownKey   = errors.New("issuer: unknown signing key")
+)
+
+// leeway tolerates clock skew between instances
+const leeway = 30 * time.Second
+
+// Types of the tokens signed with the same keys
+const (
+	TypeAccess = "access"
+	TypeID     = "id"
+)
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Claims of the issued tokens, Raw carries the claims the service passes to AccessToken
+type Claims struct {
+	Iss   string   `json:"iss,omitempty"`
+	Sub   string   `json:"sub,omitempty"`
+	Aud   Audience `json:"aud,omitempty"`
+	Exp   int64    `json:"exp"`
+	Iat   int64    `json:"iat"`
+	Nbf   int64    `json:"nbf,omitempty"`
+	Jti   string   `json:"jti,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+	// ClientId is the OAuth client the token is issued to, empty for the sign in tokens
+	ClientId string            `json:"client_id,omitempty"`
+	Raw      map[string]string `json:"raw,omitempty"`
+	// Typ is TypeAccess or TypeID, ID tokens must not be accepted as access tokens
+	Typ string `json:"typ,omitempty"`
+
+	// OpenID Connect ID token claims
+	Nonce               string `json:"nonce,omitempty"`
+	AuthTime            int64  `json:"auth_time,omitempty"`
+	Email               string `json:"email,omitempty"`
+	EmailVerified       *bool  `json:"email_verified,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool  `json:"phone_number_verified,omitempty"`
+}
+
+// Audience is the aud claim, it is a string or an array of strings (RFC 7519 section 4.1.3).
+// A single value is encoded as a string
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*a = values
+	return nil
+}
+
+// Contains reports whether aud is one of the values
+func (a Audience) Contains(aud string) bool {
+	for _, value := range a {
+		if value == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Claims) valid(now time.Time) error {
+	if c.Exp != 0 && now.After(time.Unix(c.Exp, 0).Add(leeway)) {
+		return ErrExpiredToken
+	}
+	if c.Nbf != 0 && now.Add(leeway).Before(time.Unix(c.Nbf, 0)) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// encode returns compact JWS of claims signed with k
+func encode(k *key, claims Claims) (string, error) {
+	h, err := json.Marshal(header{Alg: k.alg, Typ: "JWT", Kid: k.kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := b64(h) + "." + b64(payload)
+	sig, err := sign(k.alg, k.private, []byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + b64(sig), nil
+}
+
+// decode verifies the token signature with the key found by lookup, claims are not validated
+func decode(token string, lookup func(kid string) *key) (*Claims, error) {
+	return decodeWith(token, func(kid string) (string, crypto.PublicKey) {
+		k := lookup(kid)
+		if k == nil {
+			return "", nil
+		}
+		return k.alg, k.private.Public()
+	})
+}
+
+// decodeWith verifies the token signature with the public key returned for the header kid
+func decodeWith(token string, public func(kid string) (alg string, key crypto.PublicKey)) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := unmarshal(parts[0], &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	alg, key := public(h.Kid)
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	// the key decides the algorithm, the header one must only match it
+	if h.Alg != alg {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !verify(alg, key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := unmarshal(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// ParseAssertion verifies the token a client signed with its private key, publicKey is
+// the PEM of the registered client key (RFC 7523 section 3)
+func ParseAssertion(token, publicKey string) (*Claims, error) {
+	alg, key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := decodeWith(token, func(string) (string, crypto.PublicKey) { return alg, key })
+	if err != nil {
+		return nil, err
+	}
+	if claims.Exp == 0 {
+		return nil, ErrInvalidToken
+	}
+	if err := claims.valid(time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Unverified returns claims of the token without checking the signature, it is only
+// used to find the key the token must be verified with
+func Unverified(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := unmarshal(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func unmarshal(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,28 @@
+package issuer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAudience(t *testing.T) {
+	for _, tc := range []struct {
+		json string
+		aud  Audience
+	}{
+		{`"app"`, Audience{"app"}},
+		{`["app","api"]`, Audience{"app", "api"}},
+	} {
+		var claims Claims
+		if err := json.Unmarshal([]byte(`{"aud":`+tc.json+`}`), &claims); err != nil {
+			t.Fatal(err)
+		}
+		if len(claims.Aud) != len(tc.aud) || !claims.Aud.Contains(tc.aud[0]) || claims.Aud.Contains("other") {
+			t.Errorf("%s: aud = %v", tc.json, claims.Aud)
+		}
+		data, err := json.Marshal(tc.aud)
+		if err != nil || string(data) != tc.json {
+			t.Errorf("%v is encoded as %s, %v", tc.aud, data, err)
+		}
+	}
+}
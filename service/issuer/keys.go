@@ -0,0 +1,189 @@
+package issuer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+const rsaBits = 2048
+
+var ErrUnsupportedAlg = errors.New("issuer: unsupported algorithm")
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// generateKey returns a new private key of alg
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case RS256:
+		return rsa.GenerateKey(rand.Reader, rsaBits)
+	case ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+}
+
+func encodeKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// decodeKey parses PEM encoded PKCS8 key and checks it suits alg
+func decodeKey(alg, data string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("issuer: invalid key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		ok = alg == RS256
+	case *ecdsa.PrivateKey:
+		ok = alg == ES256 && key.Curve == elliptic.P256()
+	case ed25519.PrivateKey:
+		ok = alg == EdDSA
+	}
+	if !ok {
+		return nil, fmt.Errorf("issuer: key doesn't match %s", alg)
+	}
+	return parsed.(crypto.Signer), nil
+}
+
+// ParsePublicKey decodes PEM encoded PKIX public key and returns the algorithm it verifies
+func ParsePublicKey(data string) (string, crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return "", nil, errors.New("issuer: invalid public key PEM")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", nil, err
+	}
+	switch key := parsed.(type) {
+	case *rsa.PublicKey:
+		return RS256, key, nil
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return ES256, key, nil
+		}
+	case ed25519.PublicKey:
+		return EdDSA, key, nil
+	}
+	return "", nil, ErrUnsupportedAlg
+}
+
+func publicJWK(kid, alg string, public crypto.PublicKey) JWK {
+	jwk := JWK{Use: "sig", Kid: kid, Alg: alg}
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(key.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = b64(key.X.FillBytes(make([]byte, 32)))
+		jwk.Y = b64(key.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(key)
+	}
+	return jwk
+}
+
+// sign returns JWS signature of the signing input
+func sign(alg string, key crypto.Signer, input []byte) ([]byte, error) {
+	switch alg {
+	case RS256:
+		sum := sha256.Sum256(input)
+		return key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	case ES256:
+		sum := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), sum[:])
+		if err != nil {
+			return nil, err
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	case EdDSA:
+		return key.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+}
+
+func verify(alg string, public crypto.PublicKey, input, sig []byte) bool {
+	switch alg {
+	case RS256:
+		key, ok := public.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		sum := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	case ES256:
+		key, ok := public.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return false
+		}
+		sum := sha256.Sum256(input)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(key, sum[:], r, s)
+	case EdDSA:
+		key, ok := public.(ed25519.PublicKey)
+		if !ok {
+			return false
+		}
+		return ed25519.Verify(key, input, sig)
+	}
+	return false
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,220 @@
+// Package keyring implements envelope encryption of sensitive columns.
+//
+// Every value is encrypted with a random AES-256-GCM data key, the data key
+// is encrypted with the active master key and stored next to the value with
+// the master key id:
+//
+//	v1.<key id>.<base64 wrapped data key>.<base64 encrypted value>
+//
+// Master keys are loaded from a local JSON file:
+//
+//	{
+//	  "active": "2024-06",
+//	  "index_key": "<base64 32 bytes>",
+//	  "keys": {"2024-06": "<base64 32 bytes>", "2023-11": "<base64 32 bytes>"}
+//	}
+//
+// Old keys stay in the file until all values are re-encrypted with the active one.
+// The index key is never rotated, blind indexes built with it allow lookups of encrypted values.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	version = "v1"
+	keySize = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("keyring: unknown master key")
+	ErrMalformed  = errors.New("keyring: malformed ciphertext")
+)
+
+type Keyring struct {
+	active   string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+type file struct {
+	Active   string            `json:"active"`
+	IndexKey string            `json:"index_key"`
+	Keys     map[string]string `json:"keys"`
+}
+
+// Load reads the keyring file, the active key and the index key must be present
+func Load(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("keyring: %s: %w", path, err)
+	}
+
+	k := &Keyring{
+		active: f.Active,
+		keys:   map[string][]byte{},
+	}
+	for id, encoded := range f.Keys {
+		if id == "" || strings.Contains(id, ".") {
+			return nil, fmt.Errorf("keyring: invalid key id %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %q: %w", id, err)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[k.active]; !ok {
+		return nil, fmt.Errorf("keyring: active key %q is not in the keyring", k.active)
+	}
+	if k.indexKey, err = decodeKey(f.IndexKey); err != nil {
+		return nil, fmt.Errorf("keyring: index key: %w", err)
+	}
+	return k, nil
+}
+
+// Generate returns a random base64 encoded key for the keyring file
+func Generate() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (k *Keyring) ActiveKey() string {
+	return k.active
+}
+
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		version,
+		k.active,
+		base64.RawURLEncoding.EncodeToString(wrapped),
+		base64.RawURLEncoding.EncodeToString(sealed),
+	}, "."), nil
+}
+
+// Decrypt opens values created by Encrypt, values stored before encryption
+// was enabled are returned as is
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, version+".") {
+		return ciphertext, nil
+	}
+	parts := strings.Split(ciphertext, ".")
+	if len(parts) != 4 {
+		return "", ErrMalformed
+	}
+	master, ok := k.keys[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, parts[1])
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dataKey, err := open(master, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Stale reports whether ciphertext is not encrypted with the active key
+func (k *Keyring) Stale(ciphertext string) bool {
+	if ciphertext == "" {
+		return false
+	}
+	return !strings.HasPrefix(ciphertext, version+"."+k.active+".")
+}
+
+// BlindIndex is a keyed hash of value, it allows exact match lookups and unique indexes
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes", keySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,158 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	indexKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("i", keySize)))
+	oldKey   = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", keySize)))
+	newKey   = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("n", keySize)))
+)
+
+// load writes the keyring file and loads it
+func load(t *testing.T, f file) (*Keyring, error) {
+	t.Helper()
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func mustLoad(t *testing.T, f file) *Keyring {
+	t.Helper()
+	k, err := load(t, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncrypt(t *testing.T) {
+	k := mustLoad(t, file{Active: "old", IndexKey: indexKey, Keys: map[string]string{"old": oldKey}})
+
+	first, err := k.Encrypt("+10000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := k.Encrypt("+10000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("same value is encrypted to the same ciphertext")
+	}
+	if !strings.HasPrefix(first, "v1.old.") || strings.Contains(first, "10000000000") {
+		t.Errorf("ciphertext = %q", first)
+	}
+	for _, ciphertext := range []string{first, second} {
+		if plaintext, err := k.Decrypt(ciphertext); err != nil || plaintext != "+10000000000" {
+			t.Errorf("Decrypt = %q, %v", plaintext, err)
+		}
+	}
+	if plaintext, err := k.Decrypt("+10000000000"); err != nil || plaintext != "+10000000000" {
+		t.Errorf("Decrypt of the plain value = %q, %v", plaintext, err)
+	}
+	if k.Stale(first) || k.Stale("") || !k.Stale("+10000000000") {
+		t.Error("Stale is wrong for the active key")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := mustLoad(t, file{Active: "old", IndexKey: indexKey, Keys: map[string]string{"old": oldKey}})
+	rotated := mustLoad(t, file{Active: "new", IndexKey: indexKey, Keys: map[string]string{"old": oldKey, "new": newKey}})
+	retired := mustLoad(t, file{Active: "new", IndexKey: indexKey, Keys: map[string]string{"new": newKey}})
+
+	ciphertext, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated.Stale(ciphertext) {
+		t.Error("value of the old key is not stale")
+	}
+	if plaintext, err := rotated.Decrypt(ciphertext); err != nil || plaintext != "secret" {
+		t.Errorf("Decrypt with the old key = %q, %v", plaintext, err)
+	}
+	if _, err := retired.Decrypt(ciphertext); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt without the old key: err = %v, want ErrUnknownKey", err)
+	}
+	// the index key is not rotated, lookups keep working
+	if old.BlindIndex("secret") != rotated.BlindIndex("secret") {
+		t.Error("blind index changed with the master key")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k := mustLoad(t, file{Active: "old", IndexKey: indexKey, Keys: map[string]string{"old": oldKey}})
+	other := mustLoad(t, file{Active: "old", IndexKey: newKey, Keys: map[string]string{"old": oldKey}})
+	if k.BlindIndex("a") != k.BlindIndex("a") || k.BlindIndex("a") == k.BlindIndex("b") {
+		t.Error("blind index is not a function of the value")
+	}
+	if k.BlindIndex("a") == other.BlindIndex("a") {
+		t.Error("blind index doesn't depend on the index key")
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	k := mustLoad(t, file{Active: "old", IndexKey: indexKey, Keys: map[string]string{"old": oldKey}})
+	ciphertext, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(ciphertext, ".")
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 1
+
+	for name, value := range map[string]string{
+		"modified": strings.Join([]string{parts[0], parts[1], parts[2], base64.RawURLEncoding.EncodeToString(sealed)}, "."),
+		"parts":    strings.Join(parts[:3], "."),
+		"encoding": strings.Join([]string{parts[0], parts[1], "!", parts[3]}, "."),
+		"short":    strings.Join([]string{parts[0], parts[1], parts[2], "AA"}, "."),
+	} {
+		if _, err := k.Decrypt(value); err == nil {
+			t.Errorf("%s: ciphertext is decrypted", name)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, f := range map[string]file{
+		"no active key":   {Active: "new", IndexKey: indexKey, Keys: map[string]string{"old": oldKey}},
+		"no index key":    {Active: "old", Keys: map[string]string{"old": oldKey}},
+		"short key":       {Active: "old", IndexKey: indexKey, Keys: map[string]string{"old": "c2hvcnQ="}},
+		"dot in key id":   {Active: "old", IndexKey: indexKey, Keys: map[string]string{"old": oldKey, "2024.06": newKey}},
+		"not base64 key":  {Active: "old", IndexKey: indexKey, Keys: map[string]string{"old": "!"}},
+		"short index key": {Active: "old", IndexKey: "c2hvcnQ=", Keys: map[string]string{"old": oldKey}},
+	} {
+		if _, err := load(t, f); err == nil {
+			t.Errorf("%s: keyring is loaded", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	encoded, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := decodeKey(encoded); err != nil || len(key) != keySize {
+		t.Errorf("generated key %q: %v", encoded, err)
+	}
+}
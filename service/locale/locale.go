@@ -0,0 +1,122 @@
+// Package locale negotiates the language of the texts sent to the user
+package locale
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type contextKey struct{}
+
+// ToContext keeps the languages of the Accept-Language header, it is a before function of the http servers
+func ToContext(ctx context.Context, r *http.Request) context.Context {
+	tags := Parse(r.Header.Get("Accept-Language"))
+	if len(tags) == 0 {
+		return ctx
+	}
+	return WithLocale(ctx, tags...)
+}
+
+// WithLocale returns ctx preferring the tags over the ones already in ctx
+func WithLocale(ctx context.Context, tags ...string) context.Context {
+	var clean []string
+	for _, tag := range tags {
+		if tag = Normalize(tag); tag != "" {
+			clean = append(clean, tag)
+		}
+	}
+	if len(clean) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, append(clean, FromContext(ctx)...))
+}
+
+// FromContext returns the preferred languages, the most preferred first
+func FromContext(ctx context.Context) []string {
+	tags, _ := ctx.Value(contextKey{}).([]string)
+	return tags
+}
+
+// Parse returns the languages of the Accept-Language header ordered by quality, "*" is skipped
+func Parse(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.SplitN(part, ";", 2)
+		tag := Normalize(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if len(fields) == 2 && strings.HasPrefix(strings.TrimSpace(fields[1]), "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(fields[1]), "q="), 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			q = parsed
+		}
+		langs = append(langs, weighted{tag, q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// Normalize formats the tag as "en" or "pt-BR", an empty string is returned for invalid tags
+func Normalize(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if tag == "*" {
+		return tag
+	}
+	parts := strings.Split(tag, "-")
+	for i, p := range parts {
+		if p == "" || len(p) > 8 || strings.IndexFunc(p, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) >= 0 {
+			return ""
+		}
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(p)
+		case len(p) == 2:
+			parts[i] = strings.ToUpper(p)
+		default:
+			parts[i] = strings.ToLower(p)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// Candidates lists the languages to look up in order, each tag is followed by its base
+// language and fallback comes last: "pt-BR", "de" and "en" give "pt-BR", "pt", "de", "en"
+func Candidates(tags []string, fallback string) []string {
+	var out []string
+	seen := map[string]bool{}
+	add := func(tag string) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	for _, tag := range append(tags[:len(tags):len(tags)], fallback) {
+		tag = Normalize(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		add(tag)
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			add(tag[:i])
+		}
+	}
+	return out
+}
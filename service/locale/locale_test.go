@@ -0,0 +1,83 @@
+package locale
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"ru", []string{"ru"}},
+		{"ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7", []string{"ru-RU", "ru", "en-US", "en"}},
+		// the order of equal qualities is kept
+		{"de;q=0.5, fr, en_gb, it;q=0.5", []string{"fr", "en-GB", "de", "it"}},
+		{"en;q=0, ru;q=0.1, *;q=0.9", []string{"ru"}},
+		{"en;q=x, ru, zh-Hant-TW", []string{"ru", "zh-hant-TW"}},
+		{"<script>, en-", []string{}},
+		{"pt-br;level=1, en", []string{"pt-BR", "en"}},
+	} {
+		if got := Parse(tc.header); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%q: tags = %q, want %q", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for tag, want := range map[string]string{
+		"EN":           "en",
+		" pt_br ":      "pt-BR",
+		"zh-HANT-tw":   "zh-hant-TW",
+		"*":            "*",
+		"en--US":       "",
+		"verylongtag1": "",
+		"ru/RU":        "",
+		"":             "",
+	} {
+		if got := Normalize(tag); got != want {
+			t.Errorf("%q: normalized = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	tags := make([]string, 3, 5)
+	copy(tags, []string{"pt-BR", "de", "PT"})
+	got := Candidates(tags, "en")
+	if want := []string{"pt-BR", "pt", "de", "en"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("candidates = %q, want %q", got, want)
+	}
+	// the tags of the caller are kept as they are
+	if extra := tags[:4]; extra[3] != "" {
+		t.Errorf("tags are appended to: %q", extra)
+	}
+	if got := Candidates([]string{"*", "bad tag"}, "ru"); fmt.Sprint(got) != "[ru]" {
+		t.Errorf("candidates = %q, want [ru]", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "ru;q=0.8, de")
+	ctx := ToContext(context.Background(), r)
+	if got := FromContext(ctx); fmt.Sprint(got) != "[de ru]" {
+		t.Errorf("languages = %q", got)
+	}
+
+	// the explicit locale goes before the header
+	ctx = WithLocale(ctx, "en_US", "")
+	if got := FromContext(ctx); fmt.Sprint(got) != "[en-US de ru]" {
+		t.Errorf("languages = %q", got)
+	}
+	if FromContext(WithLocale(context.Background(), "bad tag")) != nil {
+		t.Error("invalid tag is kept")
+	}
+	if FromContext(ToContext(context.Background(), httptest.NewRequest("GET", "/", nil))) != nil {
+		t.Error("languages without the header")
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cheebo/gorest"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/locale"
+	"github.com/nori-io/auth/service/templates"
+)
+
+// Mailer delivers transactional emails
+type Mailer interface {
+	Send(ctx context.Context, to string, message templates.Message) error
+}
+
+// sendMail renders the email in the languages of ctx and sends it
+func (s *service) sendMail(ctx context.Context, to, name string, data templates.Data) error {
+	data.Email = to
+	message, err := s.templates.Render(name, locale.FromContext(ctx), data)
+	if err != nil {
+		return err
+	}
+	return s.mail.Send(ctx, to, *message)
+}
+
+// notifyPasswordChanged tells the owner of the account about the new password, failures are only logged
+func (s *service) notifyPasswordChanged(ctx context.Context, email string) {
+	if email == "" {
+		return
+	}
+	if err := s.sendMail(ctx, email, templates.PasswordChanged, templates.Data{Time: time.Now()}); err != nil {
+		s.log.Error(err)
+	}
+}
+
+// MailPreview renders the email with sample data, it is allowed to admins only
+func (s *service) MailPreview(ctx context.Context, req MailPreviewRequest) (resp *MailPreviewResponse) {
+	resp = &MailPreviewResponse{}
+
+	caller, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if caller.Type != database.UserTypeAdmin {
+		resp.Err = httpError(403, i18n.Text(ctx, msgForbidden))
+		return resp
+	}
+
+	languages := locale.FromContext(ctx)
+	if req.Locale != "" {
+		languages = []string{req.Locale}
+	}
+	message, err := s.templates.Render(req.Name, languages, templates.Sample("user@example.com"))
+	if errors.Is(err, templates.ErrNotFound) {
+		resp.Err = rest.ErrorNotFound(i18n.Text(ctx, msgTemplateNotFound))
+		return resp
+	}
+	if err != nil {
+		// the broken template is what the admin is looking for
+		resp.Err = httpError(422, err.Error())
+		return resp
+	}
+	resp.Subject = message.Subject
+	resp.Text = message.Text
+	resp.HTML = message.HTML
+	return resp
+}
@@ -0,0 +1,149 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cheebo/rand"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/issuer"
+)
+
+// CreateServiceAccount creates the service account user with the confidential client
+// it gets tokens with, the secret is returned once and only its hash is stored
+func (s *service) CreateServiceAccount(ctx context.Context, req CreateServiceAccountRequest) (resp *ServiceAccountResponse) {
+	resp = &ServiceAccountResponse{}
+
+	if err := s.admin(ctx); err != nil {
+		resp.Err = err
+		return resp
+	}
+	if req.Name == "" {
+		resp.Err = errInvalidRequest("name is required")
+		return resp
+	}
+	supported := s.supportedScopes()
+	for _, scope := range req.Scopes {
+		if _, ok := supported[scope]; !ok || scope == ScopeOpenID {
+			resp.Err = errInvalidScope("Unknown scope " + scope)
+			return resp
+		}
+	}
+	if req.PublicKey != "" {
+		if _, _, err := issuer.ParsePublicKey(req.PublicKey); err != nil {
+			resp.Err = errInvalidRequest("public_key must be PEM of RSA, P-256 or Ed25519 public key")
+			return resp
+		}
+	}
+
+	secret, secretHash := "", ""
+	if req.PublicKey == "" {
+		secret = rand.RandomAlphaNum(48)
+		secretHash = HashSecret(secret)
+	}
+	now := time.Now()
+	user := &database.UsersModel{
+		StatusId: database.UserStatusActive,
+		Type:     database.UserTypeService,
+		Created:  now,
+		Updated:  now,
+	}
+	client := &database.OAuthClientModel{
+		ClientId:   rand.RandomAlphaNum(24),
+		SecretHash: secretHash,
+		Name:       req.Name,
+		GrantTypes: []string{GrantClientCredentials},
+		Scopes:     req.Scopes,
+		PublicKey:  req.PublicKey,
+		Created:    now,
+		Updated:    now,
+	}
+	err := s.db.Tx(ctx, func(tx database.Database) error {
+		if err := tx.Users().Create(ctx, user); err != nil {
+			return err
+		}
+		client.OwnerId = user.Id
+		return tx.OAuthClients().Create(ctx, client)
+	})
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+
+	resp.UserId = user.Id
+	resp.ClientId = client.ClientId
+	resp.ClientSecret = secret
+	return resp
+}
+
+// RotateClientCredentials replaces the client secret, or the key when the new one is given,
+// the old credentials stop working at once
+func (s *service) RotateClientCredentials(ctx context.Context, req RotateClientCredentialsRequest) (resp *ClientCredentialsResponse) {
+	resp = &ClientCredentialsResponse{}
+
+	if err := s.admin(ctx); err != nil {
+		resp.Err = err
+		return resp
+	}
+	client, err := s.db.OAuthClients().FindByClientID(ctx, req.ClientId)
+	if errors.Is(err, database.ErrNotFound) {
+		resp.Err = errInvalidRequest("Unknown client")
+		return resp
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+	if client.SecretHash == "" && client.PublicKey == "" {
+		resp.Err = errInvalidRequest("Public clients have no credentials")
+		return resp
+	}
+
+	secret := ""
+	if req.PublicKey != "" {
+		if _, _, err := issuer.ParsePublicKey(req.PublicKey); err != nil {
+			resp.Err = errInvalidRequest("public_key must be PEM of RSA, P-256 or Ed25519 public key")
+			return resp
+		}
+		client.PublicKey = req.PublicKey
+		client.SecretHash = ""
+	} else {
+		secret = rand.RandomAlphaNum(48)
+		client.SecretHash = HashSecret(secret)
+		client.PublicKey = ""
+	}
+	client.Updated = time.Now()
+	if err := s.db.OAuthClients().Update(ctx, client); err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+
+	resp.ClientId = client.ClientId
+	resp.ClientSecret = secret
+	return resp
+}
+
+// admin checks the signed in user is an administrator
+func (s *service) admin(ctx context.Context) *Error {
+	userId, authErr := s.caller(ctx)
+	if authErr != nil {
+		return authErr
+	}
+	user, err := s.db.Users().FindByID(ctx, userId)
+	if errors.Is(err, database.ErrNotFound) {
+		return errInvalidToken()
+	}
+	if err != nil {
+		s.log.Error(err)
+		return errServer()
+	}
+	if user.Type != database.UserTypeAdmin {
+		return errAccessDenied("Only administrators manage service accounts")
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cheebo/rand"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+
+	// codeTTL is short, the client exchanges the code right after the redirect
+	codeTTL = time.Minute
+	// consents of the OAuth clients are kept in user_consents with this kind prefix
+	consentKindPrefix = "oauth:"
+)
+
+// Authorize validates the client request and sends the browser to the consent page
+func (s *service) Authorize(ctx context.Context, req AuthorizeRequest) (resp *AuthorizeResponse) {
+	resp = &AuthorizeResponse{}
+
+	_, _, redirect, err := s.validateAuthorize(ctx, req)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if redirect != "" {
+		resp.Redirect = redirect
+		return resp
+	}
+	if s.cfg.ConsentURL == nil || s.cfg.ConsentURL() == "" {
+		s.log.Error("oauth: consent page url is not configured")
+		resp.Err = errServer()
+		return resp
+	}
+	resp.Redirect = s.cfg.ConsentURL() + "?" + req.Query
+	return resp
+}
+
+// Consent describes the request to the consent page of the signed in user
+func (s *service) Consent(ctx context.Context, req AuthorizeRequest) (resp *ConsentResponse) {
+	resp = &ConsentResponse{}
+
+	userId, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	client, scopes, redirect, err := s.validateAuthorize(ctx, req)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if redirect != "" {
+		resp.RedirectTo = redirect
+		return resp
+	}
+
+	granted, dbErr := s.consented(ctx, userId, client.ClientId, scopes)
+	if dbErr != nil {
+		s.log.Error(dbErr)
+		resp.Err = errServer()
+		return resp
+	}
+
+	descriptions := s.supportedScopes()
+	resp.ClientId = client.ClientId
+	resp.ClientName = client.Name
+	resp.Granted = granted
+	for _, scope := range scopes {
+		resp.Scopes = append(resp.Scopes, ScopeInfo{Name: scope, Description: descriptions[scope]})
+	}
+	return resp
+}
+
+// Approve issues the authorization code when the user approved the request
+func (s *service) Approve(ctx context.Context, req ApproveRequest) (resp *ApproveResponse) {
+	resp = &ApproveResponse{}
+
+	userId, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	client, scopes, redirect, err := s.validateAuthorize(ctx, req.AuthorizeRequest)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if redirect != "" {
+		resp.RedirectTo = redirect
+		return resp
+	}
+	if !req.Approve {
+		resp.RedirectTo = errorRedirect(req.RedirectURI, req.State, errAccessDenied("The user denied the request"))
+		return resp
+	}
+
+	if err := s.recordConsent(ctx, userId, client.ClientId, scopes); err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+
+	code := rand.RandomAlphaNum(43)
+	now := time.Now()
+	model := &database.OAuthCodeModel{
+		CodeHash:      HashSecret(code),
+		ClientId:      client.ClientId,
+		UserId:        userId,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		Created:       now,
+		Expires:       now.Add(codeTTL),
+	}
+	if err := s.db.OAuthCodes().Create(ctx, model); err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	resp.RedirectTo = withQuery(req.RedirectURI, params)
+	return resp
+}
+
+// validateAuthorize checks the authorization request. Unknown client and redirect URI are
+// reported to the user with err, other errors are sent to the client with redirect
+func (s *service) validateAuthorize(ctx context.Context, req AuthorizeRequest) (client *database.OAuthClientModel, scopes []string, redirect string, err *Error) {
+	client, dbErr := s.db.OAuthClients().FindByClientID(ctx, req.ClientId)
+	if errors.Is(dbErr, database.ErrNotFound) {
+		return nil, nil, "", errInvalidRequest("Unknown client")
+	}
+	if dbErr != nil {
+		s.log.Error(dbErr)
+		return nil, nil, "", errServer()
+	}
+	// redirect URIs are matched exactly, no prefixes or wildcards
+	if req.RedirectURI == "" || !contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, "", errInvalidRequest("Redirect URI is not registered for the client")
+	}
+
+	fail := func(e *Error) (*database.OAuthClientModel, []string, string, *Error) {
+		return nil, nil, errorRedirect(req.RedirectURI, req.State, e), nil
+	}
+	if req.ResponseType != "code" {
+		return fail(errUnsupportedResponseType())
+	}
+	if !contains(client.GrantTypes, GrantAuthorizationCode) {
+		return fail(errUnauthorizedClient("Authorization code grant is not allowed for the client"))
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return fail(errInvalidRequest("PKCE code challenge with S256 method is required"))
+	}
+	scopes, err = s.scopes(client, req.Scope)
+	if err != nil {
+		return fail(err)
+	}
+	return client, scopes, "", nil
+}
+
+// scopes returns sorted requested scopes, the client ones when the request has none
+func (s *service) scopes(client *database.OAuthClientModel, requested string) ([]string, *Error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		scopes = append(scopes, client.Scopes...)
+	}
+	supported := s.supportedScopes()
+	for _, scope := range scopes {
+		if _, ok := supported[scope]; !ok {
+			return nil, errInvalidScope("Unknown scope " + scope)
+		}
+		if !contains(client.Scopes, scope) {
+			return nil, errInvalidScope("Scope " + scope + " is not allowed for the client")
+		}
+	}
+	sort.Strings(scopes)
+	return dedup(scopes), nil
+}
+
+// supportedScopes maps scopes to descriptions shown on the consent page
+func (s *service) supportedScopes() map[string]string {
+	scopes := map[string]string{}
+	for name, description := range standardScopes {
+		scopes[name] = description
+	}
+	if s.cfg.Scopes == nil {
+		return scopes
+	}
+	for name, description := range s.cfg.Scopes() {
+		d, _ := description.(string)
+		scopes[name] = d
+	}
+	return scopes
+}
+
+// consented reports whether the user already granted all the scopes to the client
+func (s *service) consented(ctx context.Context, userId uint64, clientId string, scopes []string) (bool, error) {
+	consents, err := s.db.Consents().FindByUserID(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range consents {
+		if c.Kind != consentKindPrefix+clientId || c.Revoked != nil {
+			continue
+		}
+		granted := strings.Fields(c.Version)
+		all := true
+		for _, scope := range scopes {
+			all = all && contains(granted, scope)
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordConsent replaces the consent of the user to the client, granted scopes are accumulated
+func (s *service) recordConsent(ctx context.Context, userId uint64, clientId string, scopes []string) error {
+	return s.db.Tx(ctx, func(tx database.Database) error {
+		consents, err := tx.Consents().FindByUserID(ctx, userId)
+		if err != nil {
+			return err
+		}
+		granted := append([]string(nil), scopes...)
+		for _, c := range consents {
+			if c.Kind != consentKindPrefix+clientId || c.Revoked != nil {
+				continue
+			}
+			granted = append(granted, strings.Fields(c.Version)...)
+			if err := tx.Consents().Revoke(ctx, c.Id); err != nil {
+				return err
+			}
+		}
+		sort.Strings(granted)
+		return tx.Consents().Create(ctx, &database.ConsentModel{
+			UserId:  userId,
+			Kind:    consentKindPrefix + clientId,
+			Version: strings.Join(dedup(granted), " "),
+			Granted: time.Now(),
+		})
+	})
+}
+
+// caller returns id of the signed in user
+func (s *service) caller(ctx context.Context) (uint64, *Error) {
+	sid := s.session.SessionId(ctx)
+	if len(sid) == 0 {
+		return 0, errInvalidToken()
+	}
+	history, err := s.db.AuthenticationHistory().FindBySecret(ctx, string(sid))
+	if errors.Is(err, database.ErrNotFound) {
+		return 0, errInvalidToken()
+	}
+	if err != nil {
+		s.log.Error(err)
+		return 0, errServer()
+	}
+	return history.UserId, nil
+}
+
+// verifyPKCE checks the verifier against S256 challenge (RFC 7636 section 4.6)
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func errorRedirect(redirectURI, state string, e *Error) string {
+	params := url.Values{"error": {e.Code}}
+	if e.Description != "" {
+		params.Set("error_description", e.Description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return withQuery(redirectURI, params)
+}
+
+// withQuery adds params to the query of the registered redirect URI
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// dedup removes repeated values of the sorted list
+func dedup(sorted []string) []string {
+	out := sorted[:0]
+	for i, v := range sorted {
+		if i == 0 || v != sorted[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,214 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/issuer"
+)
+
+const (
+	testRedirect = "https://app.example.com/callback"
+	testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// webClient registers the public client of the authorization code flow
+func (env *testEnv) webClient(t *testing.T) *database.OAuthClientModel {
+	t.Helper()
+	return env.client(t, database.OAuthClientModel{
+		ClientId:     "web",
+		Name:         "Web App",
+		RedirectURIs: []string{testRedirect},
+		GrantTypes:   []string{GrantAuthorizationCode, GrantRefreshToken},
+		Scopes:       []string{ScopeOpenID, ScopeEmail},
+	}, false)
+}
+
+// webRequest is the valid authorization request of the web client
+func webRequest() AuthorizeRequest {
+	return AuthorizeRequest{
+		ResponseType:        "code",
+		ClientId:            "web",
+		RedirectURI:         testRedirect,
+		Scope:               "openid email",
+		State:               "xyz",
+		CodeChallenge:       challenge(testVerifier),
+		CodeChallengeMethod: "S256",
+		Nonce:               "n-0S6",
+	}
+}
+
+// query returns the query of the redirect to the client
+func query(t *testing.T, redirect string) url.Values {
+	t.Helper()
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(redirect, testRedirect+"?") {
+		t.Fatalf("redirect to %q", redirect)
+	}
+	return u.Query()
+}
+
+func TestAuthorizeRedirectURI(t *testing.T) {
+	env := newEnv(t, true)
+	env.webClient(t)
+	ctx := context.Background()
+
+	req := webRequest()
+	req.Query = "client_id=web"
+	if resp := env.srv.Authorize(ctx, req); resp.Err != nil || resp.Redirect != "https://cms.example.com/consent?client_id=web" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	// the unregistered URIs are reported to the user and never redirected to
+	for _, uri := range []string{
+		"",
+		testRedirect + "/",
+		testRedirect + "/evil",
+		testRedirect + "?next=https://evil.example.com",
+		"https://app.example.com/Callback",
+		"https://app.example.com.evil.example.com/callback",
+		"http://app.example.com/callback",
+	} {
+		req := webRequest()
+		req.RedirectURI = uri
+		if resp := env.srv.Authorize(ctx, req); resp.Err == nil || resp.Redirect != "" {
+			t.Errorf("%q: response = %+v", uri, resp)
+		}
+	}
+	req = webRequest()
+	req.ClientId = "unknown"
+	if resp := env.srv.Authorize(ctx, req); resp.Err == nil || resp.Redirect != "" {
+		t.Errorf("unknown client: response = %+v", resp)
+	}
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	env := newEnv(t, true)
+	env.webClient(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		modify func(*AuthorizeRequest)
+		error  string
+	}{
+		{"response type", func(r *AuthorizeRequest) { r.ResponseType = "token" }, "unsupported_response_type"},
+		{"no challenge", func(r *AuthorizeRequest) { r.CodeChallenge, r.CodeChallengeMethod = "", "" }, "invalid_request"},
+		{"plain challenge", func(r *AuthorizeRequest) { r.CodeChallenge, r.CodeChallengeMethod = testVerifier, "plain" }, "invalid_request"},
+		{"unknown scope", func(r *AuthorizeRequest) { r.Scope = "openid admin" }, "invalid_scope"},
+		{"scope of another client", func(r *AuthorizeRequest) { r.Scope = "openid phone" }, "invalid_scope"},
+	} {
+		req := webRequest()
+		tc.modify(&req)
+		resp := env.srv.Authorize(ctx, req)
+		if resp.Err != nil {
+			t.Errorf("%s: err = %v", tc.name, resp.Err)
+			continue
+		}
+		// the errors are sent back to the client with the state
+		params := query(t, resp.Redirect)
+		if params.Get("error") != tc.error || params.Get("state") != "xyz" {
+			t.Errorf("%s: redirect = %q", tc.name, resp.Redirect)
+		}
+	}
+}
+
+func TestAuthorizationCode(t *testing.T) {
+	env := newEnv(t, true)
+	env.webClient(t)
+	token, userId := env.signIn(t, "user@example.com")
+	ctx := env.context(t, token)
+	public := ClientCredentials{ClientId: "web"}
+
+	consent := env.srv.Consent(ctx, webRequest())
+	if consent.Err != nil {
+		t.Fatal(consent.Err)
+	}
+	if consent.ClientName != "Web App" || consent.Granted || len(consent.Scopes) != 2 {
+		t.Errorf("consent = %+v", consent)
+	}
+	if resp := env.srv.Consent(context.Background(), webRequest()); resp.Err == nil {
+		t.Error("consent page is shown without the signed in user")
+	}
+
+	denied := env.srv.Approve(ctx, ApproveRequest{AuthorizeRequest: webRequest()})
+	if denied.Err != nil || query(t, denied.RedirectTo).Get("error") != "access_denied" {
+		t.Errorf("denied = %+v", denied)
+	}
+
+	approved := env.srv.Approve(ctx, ApproveRequest{AuthorizeRequest: webRequest(), Approve: true})
+	if approved.Err != nil {
+		t.Fatal(approved.Err)
+	}
+	params := query(t, approved.RedirectTo)
+	code := params.Get("code")
+	if code == "" || params.Get("state") != "xyz" {
+		t.Fatalf("approved = %+v", approved)
+	}
+	if resp := env.srv.Consent(ctx, webRequest()); resp.Err != nil || !resp.Granted {
+		t.Errorf("consent after approval = %+v", resp)
+	}
+
+	exchange := TokenRequest{Client: public, GrantType: GrantAuthorizationCode, Code: code, RedirectURI: testRedirect, CodeVerifier: testVerifier}
+	for name, modify := range map[string]func(*TokenRequest){
+		"wrong verifier": func(r *TokenRequest) { r.CodeVerifier = strings.Repeat("a", 43) },
+		"no verifier":    func(r *TokenRequest) { r.CodeVerifier = "" },
+		"other redirect": func(r *TokenRequest) { r.RedirectURI = testRedirect + "/" },
+		"wrong code":     func(r *TokenRequest) { r.Code = code + "x" },
+	} {
+		req := exchange
+		modify(&req)
+		if resp := env.srv.Token(ctx, req); resp.Err == nil {
+			t.Errorf("%s: code is exchanged", name)
+		}
+	}
+
+	tokens := env.srv.Token(ctx, exchange)
+	if tokens.Err != nil {
+		t.Fatal(tokens.Err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.IdToken == "" || tokens.Scope != "email openid" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	id, err := env.issuer.Parse(tokens.IdToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Typ != issuer.TypeID || !id.Aud.Contains("web") || id.Nonce != "n-0S6" || id.Email != "user@example.com" || id.Sub != strconv.FormatUint(userId, 10) {
+		t.Errorf("ID token claims = %+v", id)
+	}
+	if resp := env.srv.Token(ctx, exchange); resp.Err == nil {
+		t.Error("code is exchanged twice")
+	}
+
+	// refresh tokens are rotated
+	refresh := TokenRequest{Client: public, GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken, Scope: ScopeEmail}
+	refreshed := env.srv.Token(ctx, refresh)
+	if refreshed.Err != nil {
+		t.Fatal(refreshed.Err)
+	}
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == tokens.RefreshToken || refreshed.Scope != ScopeEmail || refreshed.IdToken != "" {
+		t.Errorf("refreshed = %+v", refreshed)
+	}
+	if resp := env.srv.Token(ctx, refresh); resp.Err == nil {
+		t.Error("refresh token is used twice")
+	}
+	widen := TokenRequest{Client: public, GrantType: GrantRefreshToken, RefreshToken: refreshed.RefreshToken, Scope: "email phone"}
+	if resp := env.srv.Token(ctx, widen); resp.Err == nil {
+		t.Error("refresh widened the scope")
+	}
+}
@@ -0,0 +1,167 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+func DecodeIntrospectRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	body := IntrospectRequest{
+		Client:        clientCredentials(r),
+		Token:         r.PostForm.Get("token"),
+		TokenTypeHint: r.PostForm.Get("token_type_hint"),
+	}
+	return body, nil
+}
+
+func DecodeRevokeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	body := RevokeRequest{
+		Client:        clientCredentials(r),
+		Token:         r.PostForm.Get("token"),
+		TokenTypeHint: r.PostForm.Get("token_type_hint"),
+	}
+	return body, nil
+}
+
+// clientCredentials supports client_secret_basic, client_secret_post and private_key_jwt,
+// must be called after ParseForm
+func clientCredentials(r *http.Request) ClientCredentials {
+	if id, secret, ok := r.BasicAuth(); ok {
+		return ClientCredentials{ClientId: id, ClientSecret: secret}
+	}
+	return ClientCredentials{
+		ClientId:      r.PostForm.Get("client_id"),
+		ClientSecret:  r.PostForm.Get("client_secret"),
+		AssertionType: r.PostForm.Get("client_assertion_type"),
+		Assertion:     r.PostForm.Get("client_assertion"),
+	}
+}
+
+func DecodeAuthorizeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return authorizeRequest(r.URL.Query(), r.URL.RawQuery), nil
+}
+
+func DecodeApproveRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body ApproveRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func DecodeTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	body := TokenRequest{
+		Client:       clientCredentials(r),
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+		DeviceCode:   r.PostForm.Get("device_code"),
+	}
+	return body, nil
+}
+
+func DecodeDeviceAuthorizationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	body := DeviceAuthorizationRequest{
+		Client: clientCredentials(r),
+		Scope:  r.PostForm.Get("scope"),
+	}
+	return body, nil
+}
+
+func DecodeDeviceRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return DeviceRequest{UserCode: r.URL.Query().Get("user_code")}, nil
+}
+
+func DecodeDeviceApproveRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body DeviceApproveRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// DecodeUserInfoRequest takes the bearer token from the header or the form (RFC 6750 section 2)
+func DecodeUserInfoRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return UserInfoRequest{Token: strings.TrimSpace(h[7:])}, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	return UserInfoRequest{Token: r.PostForm.Get("access_token")}, nil
+}
+
+func DecodeDiscoveryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+func DecodeLogoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	body := LogoutRequest{
+		IdTokenHint:           r.Form.Get("id_token_hint"),
+		ClientId:              r.Form.Get("client_id"),
+		PostLogoutRedirectURI: r.Form.Get("post_logout_redirect_uri"),
+		State:                 r.Form.Get("state"),
+	}
+	return body, nil
+}
+
+func DecodeCreateServiceAccountRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body CreateServiceAccountRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// DecodeRotateClientCredentialsRequest accepts empty body, a new secret is generated then
+func DecodeRotateClientCredentialsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body RotateClientCredentialsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	body.ClientId = mux.Vars(r)["client_id"]
+	return body, nil
+}
+
+func authorizeRequest(q url.Values, raw string) AuthorizeRequest {
+	return AuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientId:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+		Nonce:               q.Get("nonce"),
+		Query:               raw,
+	}
+}
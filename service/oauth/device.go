@@ -0,0 +1,262 @@
+package oauth
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cheebo/rand"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+const (
+	GrantDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+	deviceCodeTTL = 10 * time.Minute
+	// deviceInterval is the polling interval in seconds, slow_down adds deviceSlowDown to it
+	deviceInterval = 5
+	deviceSlowDown = 5
+	// user codes have no vowels and look-alike letters (RFC 8628 section 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+// DeviceAuthorization starts the device flow, the user enters the user code on the verification page
+func (s *service) DeviceAuthorization(ctx context.Context, req DeviceAuthorizationRequest) (resp *DeviceAuthorizationResponse) {
+	resp = &DeviceAuthorizationResponse{}
+
+	client, authErr := s.authenticateClient(ctx, req.Client, true)
+	if authErr != nil {
+		resp.Err = authErr
+		return resp
+	}
+	if !contains(client.GrantTypes, GrantDeviceCode) {
+		resp.Err = errUnauthorizedClient("Device code grant is not allowed for the client")
+		return resp
+	}
+	if s.cfg.DeviceURL == nil || s.cfg.DeviceURL() == "" {
+		s.log.Error("oauth: device verification page url is not configured")
+		resp.Err = errServer()
+		return resp
+	}
+	scopes, scopeErr := s.scopes(client, req.Scope)
+	if scopeErr != nil {
+		resp.Err = scopeErr
+		return resp
+	}
+
+	userCode, err := newUserCode()
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+	deviceCode := rand.RandomAlphaNum(43)
+	now := time.Now()
+	err = s.db.DeviceCodes().Create(ctx, &database.DeviceCodeModel{
+		DeviceCodeHash: HashSecret(deviceCode),
+		UserCodeHash:   HashSecret(normalizeUserCode(userCode)),
+		ClientId:       client.ClientId,
+		Scope:          strings.Join(scopes, " "),
+		Interval:       deviceInterval,
+		Created:        now,
+		Expires:        now.Add(deviceCodeTTL),
+	})
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+
+	resp.DeviceCode = deviceCode
+	resp.UserCode = userCode
+	resp.VerificationURI = s.cfg.DeviceURL()
+	resp.VerificationURIComplete = withQuery(s.cfg.DeviceURL(), url.Values{"user_code": {userCode}})
+	resp.ExpiresIn = int64(deviceCodeTTL.Seconds())
+	resp.Interval = deviceInterval
+	return resp
+}
+
+// Device describes the pending request of the user code to the verification page
+func (s *service) Device(ctx context.Context, req DeviceRequest) (resp *ConsentResponse) {
+	resp = &ConsentResponse{}
+
+	userId, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	code, client, err := s.pendingDevice(ctx, req.UserCode)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+
+	scopes := strings.Fields(code.Scope)
+	granted, dbErr := s.consented(ctx, userId, client.ClientId, scopes)
+	if dbErr != nil {
+		s.log.Error(dbErr)
+		resp.Err = errServer()
+		return resp
+	}
+
+	descriptions := s.supportedScopes()
+	resp.ClientId = client.ClientId
+	resp.ClientName = client.Name
+	resp.Granted = granted
+	for _, scope := range scopes {
+		resp.Scopes = append(resp.Scopes, ScopeInfo{Name: scope, Description: descriptions[scope]})
+	}
+	return resp
+}
+
+// DeviceApprove grants or denies the request of the user code, the device gets the tokens on the next poll
+func (s *service) DeviceApprove(ctx context.Context, req DeviceApproveRequest) (resp *DeviceApproveResponse) {
+	resp = &DeviceApproveResponse{}
+
+	userId, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	code, client, err := s.pendingDevice(ctx, req.UserCode)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+
+	var dbErr error
+	if req.Approve {
+		if err := s.recordConsent(ctx, userId, client.ClientId, strings.Fields(code.Scope)); err != nil {
+			s.log.Error(err)
+			resp.Err = errServer()
+			return resp
+		}
+		dbErr = s.db.DeviceCodes().Approve(ctx, code.Id, userId)
+	} else {
+		dbErr = s.db.DeviceCodes().Deny(ctx, code.Id)
+	}
+	if errors.Is(dbErr, database.ErrNotFound) {
+		resp.Err = errInvalidRequest("Code is invalid or expired")
+		return resp
+	}
+	if dbErr != nil {
+		s.log.Error(dbErr)
+		resp.Err = errServer()
+		return resp
+	}
+	resp.Approved = req.Approve
+	return resp
+}
+
+// pendingDevice returns the not expired undecided request of the user code
+func (s *service) pendingDevice(ctx context.Context, userCode string) (*database.DeviceCodeModel, *database.OAuthClientModel, *Error) {
+	code, err := s.db.DeviceCodes().FindByUserCodeHash(ctx, HashSecret(normalizeUserCode(userCode)))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, errInvalidRequest("Code is invalid or expired")
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, nil, errServer()
+	}
+	if code.Approved != nil || code.Denied != nil || !code.Expires.After(time.Now()) {
+		return nil, nil, errInvalidRequest("Code is invalid or expired")
+	}
+
+	client, err := s.db.OAuthClients().FindByClientID(ctx, code.ClientId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, errInvalidRequest("Code is invalid or expired")
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, nil, errServer()
+	}
+	return code, client, nil
+}
+
+// deviceCode exchanges the approved device code, pending codes report when to poll again (RFC 8628 section 3.5)
+func (s *service) deviceCode(ctx context.Context, client *database.OAuthClientModel, req TokenRequest) (*TokenResponse, *Error) {
+	if !contains(client.GrantTypes, GrantDeviceCode) {
+		return nil, errUnauthorizedClient("Device code grant is not allowed for the client")
+	}
+	code, err := s.db.DeviceCodes().FindByDeviceCodeHash(ctx, HashSecret(req.DeviceCode))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidGrant("Device code is invalid")
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+
+	now := time.Now()
+	switch {
+	case code.ClientId != client.ClientId || code.Used != nil:
+		return nil, errInvalidGrant("Device code is invalid")
+	case !code.Expires.After(now):
+		return nil, errExpiredToken()
+	case code.Denied != nil:
+		return nil, errDeviceDenied()
+	case code.Approved == nil:
+		interval := code.Interval
+		slow := code.LastPolled != nil && now.Sub(*code.LastPolled) < time.Duration(interval)*time.Second
+		if slow {
+			interval += deviceSlowDown
+		}
+		if err := s.db.DeviceCodes().Poll(ctx, code.Id, now, interval); err != nil {
+			s.log.Error(err)
+			return nil, errServer()
+		}
+		if slow {
+			return nil, errSlowDown()
+		}
+		return nil, errAuthorizationPending()
+	}
+
+	err = s.db.DeviceCodes().Use(ctx, code.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidGrant("Device code is invalid")
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	return s.issue(ctx, client, code.UserId, code.Scope, "")
+}
+
+// newUserCode returns 8 random letters of userCodeAlphabet formatted as XXXX-XXXX
+func newUserCode() (string, error) {
+	code := make([]byte, 0, 9)
+	buf := make([]byte, 1)
+	// bytes above the largest multiple of the alphabet length are skipped to keep letters uniform
+	limit := byte(256 - 256%len(userCodeAlphabet))
+	for len(code) < 9 {
+		if len(code) == 4 {
+			code = append(code, '-')
+			continue
+		}
+		if _, err := cryptorand.Read(buf); err != nil {
+			return "", err
+		}
+		if buf[0] >= limit {
+			continue
+		}
+		code = append(code, userCodeAlphabet[int(buf[0])%len(userCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode makes the user input case insensitive and ignores separators
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if !strings.ContainsRune(userCodeAlphabet, r) {
+			return -1
+		}
+		return r
+	}, code)
+}
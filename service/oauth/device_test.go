@@ -0,0 +1,126 @@
+package oauth
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+// errorCode returns the OAuth error code of err, an empty string without error
+func errorCode(err error) string {
+	if e, ok := err.(*Error); ok && e != nil {
+		return e.Code
+	}
+	return ""
+}
+
+var userCodeFormat = regexp.MustCompile(`^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`)
+
+func TestDeviceFlow(t *testing.T) {
+	env := newEnv(t, true)
+	env.client(t, database.OAuthClientModel{
+		ClientId:   "tv",
+		Name:       "TV",
+		GrantTypes: []string{GrantDeviceCode},
+		Scopes:     []string{ScopeOpenID, ScopeEmail},
+	}, false)
+	token, _ := env.signIn(t, "user@example.com")
+	ctx := env.context(t, token)
+	device := ClientCredentials{ClientId: "tv"}
+
+	start := env.srv.DeviceAuthorization(ctx, DeviceAuthorizationRequest{Client: device, Scope: "openid email"})
+	if start.Err != nil {
+		t.Fatal(start.Err)
+	}
+	if !userCodeFormat.MatchString(start.UserCode) || start.VerificationURI != "https://cms.example.com/device" ||
+		start.VerificationURIComplete != "https://cms.example.com/device?user_code="+start.UserCode || start.Interval != deviceInterval {
+		t.Errorf("response = %+v", start)
+	}
+	poll := TokenRequest{Client: device, GrantType: GrantDeviceCode, DeviceCode: start.DeviceCode}
+
+	// the device polls faster than the interval and is slowed down every time
+	for n, want := range []string{"authorization_pending", "slow_down", "slow_down"} {
+		if code := errorCode(env.srv.Token(ctx, poll).Err); code != want {
+			t.Errorf("poll %d: error = %q, want %q", n, code, want)
+		}
+	}
+	polled, err := env.db.DeviceCodes().FindByDeviceCodeHash(context.Background(), HashSecret(start.DeviceCode))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polled.Interval != deviceInterval+2*deviceSlowDown {
+		t.Errorf("interval = %d", polled.Interval)
+	}
+
+	// the user code is typed in any case and without the separator
+	typed := strings.ToLower(strings.ReplaceAll(start.UserCode, "-", ""))
+	page := env.srv.Device(ctx, DeviceRequest{UserCode: typed})
+	if page.Err != nil || page.ClientName != "TV" || len(page.Scopes) != 2 || page.Granted {
+		t.Errorf("verification page = %+v", page)
+	}
+	if resp := env.srv.Device(context.Background(), DeviceRequest{UserCode: typed}); resp.Err == nil {
+		t.Error("verification page is shown without the signed in user")
+	}
+	if resp := env.srv.DeviceApprove(ctx, DeviceApproveRequest{UserCode: typed, Approve: true}); resp.Err != nil || !resp.Approved {
+		t.Fatalf("approve = %+v", resp)
+	}
+	if resp := env.srv.DeviceApprove(ctx, DeviceApproveRequest{UserCode: typed, Approve: true}); resp.Err == nil {
+		t.Error("user code is approved twice")
+	}
+
+	tokens := env.srv.Token(ctx, poll)
+	if tokens.Err != nil {
+		t.Fatal(tokens.Err)
+	}
+	if tokens.AccessToken == "" || tokens.IdToken == "" || tokens.Scope != "email openid" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	if code := errorCode(env.srv.Token(ctx, poll).Err); code != "invalid_grant" {
+		t.Errorf("second exchange: error = %q", code)
+	}
+
+	// the denied request is reported to the device
+	denied := env.srv.DeviceAuthorization(ctx, DeviceAuthorizationRequest{Client: device, Scope: ScopeOpenID})
+	if denied.Err != nil {
+		t.Fatal(denied.Err)
+	}
+	if resp := env.srv.DeviceApprove(ctx, DeviceApproveRequest{UserCode: denied.UserCode}); resp.Err != nil || resp.Approved {
+		t.Fatalf("deny = %+v", resp)
+	}
+	if code := errorCode(env.srv.Token(ctx, TokenRequest{Client: device, GrantType: GrantDeviceCode, DeviceCode: denied.DeviceCode}).Err); code != "access_denied" {
+		t.Errorf("denied: error = %q", code)
+	}
+}
+
+func TestDeviceAuthorizationErrors(t *testing.T) {
+	env := newEnv(t, true)
+	env.client(t, database.OAuthClientModel{ClientId: "web", GrantTypes: []string{GrantAuthorizationCode}, Scopes: []string{ScopeOpenID}}, false)
+	env.client(t, database.OAuthClientModel{ClientId: "tv", GrantTypes: []string{GrantDeviceCode}, Scopes: []string{ScopeOpenID}}, false)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		req  DeviceAuthorizationRequest
+		code string
+	}{
+		{"grant", DeviceAuthorizationRequest{Client: ClientCredentials{ClientId: "web"}}, "unauthorized_client"},
+		{"scope", DeviceAuthorizationRequest{Client: ClientCredentials{ClientId: "tv"}, Scope: "email"}, "invalid_scope"},
+		{"client", DeviceAuthorizationRequest{Client: ClientCredentials{ClientId: "unknown"}}, "invalid_client"},
+	} {
+		if code := errorCode(env.srv.DeviceAuthorization(ctx, tc.req).Err); code != tc.code {
+			t.Errorf("%s: error = %q, want %q", tc.name, code, tc.code)
+		}
+	}
+	// the device code of another client is not exchanged
+	start := env.srv.DeviceAuthorization(ctx, DeviceAuthorizationRequest{Client: ClientCredentials{ClientId: "tv"}})
+	if start.Err != nil {
+		t.Fatal(start.Err)
+	}
+	req := TokenRequest{Client: ClientCredentials{ClientId: "web"}, GrantType: GrantDeviceCode, DeviceCode: start.DeviceCode}
+	if code := errorCode(env.srv.Token(ctx, req).Err); code != "unauthorized_client" {
+		t.Errorf("other client: error = %q", code)
+	}
+}
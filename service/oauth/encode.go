@@ -0,0 +1,125 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// OAuth errors are part of the response, so endpoints return them in the response
+// and the encoders write them in RFC 6749 format
+
+// EncodeAuthorizeResponse redirects the browser to the consent page or back to the client
+func EncodeAuthorizeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(AuthorizeResponse)
+	if resp.Err != nil {
+		return encodeJSON(w, nil, resp.Err)
+	}
+	w.Header().Set("Location", resp.Redirect)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusFound)
+	return nil
+}
+
+func EncodeConsentResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(ConsentResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeApproveResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(ApproveResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeTokenResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(TokenResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeUserInfoResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(UserInfoResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeDiscoveryResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(DiscoveryResponse)
+	if resp.Err != nil {
+		return encodeJSON(w, nil, resp.Err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// EncodeLogoutResponse redirects the browser, 204 is written when there is nowhere to redirect
+func EncodeLogoutResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(LogoutResponse)
+	if resp.Err != nil {
+		return encodeJSON(w, nil, resp.Err)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if resp.Redirect == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Location", resp.Redirect)
+	w.WriteHeader(http.StatusFound)
+	return nil
+}
+
+func EncodeServiceAccountResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(ServiceAccountResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeClientCredentialsResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(ClientCredentialsResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeDeviceAuthorizationResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(DeviceAuthorizationResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeDeviceApproveResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(DeviceApproveResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeIntrospectResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(IntrospectResponse)
+	return encodeJSON(w, resp, resp.Err)
+}
+
+func EncodeRevokeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(RevokeResponse)
+	if resp.Err != nil {
+		return encodeJSON(w, nil, resp.Err)
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func encodeJSON(w http.ResponseWriter, body interface{}, err error) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err != nil {
+		var oauthErr *Error
+		if !errors.As(err, &oauthErr) {
+			oauthErr = errServer()
+		}
+		switch oauthErr.Code {
+		case "invalid_client":
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		case "invalid_token", "insufficient_scope":
+			w.Header().Set("WWW-Authenticate", `Bearer error="`+oauthErr.Code+`"`)
+		}
+		w.WriteHeader(oauthErr.Status)
+		return json.NewEncoder(w).Encode(oauthErr)
+	}
+	return json.NewEncoder(w).Encode(body)
+}
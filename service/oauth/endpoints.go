@@ -0,0 +1,118 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/nori-io/nori-common/endpoint"
+)
+
+func MakeIntrospectEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(IntrospectRequest)
+		resp := s.Introspect(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeRevokeEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(RevokeRequest)
+		resp := s.Revoke(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeAuthorizeEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(AuthorizeRequest)
+		resp := s.Authorize(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeConsentEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(AuthorizeRequest)
+		resp := s.Consent(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeApproveEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(ApproveRequest)
+		resp := s.Approve(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeTokenEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(TokenRequest)
+		resp := s.Token(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeUserInfoEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(UserInfoRequest)
+		resp := s.UserInfo(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeDiscoveryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		resp := s.Discovery(ctx)
+		return *resp, nil
+	}
+}
+
+func MakeLogoutEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(LogoutRequest)
+		resp := s.Logout(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeCreateServiceAccountEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(CreateServiceAccountRequest)
+		resp := s.CreateServiceAccount(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeRotateClientCredentialsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(RotateClientCredentialsRequest)
+		resp := s.RotateClientCredentials(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeDeviceAuthorizationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(DeviceAuthorizationRequest)
+		resp := s.DeviceAuthorization(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeDeviceEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(DeviceRequest)
+		resp := s.Device(ctx, req)
+		return *resp, nil
+	}
+}
+
+func MakeDeviceApproveEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		req := r.(DeviceApproveRequest)
+		resp := s.DeviceApprove(ctx, req)
+		return *resp, nil
+	}
+}
@@ -0,0 +1,78 @@
+package oauth
+
+// Error is the OAuth 2.0 error response (RFC 6749 section 5.2)
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func errInvalidRequest(description string) *Error {
+	return &Error{Code: "invalid_request", Description: description, Status: 400}
+}
+
+func errInvalidClient() *Error {
+	return &Error{Code: "invalid_client", Description: "Client authentication failed", Status: 401}
+}
+
+func errUnauthorizedClient(description string) *Error {
+	return &Error{Code: "unauthorized_client", Description: description, Status: 400}
+}
+
+func errServer() *Error {
+	return &Error{Code: "server_error", Status: 500}
+}
+
+func errInvalidGrant(description string) *Error {
+	return &Error{Code: "invalid_grant", Description: description, Status: 400}
+}
+
+func errInvalidScope(description string) *Error {
+	return &Error{Code: "invalid_scope", Description: description, Status: 400}
+}
+
+func errUnsupportedGrantType() *Error {
+	return &Error{Code: "unsupported_grant_type", Status: 400}
+}
+
+func errUnsupportedResponseType() *Error {
+	return &Error{Code: "unsupported_response_type", Description: "Only code response type is supported", Status: 400}
+}
+
+func errAccessDenied(description string) *Error {
+	return &Error{Code: "access_denied", Description: description, Status: 403}
+}
+
+// errInvalidToken is returned to the signed in user endpoints (RFC 6750 section 3.1)
+func errInvalidToken() *Error {
+	return &Error{Code: "invalid_token", Status: 401}
+}
+
+func errInsufficientScope(description string) *Error {
+	return &Error{Code: "insufficient_scope", Description: description, Status: 403}
+}
+
+// device flow token errors (RFC 8628 section 3.5)
+
+func errAuthorizationPending() *Error {
+	return &Error{Code: "authorization_pending", Status: 400}
+}
+
+func errSlowDown() *Error {
+	return &Error{Code: "slow_down", Status: 400}
+}
+
+func errExpiredToken() *Error {
+	return &Error{Code: "expired_token", Description: "Device code expired", Status: 400}
+}
+
+func errDeviceDenied() *Error {
+	return &Error{Code: "access_denied", Description: "The user denied the request", Status: 400}
+}
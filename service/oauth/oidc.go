@@ -0,0 +1,237 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/issuer"
+)
+
+const (
+	ScopeOpenID = "openid"
+	ScopeEmail  = "email"
+	ScopePhone  = "phone"
+)
+
+// standardScopes are OpenID Connect scopes, oauth.scopes config overrides their descriptions
+var standardScopes = map[string]string{
+	ScopeOpenID: "Sign in with your account",
+	ScopeEmail:  "Your email address",
+	ScopePhone:  "Your phone number",
+}
+
+// UserInfo returns claims of the user the access token was issued for
+func (s *service) UserInfo(ctx context.Context, req UserInfoRequest) (resp *UserInfoResponse) {
+	resp = &UserInfoResponse{}
+
+	// only tokens issued to OAuth clients are accepted, the sign in tokens have no client
+	claims, err := s.accessToken(ctx, req.Token)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+	if claims == nil || claims.ClientId == "" {
+		resp.Err = errInvalidToken()
+		return resp
+	}
+	if claims.Jti != "" {
+		revoked, err := s.revoked.IsRevoked(ctx, claims.Jti)
+		if err != nil {
+			s.log.Error(err)
+			resp.Err = errServer()
+			return resp
+		}
+		if revoked {
+			resp.Err = errInvalidToken()
+			return resp
+		}
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !contains(scopes, ScopeOpenID) {
+		resp.Err = errInsufficientScope("openid scope is required")
+		return resp
+	}
+	userId, err := strconv.ParseUint(claims.Sub, 10, 64)
+	if err != nil {
+		resp.Err = errInvalidToken()
+		return resp
+	}
+
+	active, err := s.active(ctx, userId)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+	if !active {
+		resp.Err = errInvalidToken()
+		return resp
+	}
+	info, err := s.profile(ctx, userId, scopes)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+	return info
+}
+
+// Discovery returns the provider metadata, the endpoints are relative to the issuer URL
+func (s *service) Discovery(ctx context.Context) (resp *DiscoveryResponse) {
+	resp = &DiscoveryResponse{}
+
+	if s.issuer == nil || s.cfg.Issuer == nil || s.cfg.Issuer() == "" {
+		s.log.Error("oauth: discovery requires the native issuer and jwt.iss set to the public url")
+		resp.Err = errServer()
+		return resp
+	}
+	iss := s.cfg.Issuer()
+	base := strings.TrimRight(iss, "/")
+
+	scopes := make([]string, 0)
+	for name := range s.supportedScopes() {
+		scopes = append(scopes, name)
+	}
+	sort.Strings(scopes)
+
+	return &DiscoveryResponse{
+		Issuer:                            iss,
+		AuthorizationEndpoint:             base + "/oauth/authorize",
+		TokenEndpoint:                     base + "/oauth/token",
+		UserInfoEndpoint:                  base + "/oauth/userinfo",
+		JwksURI:                           base + "/.well-known/jwks.json",
+		EndSessionEndpoint:                base + "/oauth/logout",
+		DeviceAuthorizationEndpoint:       base + "/oauth/device_authorization",
+		RevocationEndpoint:                base + "/oauth/revoke",
+		IntrospectionEndpoint:             base + "/oauth/introspect",
+		ScopesSupported:                   scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials, GrantDeviceCode},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  s.issuer.Algorithms()[:1],
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "private_key_jwt", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{"iss", "sub", "aud", "exp", "iat", "nonce",
+			"email", "email_verified", "phone_number", "phone_number_verified"},
+	}
+}
+
+// Logout ends the session of the relying party: the refresh tokens of the client are revoked
+// and the browser is sent to the CMS logout page which signs the user out
+func (s *service) Logout(ctx context.Context, req LogoutRequest) (resp *LogoutResponse) {
+	resp = &LogoutResponse{}
+
+	clientId := req.ClientId
+	var userId uint64
+	if req.IdTokenHint != "" {
+		var claims *issuer.Claims
+		if s.issuer != nil {
+			claims, _ = s.issuer.ParseHint(req.IdTokenHint)
+		}
+		// the ID tokens are issued to a single client
+		if claims == nil || claims.Typ != issuer.TypeID || len(claims.Aud) != 1 {
+			resp.Err = errInvalidRequest("id_token_hint is invalid")
+			return resp
+		}
+		if clientId != "" && clientId != claims.Aud[0] {
+			resp.Err = errInvalidRequest("id_token_hint was issued to another client")
+			return resp
+		}
+		clientId = claims.Aud[0]
+		userId, _ = strconv.ParseUint(claims.Sub, 10, 64)
+	}
+
+	redirect := ""
+	if req.PostLogoutRedirectURI != "" {
+		if clientId == "" {
+			resp.Err = errInvalidRequest("client_id or id_token_hint is required with post_logout_redirect_uri")
+			return resp
+		}
+		client, err := s.db.OAuthClients().FindByClientID(ctx, clientId)
+		if errors.Is(err, database.ErrNotFound) {
+			resp.Err = errInvalidRequest("Unknown client")
+			return resp
+		}
+		if err != nil {
+			s.log.Error(err)
+			resp.Err = errServer()
+			return resp
+		}
+		if !contains(client.PostLogoutRedirectURIs, req.PostLogoutRedirectURI) {
+			resp.Err = errInvalidRequest("Post logout redirect URI is not registered for the client")
+			return resp
+		}
+		params := url.Values{}
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+		redirect = withQuery(req.PostLogoutRedirectURI, params)
+	}
+
+	if userId != 0 && clientId != "" {
+		if err := s.db.RefreshTokens().RevokeByClient(ctx, userId, clientId); err != nil {
+			s.log.Error(err)
+			resp.Err = errServer()
+			return resp
+		}
+	}
+
+	if s.cfg.LogoutURL != nil && s.cfg.LogoutURL() != "" {
+		params := url.Values{}
+		if redirect != "" {
+			params.Set("redirect_to", redirect)
+		}
+		resp.Redirect = withQuery(s.cfg.LogoutURL(), params)
+		return resp
+	}
+	resp.Redirect = redirect
+	return resp
+}
+
+// idToken returns the signed ID token (OpenID Connect Core section 2)
+func (s *service) idToken(ctx context.Context, client *database.OAuthClientModel, userId uint64, scopes []string, nonce string) (string, error) {
+	info, err := s.profile(ctx, userId, scopes)
+	if err != nil {
+		return "", err
+	}
+	return s.issuer.Sign(issuer.Claims{
+		Sub:                 info.Sub,
+		Aud:                 issuer.Audience{client.ClientId},
+		Nonce:               nonce,
+		Email:               info.Email,
+		EmailVerified:       info.EmailVerified,
+		PhoneNumber:         info.PhoneNumber,
+		PhoneNumberVerified: info.PhoneNumberVerified,
+		Typ:                 issuer.TypeID,
+	})
+}
+
+// profile returns the claims of the user allowed by the scopes
+func (s *service) profile(ctx context.Context, userId uint64, scopes []string) (*UserInfoResponse, error) {
+	info := &UserInfoResponse{Sub: strconv.FormatUint(userId, 10)}
+
+	auth, err := s.db.Auth().FindByUserID(ctx, userId)
+	if errors.Is(err, database.ErrNotFound) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if contains(scopes, ScopeEmail) && auth.Email_Auth != "" {
+		verified := auth.IsEmailVerified_Auth
+		info.Email = auth.Email_Auth
+		info.EmailVerified = &verified
+	}
+	if contains(scopes, ScopePhone) && auth.Phone_Auth != "" {
+		verified := auth.IsPhoneVerified_Auth
+		info.PhoneNumber = auth.Phone_Auth
+		info.PhoneNumberVerified = &verified
+	}
+	return info, nil
+}
@@ -0,0 +1,128 @@
+package oauth
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+func TestDiscovery(t *testing.T) {
+	resp := newEnv(t, true).srv.Discovery(context.Background())
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp.Issuer != testIssuer || resp.TokenEndpoint != testIssuer+"/oauth/token" || resp.JwksURI != testIssuer+"/.well-known/jwks.json" {
+		t.Errorf("endpoints = %+v", resp)
+	}
+	if len(resp.IdTokenSigningAlgValuesSupported) != 1 || resp.IdTokenSigningAlgValuesSupported[0] != "RS256" {
+		t.Errorf("algorithms = %v", resp.IdTokenSigningAlgValuesSupported)
+	}
+	if !contains(resp.ScopesSupported, ScopeOpenID) || !contains(resp.CodeChallengeMethodsSupported, "S256") || !contains(resp.GrantTypesSupported, GrantDeviceCode) {
+		t.Errorf("metadata = %+v", resp)
+	}
+
+	// the registry Auth tokens can't be verified by the clients
+	if resp := newEnv(t, false).srv.Discovery(context.Background()); resp.Err == nil {
+		t.Error("discovery without the native issuer")
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	env := newEnv(t, true)
+	signInToken, userId := env.signIn(t, "user@example.com")
+	client := env.client(t, database.OAuthClientModel{ClientId: "app", Scopes: []string{ScopeOpenID, ScopeEmail}}, true)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		scope string
+		email string
+		err   string
+	}{
+		{"openid email", "user@example.com", ""},
+		{"openid", "", ""},
+		{"email", "", "insufficient_scope"},
+	} {
+		resp := env.srv.UserInfo(ctx, UserInfoRequest{Token: env.issue(t, client, userId, tc.scope).AccessToken})
+		if tc.err != "" {
+			if e, ok := resp.Err.(*Error); !ok || e.Code != tc.err {
+				t.Errorf("%s: err = %v, want %s", tc.scope, resp.Err, tc.err)
+			}
+			continue
+		}
+		if resp.Err != nil {
+			t.Fatalf("%s: %v", tc.scope, resp.Err)
+		}
+		if resp.Sub != strconv.FormatUint(userId, 10) || resp.Email != tc.email || (tc.email != "") != (resp.EmailVerified != nil) {
+			t.Errorf("%s: response = %+v", tc.scope, resp)
+		}
+	}
+
+	tokens := env.issue(t, client, userId, "openid email")
+	credentials := ClientCredentials{ClientId: "app", ClientSecret: testSecret}
+	if resp := env.srv.Revoke(ctx, RevokeRequest{Client: credentials, Token: tokens.AccessToken}); resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	for name, token := range map[string]string{
+		"revoked":  tokens.AccessToken,
+		"id token": tokens.IdToken,
+		"sign in":  signInToken,
+		"invalid":  "token",
+	} {
+		if e, ok := env.srv.UserInfo(ctx, UserInfoRequest{Token: token}).Err.(*Error); !ok || e.Code != "invalid_token" {
+			t.Errorf("%s: err = %v, want invalid_token", name, e)
+		}
+	}
+}
+
+func TestLogout(t *testing.T) {
+	env := newEnv(t, true)
+	_, userId := env.signIn(t, "user@example.com")
+	client := env.client(t, database.OAuthClientModel{
+		ClientId:               "app",
+		GrantTypes:             []string{GrantRefreshToken},
+		Scopes:                 []string{ScopeOpenID},
+		PostLogoutRedirectURIs: []string{"https://app.example.com/bye"},
+	}, true)
+	other := env.client(t, database.OAuthClientModel{ClientId: "other", Scopes: []string{ScopeOpenID}}, true)
+	tokens := env.issue(t, client, userId, ScopeOpenID)
+	ctx := context.Background()
+
+	for name, req := range map[string]LogoutRequest{
+		"id token of another client": {IdTokenHint: env.issue(t, other, userId, ScopeOpenID).IdToken, ClientId: "app"},
+		"access token as hint":       {IdTokenHint: tokens.AccessToken},
+		"invalid hint":               {IdTokenHint: "token"},
+		"unregistered redirect":      {ClientId: "app", PostLogoutRedirectURI: "https://evil.example.com/"},
+		"redirect without client":    {PostLogoutRedirectURI: "https://app.example.com/bye"},
+		"unknown client":             {ClientId: "unknown", PostLogoutRedirectURI: "https://app.example.com/bye"},
+	} {
+		if resp := env.srv.Logout(ctx, req); resp.Err == nil || resp.Redirect != "" {
+			t.Errorf("%s: response = %+v", name, resp)
+		}
+	}
+
+	resp := env.srv.Logout(ctx, LogoutRequest{IdTokenHint: tokens.IdToken, PostLogoutRedirectURI: "https://app.example.com/bye", State: "s"})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp.Redirect != "https://app.example.com/bye?state=s" {
+		t.Errorf("redirect = %q", resp.Redirect)
+	}
+	// the refresh tokens of the client are revoked
+	credentials := ClientCredentials{ClientId: "app", ClientSecret: testSecret}
+	if info := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: tokens.RefreshToken}); info.Err != nil || info.Active {
+		t.Errorf("refresh token after logout: %+v", info)
+	}
+
+	// the CMS logout page signs the user out and then redirects
+	env.srv.(*service).cfg.LogoutURL = func() string { return "https://cms.example.com/logout" }
+	resp = env.srv.Logout(ctx, LogoutRequest{ClientId: "app", PostLogoutRedirectURI: "https://app.example.com/bye"})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp.Redirect != "https://cms.example.com/logout?redirect_to="+url.QueryEscape("https://app.example.com/bye") {
+		t.Errorf("redirect = %q", resp.Redirect)
+	}
+}
@@ -0,0 +1,99 @@
+package oauth
+
+// ClientCredentials are taken from the basic authorization or the form, private_key_jwt
+// clients send the assertion instead of the secret
+type ClientCredentials struct {
+	ClientId      string
+	ClientSecret  string
+	AssertionType string
+	Assertion     string
+}
+
+// Introspect Request (RFC 7662)
+type IntrospectRequest struct {
+	Client        ClientCredentials
+	Token         string
+	TokenTypeHint string
+}
+
+// Revoke Request (RFC 7009)
+type RevokeRequest struct {
+	Client        ClientCredentials
+	Token         string
+	TokenTypeHint string
+}
+
+// Authorize Request of the client (RFC 6749 section 4.1.1, RFC 7636 section 4.3)
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientId            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Nonce               string `json:"nonce"`
+	// Query is passed to the consent page as is
+	Query string `json:"-"`
+}
+
+// Approve Request is sent by the consent page with the authorization request it was opened with
+type ApproveRequest struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// Token Request (RFC 6749 sections 4.1.3 and 6)
+type TokenRequest struct {
+	Client       ClientCredentials
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	DeviceCode   string
+}
+
+// UserInfo Request carries the access token (OpenID Connect Core section 5.3)
+type UserInfoRequest struct {
+	Token string
+}
+
+// Logout Request of the relying party (OpenID Connect RP-Initiated Logout section 2)
+type LogoutRequest struct {
+	IdTokenHint           string
+	ClientId              string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+// CreateServiceAccount Request, the client authenticates with private_key_jwt when
+// PublicKey is set and with a generated secret otherwise
+type CreateServiceAccountRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	PublicKey string   `json:"public_key"`
+}
+
+// RotateClientCredentials Request, PublicKey replaces the key, a new secret is generated without it
+type RotateClientCredentialsRequest struct {
+	ClientId  string `json:"-"`
+	PublicKey string `json:"public_key"`
+}
+
+// DeviceAuthorization Request (RFC 8628 section 3.1)
+type DeviceAuthorizationRequest struct {
+	Client ClientCredentials
+	Scope  string
+}
+
+// Device Request is sent by the verification page with the code the user entered
+type DeviceRequest struct {
+	UserCode string `json:"user_code"`
+}
+
+type DeviceApproveRequest struct {
+	UserCode string `json:"user_code"`
+	Approve  bool   `json:"approve"`
+}
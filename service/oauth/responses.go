@@ -0,0 +1,183 @@
+package oauth
+
+// Introspect Response, inactive tokens have only Active set
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	Err       error  `json:"-"`
+}
+
+func (d *IntrospectResponse) Error() error {
+	return d.Err
+}
+
+// Revoke Response has empty body
+type RevokeResponse struct {
+	Err error
+}
+
+func (d *RevokeResponse) Error() error {
+	return d.Err
+}
+
+// Authorize Response redirects to the consent page
+type AuthorizeResponse struct {
+	Redirect string
+	Err      error
+}
+
+func (d *AuthorizeResponse) Error() error {
+	return d.Err
+}
+
+type ScopeInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Consent Response describes the request for the consent page, when RedirectTo is set
+// the page must send the browser there instead of asking the user
+type ConsentResponse struct {
+	ClientId   string      `json:"client_id,omitempty"`
+	ClientName string      `json:"client_name,omitempty"`
+	Scopes     []ScopeInfo `json:"scopes,omitempty"`
+	// Granted is set when the user already consented to all the scopes
+	Granted    bool   `json:"granted"`
+	RedirectTo string `json:"redirect_to,omitempty"`
+	Err        error  `json:"-"`
+}
+
+func (d *ConsentResponse) Error() error {
+	return d.Err
+}
+
+// Approve Response carries the client redirect with the code or the error
+type ApproveResponse struct {
+	RedirectTo string `json:"redirect_to"`
+	Err        error  `json:"-"`
+}
+
+func (d *ApproveResponse) Error() error {
+	return d.Err
+}
+
+// Token Response (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Err          error  `json:"-"`
+}
+
+func (d *TokenResponse) Error() error {
+	return d.Err
+}
+
+// UserInfo Response has the claims of the granted scopes
+type UserInfoResponse struct {
+	Sub                 string `json:"sub"`
+	Email               string `json:"email,omitempty"`
+	EmailVerified       *bool  `json:"email_verified,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool  `json:"phone_number_verified,omitempty"`
+	Err                 error  `json:"-"`
+}
+
+func (d *UserInfoResponse) Error() error {
+	return d.Err
+}
+
+// Discovery Response is the OpenID Provider metadata (OpenID Connect Discovery section 3)
+type DiscoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	EndSessionEndpoint                string   `json:"end_session_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	Err                               error    `json:"-"`
+}
+
+func (d *DiscoveryResponse) Error() error {
+	return d.Err
+}
+
+// Logout Response redirects to the logout page or back to the client, it is empty
+// when there is nowhere to redirect
+type LogoutResponse struct {
+	Redirect string
+	Err      error
+}
+
+func (d *LogoutResponse) Error() error {
+	return d.Err
+}
+
+// ServiceAccount Response, ClientSecret is shown only once
+type ServiceAccountResponse struct {
+	UserId       uint64 `json:"user_id"`
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	Err          error  `json:"-"`
+}
+
+func (d *ServiceAccountResponse) Error() error {
+	return d.Err
+}
+
+// ClientCredentials Response, ClientSecret is shown only once
+type ClientCredentialsResponse struct {
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	Err          error  `json:"-"`
+}
+
+func (d *ClientCredentialsResponse) Error() error {
+	return d.Err
+}
+
+// DeviceAuthorization Response (RFC 8628 section 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+	Err                     error  `json:"-"`
+}
+
+func (d *DeviceAuthorizationResponse) Error() error {
+	return d.Err
+}
+
+type DeviceApproveResponse struct {
+	Approved bool  `json:"approved"`
+	Err      error `json:"-"`
+}
+
+func (d *DeviceApproveResponse) Error() error {
+	return d.Err
+}
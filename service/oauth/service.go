@@ -0,0 +1,413 @@
+// Package oauth implements OAuth 2.0 endpoints for clients outside the CMS
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nori-io/nori-common/interfaces"
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/issuer"
+)
+
+const (
+	hintAccessToken  = "access_token"
+	hintRefreshToken = "refresh_token"
+
+	jwtBearerAssertion = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+type Service interface {
+	Introspect(ctx context.Context, req IntrospectRequest) (resp *IntrospectResponse)
+	Revoke(ctx context.Context, req RevokeRequest) (resp *RevokeResponse)
+	Authorize(ctx context.Context, req AuthorizeRequest) (resp *AuthorizeResponse)
+	Consent(ctx context.Context, req AuthorizeRequest) (resp *ConsentResponse)
+	Approve(ctx context.Context, req ApproveRequest) (resp *ApproveResponse)
+	Token(ctx context.Context, req TokenRequest) (resp *TokenResponse)
+	UserInfo(ctx context.Context, req UserInfoRequest) (resp *UserInfoResponse)
+	Discovery(ctx context.Context) (resp *DiscoveryResponse)
+	Logout(ctx context.Context, req LogoutRequest) (resp *LogoutResponse)
+	CreateServiceAccount(ctx context.Context, req CreateServiceAccountRequest) (resp *ServiceAccountResponse)
+	RotateClientCredentials(ctx context.Context, req RotateClientCredentialsRequest) (resp *ClientCredentialsResponse)
+	DeviceAuthorization(ctx context.Context, req DeviceAuthorizationRequest) (resp *DeviceAuthorizationResponse)
+	Device(ctx context.Context, req DeviceRequest) (resp *ConsentResponse)
+	DeviceApprove(ctx context.Context, req DeviceApproveRequest) (resp *DeviceApproveResponse)
+}
+
+type Config struct {
+	// Issuer is the public URL of the server, the endpoints in the discovery document are built from it
+	Issuer func() string
+	// ConsentURL is the page of the CMS frontend which asks the user for the consent
+	ConsentURL func() string
+	// LogoutURL is the page of the CMS frontend which signs the user out, redirect_to is appended to it
+	LogoutURL func() string
+	// DeviceURL is the page of the CMS frontend where the user enters the device flow user code
+	DeviceURL func() string
+	// Scopes maps supported scopes to their descriptions
+	Scopes     func() map[string]interface{}
+	RefreshTTL func() string
+	// TokenTTL is the lifetime of the registry Auth tokens, their introspection reports
+	// the expiry by it and the revoked ones are denied this long
+	TokenTTL func() string
+}
+
+type service struct {
+	db        database.Database
+	cfg       *Config
+	issuer    *issuer.Issuer
+	auth      interfaces.Auth
+	transport interfaces.HTTPTransport
+	session   interfaces.Session
+	revoked   database.RevokedTokens
+	log       *logrus.Logger
+}
+
+// NewService creates the OAuth service. Tokens are issued to the clients only by the native
+// issuer iss, it is nil when the registry Auth signs the users in: the sign in tokens are then
+// verified by auth with the token put into context by transport
+func NewService(
+	db database.Database,
+	cfg *Config,
+	iss *issuer.Issuer,
+	auth interfaces.Auth,
+	transport interfaces.HTTPTransport,
+	session interfaces.Session,
+	revoked database.RevokedTokens,
+	log *logrus.Logger,
+) Service {
+	return &service{
+		db:        db,
+		cfg:       cfg,
+		issuer:    iss,
+		auth:      auth,
+		transport: transport,
+		session:   session,
+		revoked:   revoked,
+		log:       log,
+	}
+}
+
+func (s *service) Introspect(ctx context.Context, req IntrospectRequest) (resp *IntrospectResponse) {
+	resp = &IntrospectResponse{}
+
+	if _, err := s.authenticateClient(ctx, req.Client, false); err != nil {
+		resp.Err = err
+		return resp
+	}
+	if req.Token == "" {
+		resp.Err = errInvalidRequest("token is required")
+		return resp
+	}
+
+	lookups := []func(context.Context, string) (*IntrospectResponse, error){s.introspectAccess, s.introspectRefresh}
+	if req.TokenTypeHint == hintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		info, err := lookup(ctx, req.Token)
+		if err != nil {
+			s.log.Error(err)
+			resp.Err = errServer()
+			return resp
+		}
+		if info != nil {
+			return info
+		}
+	}
+	return resp
+}
+
+func (s *service) Revoke(ctx context.Context, req RevokeRequest) (resp *RevokeResponse) {
+	resp = &RevokeResponse{}
+
+	client, authErr := s.authenticateClient(ctx, req.Client, true)
+	if authErr != nil {
+		resp.Err = authErr
+		return resp
+	}
+	if req.Token == "" {
+		resp.Err = errInvalidRequest("token is required")
+		return resp
+	}
+
+	refresh, err := s.refreshToken(ctx, req.Token)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+	if refresh != nil {
+		if refresh.ClientId != client.ClientId {
+			resp.Err = errUnauthorizedClient("Token was issued to another client")
+			return resp
+		}
+		if err := s.db.RefreshTokens().Revoke(ctx, refresh.Id); err != nil && !errors.Is(err, database.ErrNotFound) {
+			s.log.Error(err)
+			resp.Err = errServer()
+		}
+		return resp
+	}
+
+	claims, err := s.accessToken(ctx, req.Token)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+		return resp
+	}
+	if claims == nil {
+		// invalid tokens are not an error for the revocation (RFC 7009 section 2.2)
+		return resp
+	}
+	if claims.ClientId != "" && claims.ClientId != client.ClientId {
+		resp.Err = errUnauthorizedClient("Token was issued to another client")
+		return resp
+	}
+	if claims.Jti == "" {
+		return resp
+	}
+	s.session.Delete([]byte(claims.Jti))
+	if err := s.revoked.Revoke(ctx, claims.Jti, time.Unix(claims.Exp, 0)); err != nil {
+		s.log.Error(err)
+		resp.Err = errServer()
+	}
+	return resp
+}
+
+// authenticateClient checks the client secret or the private_key_jwt assertion, public
+// clients have neither and are accepted only when allowPublic is set
+func (s *service) authenticateClient(ctx context.Context, c ClientCredentials, allowPublic bool) (*database.OAuthClientModel, *Error) {
+	if c.Assertion != "" {
+		return s.authenticateAssertion(ctx, c)
+	}
+	if c.ClientId == "" {
+		return nil, errInvalidClient()
+	}
+	client, err := s.db.OAuthClients().FindByClientID(ctx, c.ClientId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidClient()
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	// clients with a key must sign the assertion
+	if client.PublicKey != "" {
+		return nil, errInvalidClient()
+	}
+	if client.SecretHash == "" {
+		if !allowPublic || c.ClientSecret != "" {
+			return nil, errInvalidClient()
+		}
+		return client, nil
+	}
+	if c.ClientSecret == "" {
+		return nil, errInvalidClient()
+	}
+	if subtle.ConstantTimeCompare([]byte(HashSecret(c.ClientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, errInvalidClient()
+	}
+	return client, nil
+}
+
+// authenticateAssertion checks the JWT the client signed with its private key (RFC 7523 section 3)
+func (s *service) authenticateAssertion(ctx context.Context, c ClientCredentials) (*database.OAuthClientModel, *Error) {
+	if c.AssertionType != jwtBearerAssertion {
+		return nil, errInvalidClient()
+	}
+	unverified, err := issuer.Unverified(c.Assertion)
+	if err != nil {
+		return nil, errInvalidClient()
+	}
+	clientId := c.ClientId
+	if clientId == "" {
+		clientId = unverified.Sub
+	}
+	if clientId == "" || unverified.Sub != clientId {
+		return nil, errInvalidClient()
+	}
+
+	client, err := s.db.OAuthClients().FindByClientID(ctx, clientId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidClient()
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	if client.PublicKey == "" {
+		return nil, errInvalidClient()
+	}
+	claims, err := issuer.ParseAssertion(c.Assertion, client.PublicKey)
+	if err != nil {
+		return nil, errInvalidClient()
+	}
+	if claims.Iss != clientId || claims.Sub != clientId || claims.Jti == "" || !s.assertionAudience(claims.Aud) {
+		return nil, errInvalidClient()
+	}
+
+	// assertions are single use, used ids are kept in the denylist until they expire
+	key := "assertion:" + HashSecret(clientId+":"+claims.Jti)
+	used, err := s.revoked.IsRevoked(ctx, key)
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	if used {
+		return nil, errInvalidClient()
+	}
+	if err := s.revoked.Revoke(ctx, key, time.Unix(claims.Exp, 0)); err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	return client, nil
+}
+
+// assertionAudience accepts the audience with the issuer or the token endpoint URL among the values
+func (s *service) assertionAudience(aud issuer.Audience) bool {
+	if s.cfg.Issuer == nil || s.cfg.Issuer() == "" {
+		return false
+	}
+	base := strings.TrimRight(s.cfg.Issuer(), "/")
+	return aud.Contains(s.cfg.Issuer()) || aud.Contains(base) || aud.Contains(base+"/oauth/token")
+}
+
+// accessToken returns claims of the valid access token, nil when it isn't accepted.
+// ID tokens are signed with the same keys and are not accepted. The denylist is checked by the callers
+func (s *service) accessToken(ctx context.Context, token string) (*issuer.Claims, error) {
+	if s.issuer == nil {
+		return s.registryToken(ctx, token)
+	}
+	claims, err := s.issuer.ParseAccess(token)
+	if err != nil {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// registryToken verifies the sign in token of the registry Auth the same way its routes do,
+// the claims are restored from the sign in record of the session
+func (s *service) registryToken(ctx context.Context, token string) (*issuer.Claims, error) {
+	if s.auth == nil || s.transport == nil {
+		return nil, nil
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	var sid []byte
+	verify := s.auth.Authenticated()(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		sid = s.session.SessionId(ctx)
+		return nil, nil
+	})
+	if _, err := verify(s.transport.ToContext()(ctx, r), nil); err != nil || len(sid) == 0 {
+		return nil, nil
+	}
+	var state interfaces.SessionState
+	if err := s.session.Get(sid, &state); err != nil || state != interfaces.SessionActive {
+		return nil, nil
+	}
+
+	history, err := s.db.AuthenticationHistory().FindBySecret(ctx, string(sid))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !history.LoggedOut.IsZero() {
+		return nil, nil
+	}
+	id := strconv.FormatUint(history.UserId, 10)
+	claims := &issuer.Claims{
+		Sub: id,
+		Jti: string(sid),
+		Iat: history.LoggedIn.Unix(),
+		Exp: history.LoggedIn.Add(duration(s.cfg.TokenTTL, defaultTokenTTL)).Unix(),
+		Raw: map[string]string{"id": id},
+	}
+	model, err := s.db.Auth().FindByUserID(ctx, history.UserId)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+	if model != nil {
+		claims.Raw["email"] = model.Email_Auth
+	}
+	return claims, nil
+}
+
+func (s *service) introspectAccess(ctx context.Context, token string) (*IntrospectResponse, error) {
+	claims, err := s.accessToken(ctx, token)
+	if claims == nil || err != nil {
+		return nil, err
+	}
+	if claims.Jti != "" {
+		revoked, err := s.revoked.IsRevoked(ctx, claims.Jti)
+		if err != nil || revoked {
+			return nil, err
+		}
+	}
+
+	resp := &IntrospectResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientId:  claims.ClientId,
+		Username:  claims.Raw["email"],
+		TokenType: "Bearer",
+		Exp:       claims.Exp,
+		Iat:       claims.Iat,
+		Sub:       claims.Sub,
+		Iss:       claims.Iss,
+		Jti:       claims.Jti,
+	}
+	// sign in tokens carry the user id in the raw claims
+	if id := claims.Raw["id"]; id != "" {
+		resp.Sub = id
+	}
+	return resp, nil
+}
+
+// refreshToken returns the valid not revoked refresh token
+func (s *service) refreshToken(ctx context.Context, token string) (*database.RefreshTokenModel, error) {
+	model, err := s.db.RefreshTokens().FindByTokenHash(ctx, HashSecret(token))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if model.Revoked != nil || model.Expires.Before(time.Now()) {
+		return nil, nil
+	}
+	return model, nil
+}
+
+func (s *service) introspectRefresh(ctx context.Context, token string) (*IntrospectResponse, error) {
+	model, err := s.refreshToken(ctx, token)
+	if model == nil || err != nil {
+		return nil, err
+	}
+	return &IntrospectResponse{
+		Active:   true,
+		Scope:    model.Scope,
+		ClientId: model.ClientId,
+		Exp:      model.Expires.Unix(),
+		Iat:      model.Created.Unix(),
+		Sub:      strconv.FormatUint(model.UserId, 10),
+	}, nil
+}
+
+// HashSecret is used to store client secrets and refresh tokens
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
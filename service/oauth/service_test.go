@@ -0,0 +1,250 @@
+package oauth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/nori-io/nori-common/interfaces"
+	"github.com/sirupsen/logrus"
+
+	authservice "github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/breach"
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/fakes"
+	"github.com/nori-io/auth/service/issuer"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testPassword = "Xy7!kq2Lmn#p"
+	testSecret   = "client-secret"
+)
+
+// testEnv is the OAuth service with the users signed in by the auth service over the in-memory database
+type testEnv struct {
+	db        database.Database
+	issuer    *issuer.Issuer
+	auth      interfaces.Auth
+	transport interfaces.HTTPTransport
+	session   interfaces.Session
+	users     authservice.Service
+	srv       Service
+}
+
+// newEnv signs the users in with the native issuer when native is set and with the fake registry Auth otherwise
+func newEnv(t *testing.T, native bool) *testEnv {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	env := &testEnv{db: database.NewMemory()}
+	if native {
+		env.issuer = issuer.New(env.db, issuer.Config{Iss: func() string { return testIssuer }}, logger)
+		if err := env.issuer.Rotate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		env.auth, env.transport, env.session = env.issuer, env.issuer, env.issuer.Session(fakes.NewSession())
+	} else {
+		env.auth, env.transport, env.session = fakes.NewAuth(), fakes.NewTransport(), fakes.NewSession()
+	}
+
+	revoked := env.db.RevokedTokens()
+	env.users = authservice.NewService(env.auth, env.session, &authservice.Config{
+		Sub: func() string { return "user" },
+		Iss: func() string { return testIssuer },
+	}, logger, env.db, nil, breach.NewChecker(breach.Config{}), revoked)
+	env.srv = NewService(env.db, &Config{
+		Issuer:     func() string { return testIssuer },
+		ConsentURL: func() string { return "https://cms.example.com/consent" },
+		LogoutURL:  func() string { return "" },
+		DeviceURL:  func() string { return "https://cms.example.com/device" },
+	}, env.issuer, env.auth, env.transport, env.session, revoked, logger)
+	return env
+}
+
+// signIn signs the new user up and in, the token and the user id are returned
+func (env *testEnv) signIn(t *testing.T, email string) (string, uint64) {
+	t.Helper()
+	ctx := context.Background()
+	if resp := env.users.SignUp(ctx, authservice.SignUpRequest{Email: email, Password: testPassword}); resp.Err != nil {
+		t.Fatalf("sign up: %v", resp.Err)
+	}
+	resp := env.users.SignIn(ctx, authservice.SignInRequest{Email: email, Password: testPassword})
+	if resp.Err != nil {
+		t.Fatalf("sign in: %v", resp.Err)
+	}
+	return resp.Token, resp.Id
+}
+
+// context returns the context of the request authenticated with token the way the routes do
+func (env *testEnv) context(t *testing.T, token string) context.Context {
+	t.Helper()
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	var authenticated context.Context
+	_, err = env.auth.Authenticated()(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		authenticated = ctx
+		return nil, nil
+	})(env.transport.ToContext()(context.Background(), r), nil)
+	if err != nil {
+		t.Fatalf("token is not accepted: %v", err)
+	}
+	return authenticated
+}
+
+// client registers the client, the confidential clients get testSecret
+func (env *testEnv) client(t *testing.T, model database.OAuthClientModel, confidential bool) *database.OAuthClientModel {
+	t.Helper()
+	if confidential {
+		model.SecretHash = HashSecret(testSecret)
+	}
+	if err := env.db.OAuthClients().Create(context.Background(), &model); err != nil {
+		t.Fatal(err)
+	}
+	return &model
+}
+
+func TestIntrospectRegistryToken(t *testing.T) {
+	env := newEnv(t, false)
+	token, userId := env.signIn(t, "user@example.com")
+	env.client(t, database.OAuthClientModel{ClientId: "api", Name: "API"}, true)
+	credentials := ClientCredentials{ClientId: "api", ClientSecret: testSecret}
+	ctx := context.Background()
+
+	resp := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: token})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if !resp.Active || resp.Sub != strconv.FormatUint(userId, 10) || resp.Username != "user@example.com" || resp.Jti == "" || resp.Exp <= resp.Iat {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: "unknown"}); resp.Err != nil || resp.Active {
+		t.Errorf("unknown token: %+v", resp)
+	}
+
+	if resp := env.srv.Revoke(ctx, RevokeRequest{Client: credentials, Token: token}); resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: token}); resp.Err != nil || resp.Active {
+		t.Errorf("revoked token: %+v", resp)
+	}
+}
+
+// issue issues the tokens to the client the way the code exchange does
+func (env *testEnv) issue(t *testing.T, client *database.OAuthClientModel, userId uint64, scope string) *TokenResponse {
+	t.Helper()
+	resp, err := env.srv.(*service).issue(context.Background(), client, userId, scope, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestIntrospect(t *testing.T) {
+	env := newEnv(t, true)
+	signInToken, userId := env.signIn(t, "user@example.com")
+	client := env.client(t, database.OAuthClientModel{ClientId: "app", GrantTypes: []string{GrantRefreshToken}}, true)
+	tokens := env.issue(t, client, userId, "openid email")
+	credentials := ClientCredentials{ClientId: "app", ClientSecret: testSecret}
+	ctx := context.Background()
+	sub := strconv.FormatUint(userId, 10)
+
+	for _, tc := range []struct {
+		name     string
+		token    string
+		hint     string
+		clientId string
+		scope    string
+	}{
+		{"access", tokens.AccessToken, "", "app", "openid email"},
+		{"access with refresh hint", tokens.AccessToken, hintRefreshToken, "app", "openid email"},
+		{"refresh", tokens.RefreshToken, "", "app", "openid email"},
+		{"refresh with hint", tokens.RefreshToken, hintRefreshToken, "app", "openid email"},
+		{"sign in", signInToken, hintAccessToken, "", ""},
+	} {
+		resp := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: tc.token, TokenTypeHint: tc.hint})
+		if resp.Err != nil {
+			t.Fatalf("%s: %v", tc.name, resp.Err)
+		}
+		if !resp.Active || resp.Sub != sub || resp.ClientId != tc.clientId || resp.Scope != tc.scope || resp.Exp <= resp.Iat {
+			t.Errorf("%s: response = %+v", tc.name, resp)
+		}
+	}
+
+	for name, req := range map[string]IntrospectRequest{
+		"wrong secret": {Client: ClientCredentials{ClientId: "app", ClientSecret: "wrong"}, Token: tokens.AccessToken},
+		"no secret":    {Client: ClientCredentials{ClientId: "app"}, Token: tokens.AccessToken},
+		"no token":     {Client: credentials},
+	} {
+		if resp := env.srv.Introspect(ctx, req); resp.Err == nil || resp.Active {
+			t.Errorf("%s: response = %+v", name, resp)
+		}
+	}
+	if resp := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: tokens.AccessToken + "x"}); resp.Err != nil || resp.Active {
+		t.Errorf("forged token: %+v", resp)
+	}
+	// ID tokens are signed with the same keys but are not access tokens
+	if resp := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: tokens.IdToken}); resp.Err != nil || resp.Active {
+		t.Errorf("ID token: %+v", resp)
+	}
+	if resp := env.srv.UserInfo(ctx, UserInfoRequest{Token: tokens.IdToken}); resp.Err == nil {
+		t.Errorf("ID token is accepted by userinfo: %+v", resp)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	env := newEnv(t, true)
+	_, userId := env.signIn(t, "user@example.com")
+	client := env.client(t, database.OAuthClientModel{ClientId: "app", GrantTypes: []string{GrantRefreshToken}}, true)
+	env.client(t, database.OAuthClientModel{ClientId: "other"}, true)
+	tokens := env.issue(t, client, userId, "email")
+	credentials := ClientCredentials{ClientId: "app", ClientSecret: testSecret}
+	ctx := context.Background()
+
+	active := func(token string) bool {
+		resp := env.srv.Introspect(ctx, IntrospectRequest{Client: credentials, Token: token})
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		return resp.Active
+	}
+
+	// the tokens of another client are not revoked
+	other := ClientCredentials{ClientId: "other", ClientSecret: testSecret}
+	for _, token := range []string{tokens.AccessToken, tokens.RefreshToken} {
+		if resp := env.srv.Revoke(ctx, RevokeRequest{Client: other, Token: token}); resp.Err == nil {
+			t.Error("token of another client is revoked")
+		}
+		if !active(token) {
+			t.Error("token is revoked by another client")
+		}
+	}
+
+	for name, req := range map[string]RevokeRequest{
+		"access":  {Client: credentials, Token: tokens.AccessToken, TokenTypeHint: hintAccessToken},
+		"refresh": {Client: credentials, Token: tokens.RefreshToken, TokenTypeHint: hintRefreshToken},
+	} {
+		if resp := env.srv.Revoke(ctx, req); resp.Err != nil {
+			t.Fatalf("%s: %v", name, resp.Err)
+		}
+		if active(req.Token) {
+			t.Errorf("%s: token is active after revocation", name)
+		}
+		// the revocation is idempotent
+		if resp := env.srv.Revoke(ctx, req); resp.Err != nil {
+			t.Errorf("%s: second revocation: %v", name, resp.Err)
+		}
+	}
+	if resp := env.srv.Revoke(ctx, RevokeRequest{Client: credentials, Token: "unknown"}); resp.Err != nil {
+		t.Errorf("unknown token: %v", resp.Err)
+	}
+	if resp := env.srv.Token(ctx, TokenRequest{Client: credentials, GrantType: GrantRefreshToken, RefreshToken: tokens.RefreshToken}); resp.Err == nil {
+		t.Error("revoked refresh token is exchanged")
+	}
+}
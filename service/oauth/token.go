@@ -0,0 +1,253 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cheebo/rand"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/issuer"
+)
+
+const (
+	defaultRefreshTTL = 30 * 24 * time.Hour
+	defaultTokenTTL   = time.Hour
+)
+
+func (s *service) Token(ctx context.Context, req TokenRequest) (resp *TokenResponse) {
+	resp = &TokenResponse{}
+
+	client, err := s.authenticateClient(ctx, req.Client, true)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+
+	switch req.GrantType {
+	case GrantAuthorizationCode:
+		resp, err = s.exchangeCode(ctx, client, req)
+	case GrantRefreshToken:
+		resp, err = s.refresh(ctx, client, req)
+	case GrantClientCredentials:
+		resp, err = s.clientCredentials(ctx, client, req)
+	case GrantDeviceCode:
+		resp, err = s.deviceCode(ctx, client, req)
+	default:
+		err = errUnsupportedGrantType()
+	}
+	if err != nil {
+		return &TokenResponse{Err: err}
+	}
+	return resp
+}
+
+func (s *service) exchangeCode(ctx context.Context, client *database.OAuthClientModel, req TokenRequest) (*TokenResponse, *Error) {
+	if !contains(client.GrantTypes, GrantAuthorizationCode) {
+		return nil, errUnauthorizedClient("Authorization code grant is not allowed for the client")
+	}
+	code, err := s.db.OAuthCodes().FindByCodeHash(ctx, HashSecret(req.Code))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidGrant("Code is invalid")
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	if code.Used != nil || code.Expires.Before(time.Now()) || code.ClientId != client.ClientId {
+		return nil, errInvalidGrant("Code is invalid")
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, errInvalidGrant("Redirect URI doesn't match the authorization request")
+	}
+	if !verifyPKCE(code.CodeChallenge, req.CodeVerifier) {
+		return nil, errInvalidGrant("Code verifier doesn't match the challenge")
+	}
+	// the code is single use, concurrent exchanges fail here
+	err = s.db.OAuthCodes().Use(ctx, code.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidGrant("Code is invalid")
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+
+	return s.issue(ctx, client, code.UserId, code.Scope, code.Nonce)
+}
+
+func (s *service) refresh(ctx context.Context, client *database.OAuthClientModel, req TokenRequest) (*TokenResponse, *Error) {
+	if !contains(client.GrantTypes, GrantRefreshToken) {
+		return nil, errUnauthorizedClient("Refresh token grant is not allowed for the client")
+	}
+	token, err := s.refreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	if token == nil || token.ClientId != client.ClientId {
+		return nil, errInvalidGrant("Refresh token is invalid")
+	}
+
+	// the scope may only be narrowed (RFC 6749 section 6)
+	scope := token.Scope
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		granted := strings.Fields(token.Scope)
+		for _, name := range requested {
+			if !contains(granted, name) {
+				return nil, errInvalidScope("Scope " + name + " was not granted")
+			}
+		}
+		scope = strings.Join(requested, " ")
+	}
+
+	// refresh tokens are rotated, the used one is revoked
+	err = s.db.RefreshTokens().Revoke(ctx, token.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, errInvalidGrant("Refresh token is invalid")
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+
+	return s.issue(ctx, client, token.UserId, scope, "")
+}
+
+// clientCredentials issues the access token of the service account owning the client (RFC 6749 section 4.4)
+func (s *service) clientCredentials(ctx context.Context, client *database.OAuthClientModel, req TokenRequest) (*TokenResponse, *Error) {
+	if !contains(client.GrantTypes, GrantClientCredentials) {
+		return nil, errUnauthorizedClient("Client credentials grant is not allowed for the client")
+	}
+	if client.OwnerId == 0 || (client.SecretHash == "" && client.PublicKey == "") {
+		return nil, errUnauthorizedClient("Client credentials grant requires a confidential service account client")
+	}
+	if s.issuer == nil {
+		s.log.Error("oauth: tokens can be issued only by the native issuer, set jwt.issuer to native")
+		return nil, errServer()
+	}
+	active, err := s.active(ctx, client.OwnerId)
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	if !active {
+		return nil, errInvalidGrant("Service account is not active")
+	}
+
+	scopes, scopeErr := s.scopes(client, req.Scope)
+	if scopeErr != nil {
+		return nil, scopeErr
+	}
+	// there is no user to identify, ID tokens are not issued
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if scope != ScopeOpenID {
+			granted = append(granted, scope)
+		}
+	}
+
+	resp, err := s.signAccess(client, client.OwnerId, strings.Join(granted, " "))
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	return resp, nil
+}
+
+// issue returns the access token, the refresh token when the client may use them
+// and the ID token when openid scope is granted
+func (s *service) issue(ctx context.Context, client *database.OAuthClientModel, userId uint64, scope, nonce string) (*TokenResponse, *Error) {
+	if s.issuer == nil {
+		s.log.Error("oauth: tokens can be issued only by the native issuer, set jwt.issuer to native")
+		return nil, errServer()
+	}
+
+	active, err := s.active(ctx, userId)
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+	if !active {
+		return nil, errInvalidGrant("User is not active")
+	}
+
+	now := time.Now()
+	resp, err := s.signAccess(client, userId, scope)
+	if err != nil {
+		s.log.Error(err)
+		return nil, errServer()
+	}
+
+	if contains(client.GrantTypes, GrantRefreshToken) {
+		refresh := rand.RandomAlphaNum(48)
+		err := s.db.RefreshTokens().Create(ctx, &database.RefreshTokenModel{
+			TokenHash: HashSecret(refresh),
+			ClientId:  client.ClientId,
+			UserId:    userId,
+			Scope:     scope,
+			Created:   now,
+			Expires:   now.Add(duration(s.cfg.RefreshTTL, defaultRefreshTTL)),
+		})
+		if err != nil {
+			s.log.Error(err)
+			return nil, errServer()
+		}
+		resp.RefreshToken = refresh
+	}
+
+	if scopes := strings.Fields(scope); contains(scopes, ScopeOpenID) {
+		resp.IdToken, err = s.idToken(ctx, client, userId, scopes, nonce)
+		if err != nil {
+			s.log.Error(err)
+			return nil, errServer()
+		}
+	}
+	return resp, nil
+}
+
+func (s *service) signAccess(client *database.OAuthClientModel, userId uint64, scope string) (*TokenResponse, error) {
+	access, err := s.issuer.Sign(issuer.Claims{
+		Sub:      strconv.FormatUint(userId, 10),
+		Aud:      issuer.Audience{client.ClientId},
+		Jti:      rand.RandomAlphaNum(32),
+		Scope:    scope,
+		ClientId: client.ClientId,
+		Typ:      issuer.TypeAccess,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.issuer.TTL().Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// active reports whether the user exists and is neither deleted nor suspended
+func (s *service) active(ctx context.Context, userId uint64) (bool, error) {
+	user, err := s.db.Users().FindByID(ctx, userId)
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Deleted == nil && user.StatusId != database.UserStatusSuspended, nil
+}
+
+func duration(value func() string, def time.Duration) time.Duration {
+	if value == nil {
+		return def
+	}
+	d, err := time.ParseDuration(value())
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
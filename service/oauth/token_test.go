@@ -0,0 +1,129 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+// assertion returns the private_key_jwt assertion with claims signed by key
+func assertion(t *testing.T, key ed25519.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return input + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(input)))
+}
+
+func publicKeyPEM(t *testing.T, key ed25519.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestClientCredentialsAssertion(t *testing.T) {
+	env := newEnv(t, true)
+	ctx := context.Background()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := &database.UsersModel{StatusId: database.UserStatusActive, Type: database.UserTypeService}
+	if err := env.db.Users().Create(ctx, account); err != nil {
+		t.Fatal(err)
+	}
+	env.client(t, database.OAuthClientModel{
+		ClientId:   "svc",
+		GrantTypes: []string{GrantClientCredentials},
+		Scopes:     []string{ScopeEmail},
+		OwnerId:    account.Id,
+		PublicKey:  publicKeyPEM(t, public),
+	}, false)
+
+	endpoint := testIssuer + "/oauth/token"
+	jti := 0
+	claims := func(aud interface{}, exp time.Duration) map[string]interface{} {
+		jti++
+		return map[string]interface{}{
+			"iss": "svc",
+			"sub": "svc",
+			"aud": aud,
+			"jti": strconv.Itoa(jti),
+			"exp": time.Now().Add(exp).Unix(),
+		}
+	}
+	request := func(assertion string) TokenRequest {
+		return TokenRequest{
+			Client:    ClientCredentials{AssertionType: jwtBearerAssertion, Assertion: assertion},
+			GrantType: GrantClientCredentials,
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		claims map[string]interface{}
+		key    ed25519.PrivateKey
+		ok     bool
+	}{
+		{"token endpoint", claims(endpoint, time.Minute), private, true},
+		{"issuer", claims(testIssuer, time.Minute), private, true},
+		{"array", claims([]string{"https://other.example.com", endpoint}, time.Minute), private, true},
+		{"foreign audience", claims([]string{"https://other.example.com"}, time.Minute), private, false},
+		{"expired", claims(endpoint, -time.Hour), private, false},
+		{"other key", claims(endpoint, time.Minute), otherKey, false},
+	} {
+		resp := env.srv.Token(ctx, request(assertion(t, tc.key, tc.claims)))
+		if ok := resp.Err == nil; ok != tc.ok {
+			t.Errorf("%s: err = %v", tc.name, resp.Err)
+			continue
+		}
+		if !tc.ok {
+			continue
+		}
+		info := env.srv.Introspect(ctx, IntrospectRequest{
+			Client: ClientCredentials{AssertionType: jwtBearerAssertion, Assertion: assertion(t, private, claims(endpoint, time.Minute))},
+			Token:  resp.AccessToken,
+		})
+		if info.Err != nil || !info.Active || info.ClientId != "svc" || info.Sub != strconv.FormatUint(account.Id, 10) || info.Scope != ScopeEmail {
+			t.Errorf("%s: introspection = %+v", tc.name, info)
+		}
+		if resp.RefreshToken != "" || resp.IdToken != "" {
+			t.Errorf("%s: response = %+v", tc.name, resp)
+		}
+	}
+
+	// assertions are single use
+	used := assertion(t, private, claims(endpoint, time.Minute))
+	if resp := env.srv.Token(ctx, request(used)); resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp := env.srv.Token(ctx, request(used)); resp.Err == nil {
+		t.Error("assertion is used twice")
+	}
+	// the client with a key can't authenticate with a secret
+	if resp := env.srv.Token(ctx, TokenRequest{Client: ClientCredentials{ClientId: "svc", ClientSecret: testSecret}, GrantType: GrantClientCredentials}); resp.Err == nil {
+		t.Error("secret is accepted instead of the assertion")
+	}
+}
@@ -0,0 +1,150 @@
+package oauth
+
+import (
+	"github.com/nori-io/nori-common/endpoint"
+	"github.com/nori-io/nori-common/interfaces"
+	"github.com/nori-io/nori-common/transport/http"
+	"github.com/sirupsen/logrus"
+
+	authservice "github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/database"
+)
+
+func Transport(
+	auth interfaces.Auth,
+	transport interfaces.HTTPTransport,
+	session interfaces.Session,
+	revoked database.RevokedTokens,
+	router interfaces.Http,
+	srv Service,
+	logger *logrus.Logger,
+) {
+
+	notRevoked := authservice.NotRevoked(revoked, session, logger)
+	authenticated := func(e endpoint.Endpoint) endpoint.Endpoint {
+		return auth.Authenticated()(notRevoked(session.Verify()(e)))
+	}
+
+	opts := []http.ServerOption{
+		http.ServerBefore(transport.ToContext()),
+	}
+
+	introspectHandler := http.NewServer(
+		MakeIntrospectEndpoint(srv),
+		DecodeIntrospectRequest,
+		EncodeIntrospectResponse,
+		logger,
+	)
+
+	revokeHandler := http.NewServer(
+		MakeRevokeEndpoint(srv),
+		DecodeRevokeRequest,
+		EncodeRevokeResponse,
+		logger,
+	)
+
+	authorizeHandler := http.NewServer(
+		MakeAuthorizeEndpoint(srv),
+		DecodeAuthorizeRequest,
+		EncodeAuthorizeResponse,
+		logger,
+	)
+
+	consentHandler := http.NewServer(
+		authenticated(MakeConsentEndpoint(srv)),
+		DecodeAuthorizeRequest,
+		EncodeConsentResponse,
+		logger,
+		opts...,
+	)
+
+	approveHandler := http.NewServer(
+		authenticated(MakeApproveEndpoint(srv)),
+		DecodeApproveRequest,
+		EncodeApproveResponse,
+		logger,
+		opts...,
+	)
+
+	tokenHandler := http.NewServer(
+		MakeTokenEndpoint(srv),
+		DecodeTokenRequest,
+		EncodeTokenResponse,
+		logger,
+	)
+
+	userInfoHandler := http.NewServer(
+		MakeUserInfoEndpoint(srv),
+		DecodeUserInfoRequest,
+		EncodeUserInfoResponse,
+		logger,
+	)
+
+	discoveryHandler := http.NewServer(
+		MakeDiscoveryEndpoint(srv),
+		DecodeDiscoveryRequest,
+		EncodeDiscoveryResponse,
+		logger,
+	)
+
+	logoutHandler := http.NewServer(
+		MakeLogoutEndpoint(srv),
+		DecodeLogoutRequest,
+		EncodeLogoutResponse,
+		logger,
+	)
+
+	createServiceAccountHandler := http.NewServer(
+		authenticated(MakeCreateServiceAccountEndpoint(srv)),
+		DecodeCreateServiceAccountRequest,
+		EncodeServiceAccountResponse,
+		logger,
+		opts...,
+	)
+
+	rotateClientCredentialsHandler := http.NewServer(
+		authenticated(MakeRotateClientCredentialsEndpoint(srv)),
+		DecodeRotateClientCredentialsRequest,
+		EncodeClientCredentialsResponse,
+		logger,
+		opts...,
+	)
+
+	deviceAuthorizationHandler := http.NewServer(
+		MakeDeviceAuthorizationEndpoint(srv),
+		DecodeDeviceAuthorizationRequest,
+		EncodeDeviceAuthorizationResponse,
+		logger,
+	)
+
+	deviceHandler := http.NewServer(
+		authenticated(MakeDeviceEndpoint(srv)),
+		DecodeDeviceRequest,
+		EncodeConsentResponse,
+		logger,
+		opts...,
+	)
+
+	deviceApproveHandler := http.NewServer(
+		authenticated(MakeDeviceApproveEndpoint(srv)),
+		DecodeDeviceApproveRequest,
+		EncodeDeviceApproveResponse,
+		logger,
+		opts...,
+	)
+
+	router.Handle("/.well-known/openid-configuration", discoveryHandler).Methods("GET")
+	router.Handle("/oauth/authorize", authorizeHandler).Methods("GET")
+	router.Handle("/oauth/authorize/consent", consentHandler).Methods("GET")
+	router.Handle("/oauth/authorize/consent", approveHandler).Methods("POST")
+	router.Handle("/oauth/token", tokenHandler).Methods("POST")
+	router.Handle("/oauth/device_authorization", deviceAuthorizationHandler).Methods("POST")
+	router.Handle("/oauth/device", deviceHandler).Methods("GET")
+	router.Handle("/oauth/device", deviceApproveHandler).Methods("POST")
+	router.Handle("/oauth/introspect", introspectHandler).Methods("POST")
+	router.Handle("/oauth/revoke", revokeHandler).Methods("POST")
+	router.Handle("/oauth/userinfo", userInfoHandler).Methods("GET", "POST")
+	router.Handle("/oauth/logout", logoutHandler).Methods("GET", "POST")
+	router.Handle("/oauth/service-accounts", createServiceAccountHandler).Methods("POST")
+	router.Handle("/oauth/clients/{client_id}/credentials", rotateClientCredentialsHandler).Methods("POST")
+}
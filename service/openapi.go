@@ -0,0 +1,314 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nori-io/nori-common/interfaces"
+	"github.com/sirupsen/logrus"
+)
+
+// apiRoute describes the route registered by Transport, the OpenAPI document is built from them
+type apiRoute struct {
+	Method  string
+	Path    string
+	Summary string
+	// Request and Response are values of the types decoded from and encoded to JSON bodies, nil when there is no body
+	Request  interface{}
+	Response interface{}
+	// Query maps the query parameters to descriptions
+	Query         map[string]string
+	Authenticated bool
+	// Download is the content type of the file sent instead of the JSON response
+	Download string
+}
+
+// apiRoutes must be kept in sync with Transport, the drift is logged when the routes are registered
+var apiRoutes = []apiRoute{
+	{Method: "POST", Path: "/auth/signup", Summary: "Create the account",
+		Request: SignUpRequest{}, Response: SignUpResponse{}},
+	{Method: "POST", Path: "/auth/signin", Summary: "Sign in with email and password",
+		Request: SignInRequest{}, Response: SignInResponse{}},
+	{Method: "GET", Path: "/auth/signout", Summary: "Close the session of the token",
+		Response: SignOutResponse{}, Authenticated: true},
+	{Method: "POST", Path: "/auth/token/refresh", Summary: "Replace the token with the token of a new session",
+		Response: RefreshTokenResponse{}, Authenticated: true},
+	{Method: "GET", Path: "/auth/token/verify", Summary: "Return the user of the token",
+		Response: VerifyTokenResponse{}, Authenticated: true},
+	{Method: "POST", Path: "/auth/password/change", Summary: "Change the password, the expired password sessions are accepted",
+		Request: ChangePasswordRequest{}, Response: ChangePasswordResponse{}, Authenticated: true},
+	{Method: "POST", Path: "/auth/password/forgot", Summary: "Mail the password reset link",
+		Request: ForgotPasswordRequest{}, Response: ForgotPasswordResponse{}},
+	{Method: "POST", Path: "/auth/password/reset", Summary: "Set the password with the token of the reset link",
+		Request: ResetPasswordRequest{}, Response: ResetPasswordResponse{}},
+	{Method: "DELETE", Path: "/auth/me", Summary: "Schedule deletion of the account",
+		Request: DeleteAccountRequest{}, Response: DeleteAccountResponse{}, Authenticated: true},
+	{Method: "GET", Path: "/auth/me/export", Summary: "Download the data of the account",
+		Response: UserExport{}, Query: exportQuery, Authenticated: true, Download: "application/zip"},
+	{Method: "GET", Path: "/auth/users/export", Summary: "Download the users, admins only",
+		Query: exportUsersQuery, Authenticated: true, Download: "text/csv"},
+	{Method: "GET", Path: "/auth/users/{id:[0-9]+}/export", Summary: "Download the data of the user, admins only",
+		Response: UserExport{}, Query: exportQuery, Authenticated: true, Download: "application/zip"},
+	{Method: "POST", Path: "/auth/users/{id:[0-9]+}/suspend", Summary: "Suspend the user, admins only",
+		Response: SuspendUserResponse{}, Authenticated: true},
+	{Method: "POST", Path: "/auth/users/{id:[0-9]+}/unsuspend", Summary: "Reinstate the suspended user, admins only",
+		Response: SuspendUserResponse{}, Authenticated: true},
+	{Method: "GET", Path: "/auth/mail/templates/{name}/preview", Summary: "Render the email with sample data, admins only",
+		Response: MailPreviewResponse{}, Query: mailPreviewQuery, Authenticated: true},
+	{Method: "GET", Path: "/auth/openapi.json", Summary: "This document"},
+}
+
+var exportQuery = map[string]string{"format": "zip to get the export as zip archive, json by default"}
+
+var mailPreviewQuery = map[string]string{"locale": "language of the email, the Accept-Language header is used by default"}
+
+var exportUsersQuery = map[string]string{
+	"format": "csv or jsonl, jsonl by default",
+	"fields": "comma separated fields, all by default: " + strings.Join(UsersExportFields, ", "),
+	"type":   "type of the exported users, all types by default",
+}
+
+// pathVariable matches mux path variables, their patterns are dropped in the document
+var pathVariable = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// OpenAPI returns the OpenAPI 3 document of the routes
+func OpenAPI() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	for _, route := range apiRoutes {
+		path := pathVariable.ReplaceAllString(route.Path, "{$1}")
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+
+		params := []interface{}{}
+		for _, m := range pathVariable.FindAllStringSubmatch(route.Path, -1) {
+			schema := map[string]interface{}{"type": "string"}
+			if m[2] == ":[0-9]+" {
+				schema = map[string]interface{}{"type": "integer", "format": "int64"}
+			}
+			params = append(params, map[string]interface{}{
+				"name": m[1], "in": "path", "required": true, "schema": schema,
+			})
+		}
+		for _, name := range sortedKeys(route.Query) {
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "query", "description": route.Query[name],
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		content := map[string]interface{}{}
+		if route.Response != nil {
+			content["application/json"] = map[string]interface{}{"schema": schemaOf(reflect.TypeOf(route.Response), schemas)}
+		}
+		if route.Download != "" {
+			content[route.Download] = map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}
+		}
+		ok200 := map[string]interface{}{"description": "OK"}
+		if len(content) > 0 {
+			ok200["content"] = content
+		}
+
+		operation := map[string]interface{}{
+			"summary":    route.Summary,
+			"parameters": params,
+			"responses": map[string]interface{}{
+				"200":     ok200,
+				"default": map[string]interface{}{"description": "Error"},
+			},
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(route.Request), schemas)},
+				},
+			}
+		}
+		if route.Authenticated {
+			operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Nori auth",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// OpenAPIHandler serves the document, it is mounted on /auth/openapi.json
+func OpenAPIHandler(logger *logrus.Logger) http.Handler {
+	doc, err := json.Marshal(OpenAPI())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			logger.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// schemaOf returns the schema of the type, structs are added to schemas and referenced
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			// the placeholder stops the recursion of self referencing types
+			schemas[t.Name()] = map[string]interface{}{}
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema lists the fields the way encoding/json writes them. The error and the
+// status code of the responses are written by the transport, they aren't in the body
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	var fields func(t reflect.Type)
+	fields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				fields(f.Type)
+				continue
+			}
+			if f.PkgPath != "" || f.Type == errorType || f.Name == "HttpStatusCode" {
+				continue
+			}
+			name := f.Name
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+				}
+			}
+			properties[name] = schemaOf(f.Type, schemas)
+			if strings.Contains(f.Tag.Get("valid"), "required") {
+				required = append(required, name)
+			}
+		}
+	}
+	fields(t)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// routeRecorder keeps the routes registered by Transport for the drift check
+type routeRecorder struct {
+	interfaces.Http
+	routes []*mux.Route
+}
+
+func (r *routeRecorder) Handle(path string, handler http.Handler) *mux.Route {
+	route := r.Http.Handle(path, handler)
+	r.routes = append(r.routes, route)
+	return route
+}
+
+// specDrift returns the routes which are registered but not documented and the other way round
+func specDrift(routes []*mux.Route) []string {
+	documented := map[string]bool{}
+	for _, route := range apiRoutes {
+		documented[route.String()] = true
+	}
+	registered := map[string]bool{}
+	var drift []string
+	for _, route := range routes {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			continue
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			key := method + " " + path
+			registered[key] = true
+			if !documented[key] {
+				drift = append(drift, key+" is not in the OpenAPI document")
+			}
+		}
+	}
+	for _, route := range apiRoutes {
+		if key := route.String(); !registered[key] {
+			drift = append(drift, key+" is documented but not registered")
+		}
+	}
+	return drift
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r apiRoute) String() string {
+	return fmt.Sprintf("%s %s", r.Method, r.Path)
+}
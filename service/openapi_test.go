@@ -0,0 +1,45 @@
+package service
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/breach"
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/fakes"
+)
+
+// TestSpecDrift fails when a route is added to Transport without documenting it in apiRoutes
+func TestSpecDrift(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	db := database.NewMemory()
+	auth := fakes.NewAuth()
+	session := fakes.NewSession()
+	router := fakes.NewRouter()
+	cfg := &Config{
+		Sub: func() string { return "user" },
+		Iss: func() string { return "auth" },
+	}
+	srv := NewService(auth, session, cfg, logger, db, nil, breach.NewChecker(breach.Config{}), db.RevokedTokens())
+	Transport(auth, fakes.NewTransport(), session, db.RevokedTokens(), router, srv, nil, logger)
+
+	var routes []*mux.Route
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		routes = append(routes, route)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) == 0 {
+		t.Fatal("no routes are registered")
+	}
+	for _, drift := range specDrift(routes) {
+		t.Error(drift)
+	}
+}
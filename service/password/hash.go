@@ -0,0 +1,46 @@
+package password
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const bcryptPrefix = "$2"
+
+func Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify reports whether password matches the stored hash, rehash is set
+// when the hash is not in the current format and should be replaced with Hash.
+// Hashes imported from other systems are checked by their format, salt is the
+// auth.salt column used by salted SHA-256. Values in unknown formats are rejected
+// unless plaintext is set: then they are taken for the passwords stored before
+// hashing was introduced and compared as plain text
+func Verify(hash, salt, password string, plaintext bool) (ok bool, rehash bool) {
+	if password == "" || hash == "" {
+		return false, false
+	}
+	if !strings.HasPrefix(hash, bcryptPrefix) {
+		if verify := legacy(hash, salt); verify != nil {
+			ok = verify(hash, salt, password)
+			return ok, ok
+		}
+		if !plaintext {
+			return false, false
+		}
+		ok = subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+		return ok, ok
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err == nil && cost < bcrypt.DefaultCost
+}
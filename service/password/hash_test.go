@@ -0,0 +1,44 @@
+package password
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerify(t *testing.T) {
+	hash, err := Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("salt" + "secret"))
+	salted := hex.EncodeToString(sum[:])
+
+	for _, tc := range []struct {
+		name       string
+		hash, salt string
+		password   string
+		plaintext  bool
+		ok, rehash bool
+	}{
+		{name: "bcrypt", hash: hash, password: "secret", ok: true},
+		{name: "wrong password", hash: hash, password: "public"},
+		{name: "bcrypt of low cost", hash: string(weak), password: "secret", ok: true, rehash: true},
+		{name: "salted sha256", hash: salted, salt: "salt", password: "secret", ok: true, rehash: true},
+		{name: "plain text", hash: "secret", password: "secret"},
+		{name: "plain text migration", hash: "secret", password: "secret", plaintext: true, ok: true, rehash: true},
+		{name: "plain text migration, wrong password", hash: "secret", password: "public", plaintext: true},
+		{name: "empty password", hash: "", password: "", plaintext: true},
+	} {
+		ok, rehash := Verify(tc.hash, tc.salt, tc.password, tc.plaintext)
+		if ok != tc.ok || rehash != tc.rehash {
+			t.Errorf("%s: Verify = %v, %v, want %v, %v", tc.name, ok, rehash, tc.ok, tc.rehash)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Formats of the hashes imported from other systems, they are accepted by Verify
+// and replaced with Hash on the next sign in
+const (
+	FormatBcrypt       = "bcrypt"
+	FormatPBKDF2SHA256 = "pbkdf2_sha256"
+	FormatScrypt       = "scrypt"
+	FormatSaltedSHA256 = "salted_sha256"
+	FormatDjangoBcrypt = "django_bcrypt"
+	FormatPHPass       = "phpass"
+)
+
+// limits of the work factors read from the hashes, the bigger ones are rejected
+// instead of keeping the sign in busy for minutes
+const (
+	maxPBKDF2Iterations = 10000000
+	maxScryptN          = 1 << 20
+)
+
+type legacyVerify func(hash, salt, password string) bool
+
+// Format returns the format of the stored hash, an empty string when it isn't a known hash
+func Format(hash, salt string) string {
+	switch {
+	case strings.HasPrefix(hash, bcryptPrefix):
+		return FormatBcrypt
+	case strings.HasPrefix(hash, "pbkdf2_sha256$"), strings.HasPrefix(hash, "$pbkdf2-sha256$"):
+		return FormatPBKDF2SHA256
+	case strings.HasPrefix(hash, "scrypt$"):
+		return FormatScrypt
+	case strings.HasPrefix(hash, "bcrypt_sha256$"), strings.HasPrefix(hash, "bcrypt$"):
+		return FormatDjangoBcrypt
+	case strings.HasPrefix(hash, "$P$"), strings.HasPrefix(hash, "$H$"):
+		return FormatPHPass
+	case salt != "" && len(hash) == sha256.Size*2 && isHex(hash):
+		return FormatSaltedSHA256
+	}
+	return ""
+}
+
+func legacy(hash, salt string) legacyVerify {
+	switch Format(hash, salt) {
+	case FormatPBKDF2SHA256:
+		return verifyPBKDF2
+	case FormatScrypt:
+		return verifyScrypt
+	case FormatDjangoBcrypt:
+		return verifyDjangoBcrypt
+	case FormatPHPass:
+		return verifyPHPass
+	case FormatSaltedSHA256:
+		return verifySaltedSHA256
+	}
+	return nil
+}
+
+// verifyPBKDF2 checks Django "pbkdf2_sha256$iterations$salt$hash" and
+// passlib "$pbkdf2-sha256$iterations$salt$hash" hashes
+func verifyPBKDF2(hash, _, password string) bool {
+	passlib := strings.HasPrefix(hash, "$")
+	parts := strings.Split(strings.TrimPrefix(hash, "$"), "$")
+	if len(parts) != 4 {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > maxPBKDF2Iterations {
+		return false
+	}
+	salt, want := []byte(parts[2]), []byte(nil)
+	if passlib {
+		// passlib uses base64 with "." instead of "+" and without padding
+		if salt, err = abase64(parts[2]); err != nil {
+			return false
+		}
+		want, err = abase64(parts[3])
+	} else {
+		want, err = base64.StdEncoding.DecodeString(parts[3])
+	}
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got := pbkdf2.Key([]byte(password), salt, iterations, len(want), sha256.New)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// verifyScrypt checks Django "scrypt$N$salt$r$p$hash" hashes
+func verifyScrypt(hash, _, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	n, errN := strconv.Atoi(parts[1])
+	r, errR := strconv.Atoi(parts[3])
+	p, errP := strconv.Atoi(parts[4])
+	if errN != nil || errR != nil || errP != nil || n > maxScryptN || r < 1 || p < 1 || r*p >= 1<<30 {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got, err := scrypt.Key([]byte(password), []byte(parts[2]), n, r, p, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// verifyDjangoBcrypt checks Django "bcrypt_sha256$<bcrypt>" hashes of the hex SHA-256 of the
+// password and "bcrypt$<bcrypt>" hashes of the password itself
+func verifyDjangoBcrypt(hash, _, password string) bool {
+	i := strings.IndexByte(hash, '$')
+	algorithm, bcryptHash := hash[:i], hash[i+1:]
+	if algorithm == "bcrypt_sha256" {
+		sum := sha256.Sum256([]byte(password))
+		password = hex.EncodeToString(sum[:])
+	}
+	return bcrypt.CompareHashAndPassword([]byte(bcryptHash), []byte(password)) == nil
+}
+
+// verifySaltedSHA256 checks the hex SHA-256 of the salt followed by the password
+func verifySaltedSHA256(hash, salt, password string) bool {
+	sum := sha256.Sum256([]byte(salt + password))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(hash))) == 1
+}
+
+const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// verifyPHPass checks the portable hashes of phpass used by WordPress and phpBB
+func verifyPHPass(hash, _, password string) bool {
+	if len(hash) != 34 {
+		return false
+	}
+	countLog2 := strings.IndexByte(itoa64, hash[3])
+	if countLog2 < 7 || countLog2 > 30 {
+		return false
+	}
+	salt := hash[4:12]
+
+	sum := md5.Sum([]byte(salt + password))
+	for count := 1 << countLog2; count > 0; count-- {
+		sum = md5.Sum(append(sum[:], password...))
+	}
+	got := hash[:12] + phpassEncode(sum[:])
+	return subtle.ConstantTimeCompare([]byte(got), []byte(hash)) == 1
+}
+
+// phpassEncode is the little endian base64 of phpass
+func phpassEncode(src []byte) string {
+	var out strings.Builder
+	for i := 0; i < len(src); {
+		value := int(src[i])
+		i++
+		out.WriteByte(itoa64[value&0x3f])
+		if i < len(src) {
+			value |= int(src[i]) << 8
+		}
+		out.WriteByte(itoa64[(value>>6)&0x3f])
+		if i >= len(src) {
+			break
+		}
+		i++
+		if i < len(src) {
+			value |= int(src[i]) << 16
+		}
+		out.WriteByte(itoa64[(value>>12)&0x3f])
+		if i >= len(src) {
+			break
+		}
+		i++
+		out.WriteByte(itoa64[(value>>18)&0x3f])
+	}
+	return out.String()
+}
+
+func abase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
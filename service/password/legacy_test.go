@@ -0,0 +1,66 @@
+package password
+
+import "testing"
+
+// the hashes of "correct horse" made by the reference implementations
+const (
+	djangoPBKDF2  = "pbkdf2_sha256$1000$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso="
+	passlibPBKDF2 = "$pbkdf2-sha256$1000$AAECAwQFBgcICQoLDA0ODw$yRTMTwbMbo9G0VfjobWqerzuuxe7BETNTErBbKKumGQ"
+	djangoScrypt  = "scrypt$1024$seasalt$8$1$b9vqOe0B5HZh2f10Q9RPN9OFNPtmb+ezp5YOlnZsJQ0vQ5I3eq8Zt7GFMuqQ58xCxTIvVL1QCL/PTXEWr+RNHA=="
+	djangoBcrypt  = "bcrypt_sha256$$2a$04$4BOb5EgWSGOUc1Z19KnqJe3DrrqSdmN/iVZv3qAc.XZOz8FapU4Hy"
+	wordpress     = "$P$BsaltSALTcttLpWkgP8mXe6kPORBKs."
+	phpBB         = "$H$9abcdefghPDonBA7cb6Wv7y6IfLfEF."
+)
+
+func TestLegacy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		hash     string
+		password string
+		format   string
+	}{
+		{"django pbkdf2", djangoPBKDF2, "correct horse", FormatPBKDF2SHA256},
+		{"passlib pbkdf2", passlibPBKDF2, "correct horse", FormatPBKDF2SHA256},
+		{"django scrypt", djangoScrypt, "correct horse", FormatScrypt},
+		{"django bcrypt_sha256", djangoBcrypt, "correct horse", FormatDjangoBcrypt},
+		// OpenBSD bcrypt test vector
+		{"django bcrypt", "bcrypt$$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", FormatDjangoBcrypt},
+		{"wordpress", wordpress, "correct horse", FormatPHPass},
+		{"phpbb", phpBB, "correct horse", FormatPHPass},
+		// phpass test vector
+		{"phpass", "$P$9IQRaTwmfeRo7ud9Fh4E2PdI0S3r.L0", "test12345", FormatPHPass},
+	} {
+		if format := Format(tc.hash, ""); format != tc.format {
+			t.Errorf("%s: Format = %q, want %q", tc.name, format, tc.format)
+		}
+		if ok, rehash := Verify(tc.hash, "", tc.password, false); !ok || !rehash {
+			t.Errorf("%s: Verify = %v, %v, want true, true", tc.name, ok, rehash)
+		}
+		if ok, _ := Verify(tc.hash, "", tc.password+"!", false); ok {
+			t.Errorf("%s: wrong password is accepted", tc.name)
+		}
+	}
+}
+
+func TestLegacyMalformed(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		hash     string
+		password string
+	}{
+		{"pbkdf2 iterations", "pbkdf2_sha256$0$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso=", "correct horse"},
+		{"pbkdf2 work factor", "pbkdf2_sha256$99999999$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso=", "correct horse"},
+		{"pbkdf2 parts", "pbkdf2_sha256$1000$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso=", "correct horse"},
+		{"scrypt salt before N", "scrypt$seasalt$1024$8$1$b9vqOe0B5HZh2f10Q9RPN9OFNPtmb+ezp5YOlnZsJQ0vQ5I3eq8Zt7GFMuqQ58xCxTIvVL1QCL/PTXEWr+RNHA==", "correct horse"},
+		{"scrypt work factor", "scrypt$2097152$seasalt$8$1$b9vqOe0B5HZh2f10Q9RPN9OFNPtmb+ezp5YOlnZsJQ0vQ5I3eq8Zt7GFMuqQ58xCxTIvVL1QCL/PTXEWr+RNHA==", "correct horse"},
+		{"scrypt empty hash", "scrypt$1024$seasalt$8$1$", "correct horse"},
+		{"phpass length", "$P$BsaltSALTcttLpWkgP8mXe6kPORBKs", "correct horse"},
+		{"phpass iterations", "$P$zsaltSALTcttLpWkgP8mXe6kPORBKs.", "correct horse"},
+		// bcrypt_sha256 hashes the hex SHA-256, not the password itself
+		{"bcrypt_sha256 of the password", "bcrypt_sha256$$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U"},
+	} {
+		if ok, _ := Verify(tc.hash, "", tc.password, false); ok {
+			t.Errorf("%s: %q is accepted", tc.name, tc.hash)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package password validates new passwords against the configured policy
+package password
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMinLength = 8
+	defaultMaxLength = 64
+	// maxBytes is the longest input bcrypt hashes, it caps MaxLength for the multibyte characters
+	maxBytes = 72
+)
+
+// common passwords which are always rejected
+var builtinBanned = []string{
+	"password", "password1", "12345678", "123456789", "1234567890", "qwerty123",
+	"qwertyuiop", "11111111", "iloveyou", "letmein1", "welcome1", "admin123",
+}
+
+// Config reads policy from the config manager, every rule is applied on each check
+type Config struct {
+	MinLength     func() int
+	MaxLength     func() int
+	RequireUpper  func() bool
+	RequireLower  func() bool
+	RequireDigit  func() bool
+	RequireSymbol func() bool
+	// Banned is a comma separated list of passwords
+	Banned func() string
+	// BannedFile is a path to a file with a banned password per line
+	BannedFile func() string
+	// MaxEmailSimilarity is the percentage of similarity to the email that is still allowed, 0 disables the rule
+	MaxEmailSimilarity func() int
+	// MinScore is the minimal strength score from 0 to 4
+	MinScore func() int
+}
+
+// Violation is a broken policy rule
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+type Policy struct {
+	cfg Config
+
+	mu         sync.Mutex
+	bannedPath string
+	bannedFile map[string]struct{}
+}
+
+func NewPolicy(cfg Config) *Policy {
+	return &Policy{cfg: cfg}
+}
+
+// Validate returns all rules password breaks, email is used by the similarity rule
+func (p *Policy) Validate(password, email string) []Violation {
+	var violations []Violation
+	add := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if min := intValue(p.cfg.MinLength, defaultMinLength); length < min {
+		add("min_length", "Password is too short.")
+	}
+	if max := intValue(p.cfg.MaxLength, defaultMaxLength); length > max || len(password) > maxBytes {
+		add("max_length", "Password is too long.")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if boolValue(p.cfg.RequireUpper) && !upper {
+		add("upper", "Password must contain an uppercase letter.")
+	}
+	if boolValue(p.cfg.RequireLower) && !lower {
+		add("lower", "Password must contain a lowercase letter.")
+	}
+	if boolValue(p.cfg.RequireDigit) && !digit {
+		add("digit", "Password must contain a digit.")
+	}
+	if boolValue(p.cfg.RequireSymbol) && !symbol {
+		add("symbol", "Password must contain a symbol.")
+	}
+
+	if p.banned(password) {
+		add("banned", "Password is too common.")
+	}
+
+	if max := intValue(p.cfg.MaxEmailSimilarity, 0); max > 0 && email != "" && similarity(password, email) > max {
+		add("email_similarity", "Password is too similar to the email.")
+	}
+
+	if min := intValue(p.cfg.MinScore, 0); Score(password) < min {
+		add("score", "Password is too weak.")
+	}
+
+	return violations
+}
+
+func (p *Policy) banned(password string) bool {
+	password = strings.ToLower(password)
+	for _, b := range builtinBanned {
+		if password == b {
+			return true
+		}
+	}
+	if p.cfg.Banned != nil {
+		for _, b := range strings.Split(p.cfg.Banned(), ",") {
+			if b = strings.TrimSpace(b); b != "" && strings.ToLower(b) == password {
+				return true
+			}
+		}
+	}
+	_, ok := p.fileBanned()[password]
+	return ok
+}
+
+// fileBanned loads the banned file once per configured path
+func (p *Policy) fileBanned() map[string]struct{} {
+	path := ""
+	if p.cfg.BannedFile != nil {
+		path = p.cfg.BannedFile()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if path == p.bannedPath && p.bannedFile != nil {
+		return p.bannedFile
+	}
+	p.bannedPath = path
+	p.bannedFile = map[string]struct{}{}
+	if path == "" {
+		return p.bannedFile
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return p.bannedFile
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.bannedFile[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return p.bannedFile
+}
+
+// Score estimates password strength from 0 (very weak) to 4 (strong)
+func Score(password string) int {
+	var pool float64
+	var lower, upper, digit, symbol bool
+	unique := map[rune]struct{}{}
+	for _, r := range password {
+		unique[r] = struct{}{}
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	// repeated characters add almost nothing to the guessing effort
+	bits := float64(len(unique)) * math.Log2(pool)
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 90:
+		return 3
+	}
+	return 4
+}
+
+// similarity of the password to the email local part in percents
+func similarity(password, email string) int {
+	local := strings.ToLower(email)
+	if i := strings.IndexByte(local, '@'); i >= 0 {
+		local = local[:i]
+	}
+	password = strings.ToLower(password)
+	if len(local) >= 3 && strings.Contains(password, local) {
+		return 100
+	}
+
+	a, b := []rune(password), []rune(local)
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 100 - levenshtein(a, b)*100/longest
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func intValue(f func() int, def int) int {
+	if f == nil {
+		return def
+	}
+	if v := f(); v > 0 {
+		return v
+	}
+	return def
+}
+
+func boolValue(f func() bool) bool {
+	return f != nil && f()
+}
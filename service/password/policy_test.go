@@ -0,0 +1,118 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	banned := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(banned, []byte("Tr0ub4dor&3\n\n  correcthorse  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	on := func() bool { return true }
+	value := func(v int) func() int { return func() int { return v } }
+
+	for _, tc := range []struct {
+		name     string
+		cfg      Config
+		password string
+		email    string
+		rules    []string
+	}{
+		{name: "defaults", password: "Xy7!kq2Lmn#p"},
+		{name: "too short", password: "Xy7!kq", rules: []string{"min_length"}},
+		{name: "configured min length", cfg: Config{MinLength: value(16)}, password: "Xy7!kq2Lmn#p", rules: []string{"min_length"}},
+		{name: "too long", password: strings.Repeat("x", 65), rules: []string{"max_length"}},
+		{name: "configured max length", cfg: Config{MaxLength: value(10)}, password: "Xy7!kq2Lmn#p", rules: []string{"max_length"}},
+		{name: "length in characters", password: strings.Repeat("я", 36)},
+		{name: "over 72 bytes", password: strings.Repeat("я", 37), rules: []string{"max_length"}},
+		{name: "max length over 72 bytes", cfg: Config{MaxLength: value(100)}, password: strings.Repeat("x", 73), rules: []string{"max_length"}},
+		{
+			name:     "character classes",
+			cfg:      Config{RequireUpper: on, RequireLower: on, RequireDigit: on, RequireSymbol: on},
+			password: "Xy7!kq2Lmn#p",
+		},
+		{
+			name:     "missing character classes",
+			cfg:      Config{RequireUpper: on, RequireLower: on, RequireDigit: on, RequireSymbol: on},
+			password: "abcdefghij",
+			rules:    []string{"upper", "digit", "symbol"},
+		},
+		{name: "non latin letters", cfg: Config{RequireUpper: on, RequireLower: on}, password: "Пароль-ёжик"},
+		{name: "builtin banned", password: "Password1", rules: []string{"banned"}},
+		{name: "config banned", cfg: Config{Banned: func() string { return "hunter22, Swordfish" }}, password: "swordfish", rules: []string{"banned"}},
+		{name: "file banned", cfg: Config{BannedFile: func() string { return banned }}, password: "CorrectHorse", rules: []string{"banned"}},
+		{name: "missing banned file", cfg: Config{BannedFile: func() string { return banned + ".missing" }}, password: "correcthorse"},
+		{
+			name:     "contains email",
+			cfg:      Config{MaxEmailSimilarity: value(50)},
+			password: "JohnSmith2024",
+			email:    "johnsmith@example.com",
+			rules:    []string{"email_similarity"},
+		},
+		{
+			name:     "similar to email",
+			cfg:      Config{MaxEmailSimilarity: value(50)},
+			password: "jonsmitt",
+			email:    "johnsmith@example.com",
+			rules:    []string{"email_similarity"},
+		},
+		{name: "unlike email", cfg: Config{MaxEmailSimilarity: value(50)}, password: "Xy7!kq2Lmn#p", email: "johnsmith@example.com"},
+		{name: "similarity disabled", password: "johnsmith2024", email: "johnsmith@example.com"},
+		{name: "weak", cfg: Config{MinScore: value(3)}, password: "abcdefgh", rules: []string{"score"}},
+		{name: "strong enough", cfg: Config{MinScore: value(3)}, password: "Xy7!kq2Lmn#p"},
+		{name: "every rule", cfg: Config{RequireDigit: on, MinScore: value(4)}, password: "qwerty", rules: []string{"min_length", "digit", "score"}},
+	} {
+		var rules []string
+		for _, v := range NewPolicy(tc.cfg).Validate(tc.password, tc.email) {
+			rules = append(rules, v.Rule)
+		}
+		if !reflect.DeepEqual(rules, tc.rules) {
+			t.Errorf("%s: rules = %v, want %v", tc.name, rules, tc.rules)
+		}
+	}
+}
+
+func TestBannedFileReload(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.txt"), filepath.Join(dir, "second.txt")
+	if err := os.WriteFile(first, []byte("alphabravo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("charliedelta\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := first
+	policy := NewPolicy(Config{BannedFile: func() string { return path }})
+
+	if !policy.banned("alphabravo") || policy.banned("charliedelta") {
+		t.Fatal("first file is not applied")
+	}
+	// the file is loaded again when the config points to another one
+	path = second
+	if policy.banned("alphabravo") || !policy.banned("charliedelta") {
+		t.Error("second file is not applied")
+	}
+}
+
+func TestScore(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		score    int
+	}{
+		{"", 0},
+		{"aaaaaaaaaaaaaaaa", 0},
+		{"abcdef", 1},
+		{"abcdefgh", 2},
+		{"Xy7!kq2Lmn#p", 3},
+		{"Xy7!kq2Lmn#pZ$", 4},
+	} {
+		if got := Score(tc.password); got != tc.score {
+			t.Errorf("Score(%q) = %d, want %d", tc.password, got, tc.score)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/cheebo/gorest"
+	"github.com/cheebo/rand"
+	"github.com/nori-io/nori-common/interfaces"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/password"
+	"github.com/nori-io/auth/service/templates"
+)
+
+// scopePasswordChange is the token scope of sessions restricted by the password expiry
+const scopePasswordChange = "password_change"
+
+func (s *service) ChangePassword(ctx context.Context, req ChangePasswordRequest) (resp *ChangePasswordResponse) {
+	resp = &ChangePasswordResponse{}
+	errField := rest.ErrFieldResp{
+		Meta: rest.ErrFieldRespMeta{
+			ErrCode: 400,
+		},
+	}
+
+	// the route skips session verification, so sessions restricted by
+	// the password expiry can reach it
+	sid := s.session.SessionId(ctx)
+	var state interfaces.SessionState
+	if err := s.session.Get(sid, &state); err != nil || (state != interfaces.SessionActive && state != interfaces.SessionLocked) {
+		resp.Err = httpError(401, i18n.Text(ctx, msgUnauthorized))
+		return resp
+	}
+
+	caller, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	model, err := s.db.Auth().FindByUserID(ctx, caller.Id)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	if !s.checkPassword(ctx, model, req.OldPassword) {
+		errField.AddError("old_password", 400, i18n.Text(ctx, msgPasswordIncorrect))
+	}
+	s.validatePassword(ctx, "new_password", req.NewPassword, model.Email_Auth, &errField)
+	s.checkReuse(ctx, "new_password", model.UserId_Auth, req.NewPassword, &errField)
+	if errField.HasErrors() {
+		resp.Err = errField
+		return resp
+	}
+
+	if err := s.setPassword(ctx, model, req.NewPassword); err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	if state == interfaces.SessionLocked {
+		s.session.Save(sid, interfaces.SessionActive, 0)
+	}
+	// other sessions may belong to whoever knew the old password
+	if err := s.revokeSessions(ctx, model.UserId_Auth, string(sid)); err != nil {
+		s.log.Error(err)
+	}
+	s.notifyPasswordChanged(s.userLanguages(ctx, model.UserId_Auth), model.Email_Auth)
+	return resp
+}
+
+func (s *service) ForgotPassword(ctx context.Context, req ForgotPasswordRequest) (resp *ForgotPasswordResponse) {
+	resp = &ForgotPasswordResponse{}
+
+	model, err := s.db.Auth().FindByEmail(ctx, req.Email)
+	if errors.Is(err, database.ErrNotFound) {
+		// the response doesn't tell whether the email is registered
+		return resp
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	token := rand.RandomAlphaNum(40)
+	now := time.Now()
+	err = s.db.PasswordResets().Create(ctx, &database.PasswordResetModel{
+		UserId:    model.UserId_Auth,
+		TokenHash: hashToken(token),
+		Created:   now,
+		Expires:   now.Add(duration(s.cfg.ResetTTL, defaultResetTTL)),
+	})
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	data := templates.Data{
+		Link:    s.cfg.ResetURL() + token,
+		Expires: now.Add(duration(s.cfg.ResetTTL, defaultResetTTL)),
+	}
+	if err := s.sendMail(s.userLanguages(ctx, model.UserId_Auth), model.Email_Auth, templates.PasswordReset, data); err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	return resp
+}
+
+func (s *service) ResetPassword(ctx context.Context, req ResetPasswordRequest) (resp *ResetPasswordResponse) {
+	resp = &ResetPasswordResponse{}
+	errField := rest.ErrFieldResp{
+		Meta: rest.ErrFieldRespMeta{
+			ErrCode: 400,
+		},
+	}
+
+	reset, err := s.db.PasswordResets().FindByTokenHash(ctx, hashToken(req.Token))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	if reset == nil || reset.Used != nil || reset.Expires.Before(time.Now()) {
+		errField.AddError("token", 400, i18n.Text(ctx, msgResetLinkInvalid))
+		resp.Err = errField
+		return resp
+	}
+
+	model, err := s.db.Auth().FindByUserID(ctx, reset.UserId)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	s.validatePassword(ctx, "password", req.Password, model.Email_Auth, &errField)
+	s.checkReuse(ctx, "password", model.UserId_Auth, req.Password, &errField)
+	if errField.HasErrors() {
+		resp.Err = errField
+		return resp
+	}
+
+	err = s.db.Tx(ctx, func(tx database.Database) error {
+		if err := tx.PasswordResets().Use(ctx, reset.Id); err != nil {
+			return err
+		}
+		return s.setPasswordTx(ctx, tx, model, req.Password)
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		errField.AddError("token", 400, i18n.Text(ctx, msgResetLinkInvalid))
+		resp.Err = errField
+		return resp
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	// the sessions may belong to whoever knew the old password
+	if err := s.revokeSessions(ctx, model.UserId_Auth, ""); err != nil {
+		s.log.Error(err)
+	}
+	s.notifyPasswordChanged(s.userLanguages(ctx, model.UserId_Auth), model.Email_Auth)
+	return resp
+}
+
+// validatePassword adds an error of field for every broken password policy rule
+func (s *service) validatePassword(ctx context.Context, field, password, email string, errField *rest.ErrFieldResp) {
+	for _, v := range s.policy.Validate(password, email) {
+		errField.AddError(field, 400, i18n.Text(ctx, msgPassword+v.Rule))
+	}
+
+	if !s.breach.Enabled() {
+		return
+	}
+	breached, err := s.breach.Breached(password)
+	if err != nil {
+		s.log.Warn(err)
+		return
+	}
+	if breached {
+		errField.AddError(field, 400, i18n.Text(ctx, msgPasswordBreached))
+	}
+}
+
+func (s *service) setPassword(ctx context.Context, model *database.AuthModel, password string) error {
+	return s.setPasswordTx(ctx, s.db, model, password)
+}
+
+// checkReuse adds an error of field when password is one of the recent passwords of the user
+func (s *service) checkReuse(ctx context.Context, field string, userId uint64, pw string, errField *rest.ErrFieldResp) {
+	limit := s.historySize()
+	if limit == 0 {
+		return
+	}
+	recent, err := s.db.PasswordHistory().Recent(ctx, userId, limit)
+	if err != nil {
+		s.log.Error(err)
+		return
+	}
+	for _, h := range recent {
+		// the history keeps only the hashes
+		if ok, _ := password.Verify(h.Hash, "", pw, false); ok {
+			errField.AddError(field, 400, i18n.Text(ctx, msgPasswordReused))
+			return
+		}
+	}
+}
+
+// setPasswordTx stores the new password using db, which may be a running transaction
+func (s *service) setPasswordTx(ctx context.Context, db database.Database, model *database.AuthModel, pw string) error {
+	hash, err := password.Hash(pw)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	model.Password_Auth = hash
+	model.Salt_Auth = ""
+	model.Updated_Auth = now
+	model.PasswordChanged_Auth = now
+
+	return db.Tx(ctx, func(tx database.Database) error {
+		if err := tx.Auth().Update(ctx, model); err != nil {
+			return err
+		}
+		return s.recordPassword(ctx, tx, model.UserId_Auth, hash)
+	})
+}
+
+// recordPassword adds hash to the password history and drops the ones not needed anymore
+func (s *service) recordPassword(ctx context.Context, db database.Database, userId uint64, hash string) error {
+	limit := s.historySize()
+	if limit == 0 {
+		return nil
+	}
+	err := db.PasswordHistory().Create(ctx, &database.PasswordHistoryModel{
+		UserId:  userId,
+		Hash:    hash,
+		Created: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return db.PasswordHistory().Prune(ctx, userId, limit)
+}
+
+func (s *service) historySize() int {
+	if s.cfg.PasswordHistory == nil || s.cfg.PasswordHistory() < 0 {
+		return 0
+	}
+	return s.cfg.PasswordHistory()
+}
+
+// passwordExpired reports whether the password is older than the configured maximal age
+func (s *service) passwordExpired(model *database.AuthModel) bool {
+	maxAge := duration(s.cfg.PasswordMaxAge, 0)
+	if maxAge == 0 {
+		return false
+	}
+	changed := model.PasswordChanged_Auth
+	if changed.IsZero() {
+		changed = model.Created_Auth
+	}
+	return time.Since(changed) > maxAge
+}
+
+// hashToken is used to store tokens which are sent to users
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
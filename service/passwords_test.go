@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/nori-io/auth/service/templates"
+)
+
+// mailbox keeps the sent messages
+type mailbox struct {
+	mu       sync.Mutex
+	messages map[string][]templates.Message
+}
+
+func (m *mailbox) Send(_ context.Context, to string, message templates.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.messages == nil {
+		m.messages = map[string][]templates.Message{}
+	}
+	m.messages[to] = append(m.messages[to], message)
+	return nil
+}
+
+func (m *mailbox) received(to string) []templates.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages[to]
+}
+
+var resetLink = regexp.MustCompile(`https://example\.com/reset/([A-Za-z0-9]+)`)
+
+func TestResetPassword(t *testing.T) {
+	mail := &mailbox{}
+	server, _ := newConfiguredServer(t, &Config{ResetURL: func() string { return "https://example.com/reset/" }}, mail)
+	signUp(t, server, "user@example.com")
+	token := signIn(t, server, "user@example.com").Token
+
+	if code, body := call(t, server, "POST", "/auth/password/forgot", "", ForgotPasswordRequest{Email: "user@example.com"}); code != http.StatusOK {
+		t.Fatalf("forgot: %d %s", code, body)
+	}
+	messages := mail.received("user@example.com")
+	if len(messages) != 1 {
+		t.Fatalf("received %d messages", len(messages))
+	}
+	link := resetLink.FindStringSubmatch(messages[0].Text)
+	if link == nil {
+		t.Fatalf("no reset link in %q", messages[0].Text)
+	}
+
+	newPassword := testPassword + "Q"
+	req := ResetPasswordRequest{Token: link[1], Password: newPassword}
+	if code, body := call(t, server, "POST", "/auth/password/reset", "", req); code != http.StatusOK {
+		t.Fatalf("reset: %d %s", code, body)
+	}
+	if code, body := call(t, server, "POST", "/auth/password/reset", "", req); code == http.StatusOK {
+		t.Errorf("reset link is used twice: %s", body)
+	}
+
+	// the sessions of the old password are closed
+	if code, body := call(t, server, "GET", "/auth/token/verify", token, nil); code == http.StatusOK {
+		t.Errorf("token of the old password is accepted: %s", body)
+	}
+	if code, body := call(t, server, "POST", "/auth/signin", "", SignInRequest{Email: "user@example.com", Password: testPassword}); code == http.StatusOK {
+		t.Errorf("old password is accepted: %s", body)
+	}
+	if code, body := call(t, server, "POST", "/auth/signin", "", SignInRequest{Email: "user@example.com", Password: newPassword}); code != http.StatusOK {
+		t.Errorf("sign in with the new password: %d %s", code, body)
+	}
+	if n := len(mail.received("user@example.com")); n != 2 {
+		t.Errorf("received %d messages, want the password changed notice", n)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	server := newTestServer(t)
+	signUp(t, server, "user@example.com")
+	current := signIn(t, server, "user@example.com").Token
+	other := signIn(t, server, "user@example.com").Token
+
+	req := ChangePasswordRequest{OldPassword: testPassword + "1", NewPassword: testPassword + "Q"}
+	if code, body := call(t, server, "POST", "/auth/password/change", current, req); code == http.StatusOK {
+		t.Errorf("wrong old password is accepted: %s", body)
+	}
+	req.OldPassword = testPassword
+	if code, body := call(t, server, "POST", "/auth/password/change", current, req); code != http.StatusOK {
+		t.Fatalf("change: %d %s", code, body)
+	}
+
+	// the session which changed the password stays open
+	if code, body := call(t, server, "GET", "/auth/token/verify", current, nil); code != http.StatusOK {
+		t.Errorf("verify: %d %s", code, body)
+	}
+	if code, body := call(t, server, "GET", "/auth/token/verify", other, nil); code == http.StatusOK {
+		t.Errorf("token of the other session is accepted: %s", body)
+	}
+}
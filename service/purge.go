@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/sirupsen/logrus"
+)
+
+// PurgeJob periodically removes accounts which deletion grace period is over and expired revocations
+type PurgeJob struct {
+	db       database.Database
+	interval time.Duration
+	log      *logrus.Logger
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewPurgeJob(db database.Database, cfg *Config, log *logrus.Logger) *PurgeJob {
+	return &PurgeJob{
+		db:       db,
+		interval: duration(cfg.PurgeInterval, defaultPurgeInterval),
+		log:      log,
+	}
+}
+
+func (j *PurgeJob) Start() {
+	j.stop = make(chan struct{})
+	j.done = make(chan struct{})
+
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := j.Run(context.Background()); err != nil {
+				j.log.Error(err)
+			}
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (j *PurgeJob) Stop() {
+	if j.stop == nil {
+		return
+	}
+	close(j.stop)
+	<-j.done
+	j.stop = nil
+}
+
+// Run purges all accounts due for deletion, expired denylist entries and device codes, it returns the purged accounts count
+func (j *PurgeJob) Run(ctx context.Context) (purged int, err error) {
+	if _, err := j.db.RevokedTokens().Purge(ctx, time.Now()); err != nil {
+		return 0, err
+	}
+	if _, err := j.db.DeviceCodes().Purge(ctx, time.Now()); err != nil {
+		return 0, err
+	}
+
+	filter := database.UsersFilter{
+		DeletionDue:    time.Now(),
+		IncludeDeleted: true,
+	}
+	for {
+		users, next, err := j.db.Users().List(ctx, filter)
+		if err != nil {
+			return purged, err
+		}
+		for _, u := range users {
+			if err := j.db.Users().Delete(ctx, u.Id); err != nil {
+				return purged, err
+			}
+			purged++
+			j.log.Infof("account %d purged", u.Id)
+		}
+		if next == 0 {
+			return purged, nil
+		}
+		filter.Cursor = next
+	}
+}
@@ -1,19 +1,29 @@
 package service
 
 import (
-	"github.com/asaskevich/govalidator"
+	"context"
+	"strings"
+
 	"github.com/cheebo/gorest"
+
+	"github.com/nori-io/auth/service/i18n"
 )
 
-// SignUp Request
+// SignUp Request, the optional fields are accepted as configured in SignUpConfig
 type SignUpRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string            `json:"email" valid:"email,required"`
+	Password   string            `json:"password" valid:"required"`
+	Name       string            `json:"name"`
+	Username   string            `json:"username"`
+	Locale     string            `json:"locale"`
+	Timezone   string            `json:"timezone"`
+	Attributes map[string]string `json:"attributes"`
+	// TermsVersion is the version of the terms of service the user accepted
+	TermsVersion string `json:"terms_version"`
 }
 
-func (r SignUpRequest) Validate() error {
-	_, err := govalidator.ValidateStruct(r)
-	return rest.ValidateResponse(err)
+func (r SignUpRequest) Validate(ctx context.Context) error {
+	return validate(ctx, r)
 }
 
 // LogIn Request
@@ -22,10 +32,100 @@ type SignInRequest struct {
 	Password string
 }
 
-func (r SignInRequest) Validate() error {
-	_, err := govalidator.ValidateStruct(r)
-	return rest.ValidateResponse(err)
+func (r SignInRequest) Validate(ctx context.Context) error {
+	return validate(ctx, r)
 }
 
 // LogOut Request
 type SignOutRequest struct{}
+
+// DeleteAccount Request, the password is asked again before the deletion is scheduled
+type DeleteAccountRequest struct {
+	Password string `json:"password" valid:"required"`
+}
+
+func (r DeleteAccountRequest) Validate(ctx context.Context) error {
+	return validate(ctx, r)
+}
+
+// Export Request, UserId is empty when the caller exports own account
+type ExportRequest struct {
+	UserId uint64
+	Zip    bool
+}
+
+// ChangePassword Request
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" valid:"required"`
+	NewPassword string `json:"new_password" valid:"required"`
+}
+
+func (r ChangePasswordRequest) Validate(ctx context.Context) error {
+	return validate(ctx, r)
+}
+
+// ForgotPassword Request asks to mail the password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" valid:"email,required"`
+}
+
+func (r ForgotPasswordRequest) Validate(ctx context.Context) error {
+	return validate(ctx, r)
+}
+
+// ResetPassword Request sets the password using the token from the reset link
+type ResetPasswordRequest struct {
+	Token    string `json:"token" valid:"required"`
+	Password string `json:"password" valid:"required"`
+}
+
+func (r ResetPasswordRequest) Validate(ctx context.Context) error {
+	return validate(ctx, r)
+}
+
+// SuspendUser Request suspends or reinstates the user, it is allowed to admins only
+type SuspendUserRequest struct {
+	UserId  uint64
+	Suspend bool
+}
+
+func (r SuspendUserRequest) Validate(ctx context.Context) error {
+	return nil
+}
+
+// ExportUsers Request selects the users and fields of the bulk export, it is allowed to admins only
+type ExportUsersRequest struct {
+	Format string
+	Fields []string
+	Type   string
+}
+
+func (r ExportUsersRequest) Validate(ctx context.Context) error {
+	errField := rest.ErrFieldResp{
+		Meta: rest.ErrFieldRespMeta{
+			ErrCode: 400,
+		},
+	}
+	if r.Format != UsersExportCSV && r.Format != UsersExportJSONL {
+		errField.AddError("format", 400, i18n.Text(ctx, msgExportFormat))
+	}
+	if err := UsersExportFieldsValid(r.Fields); err != nil {
+		errField.AddError("fields", 400, i18n.Text(ctx, msgExportFields, strings.Join(UsersExportFields, ", ")))
+	}
+	if errField.HasErrors() {
+		return errField
+	}
+	return nil
+}
+
+// MailPreview Request renders the email template, Locale overrides the Accept-Language header
+type MailPreviewRequest struct {
+	Name   string
+	Locale string
+}
+
+// RefreshToken Request replaces the token the request is authenticated with
+type RefreshTokenRequest struct{}
+
+// VerifyToken Request checks the token the request is authenticated with
+type VerifyTokenRequest struct{}
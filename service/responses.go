@@ -1,6 +1,11 @@
 package service
 
-import "github.com/nori-io/auth/service/database"
+import (
+	"io"
+	"time"
+
+	"github.com/nori-io/auth/service/database"
+)
 
 //import "github.com/nori-io/noricms/service/database"
 
@@ -8,6 +13,7 @@ import "github.com/nori-io/auth/service/database"
 type SignUpResponse struct {
 	Id             uint64
 	Name           string
+	Username       string
 	Email          string
 	HttpStatusCode int
 	Err            error
@@ -23,12 +29,19 @@ func (d *SignUpResponse) StatusCode() int {
 
 // LogInResponse
 type SignInResponse struct {
-	Id             uint64
-	Token          string
-	User           database.AuthModel
-	MFA            string
-	HttpStatusCode int
-	Err            error
+	Id    uint64
+	Token string
+	User  database.AuthModel
+	MFA   string
+	// DeletionCancelled is set when sign in cancelled the scheduled account deletion
+	DeletionCancelled bool
+	// PasswordBreached is set when the recheck found the password in breaches
+	PasswordBreached bool
+	// PasswordChangeRequired is set when the password expired, Token is then
+	// accepted only by the change password endpoint
+	PasswordChangeRequired bool
+	HttpStatusCode         int
+	Err                    error
 }
 
 func (d *SignInResponse) Error() error {
@@ -52,3 +65,230 @@ func (d *SignOutResponse) Error() error {
 func (d *SignOutResponse) StatusCode() int {
 	return d.HttpStatusCode
 }
+
+// DeleteAccount Response
+type DeleteAccountResponse struct {
+	DeletionScheduled time.Time
+	HttpStatusCode    int
+	Err               error
+}
+
+func (d *DeleteAccountResponse) Error() error {
+	return d.Err
+}
+
+func (d *DeleteAccountResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// Export Response
+type ExportResponse struct {
+	Data           *UserExport
+	Zip            bool
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *ExportResponse) Error() error {
+	return d.Err
+}
+
+func (d *ExportResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// UserExport is the subject access archive of a single user
+type UserExport struct {
+	Exported  time.Time        `json:"exported"`
+	User      ExportUser       `json:"user"`
+	Auth      *ExportAuth      `json:"auth,omitempty"`
+	Profile   *ExportProfile   `json:"profile,omitempty"`
+	Providers []ExportProvider `json:"auth_providers"`
+	Mfa       ExportMfa        `json:"mfa"`
+	History   []ExportHistory  `json:"authentication_history"`
+	Sessions  []ExportHistory  `json:"sessions"`
+	Consents  []ExportConsent  `json:"consents"`
+}
+
+type ExportUser struct {
+	Id       uint64     `json:"id"`
+	Kind     string     `json:"kind"`
+	StatusId int64      `json:"status_id"`
+	Type     string     `json:"type"`
+	Created  time.Time  `json:"created"`
+	Updated  time.Time  `json:"updated"`
+	Deleted  *time.Time `json:"deleted,omitempty"`
+
+	DeletionScheduled *time.Time `json:"deletion_scheduled,omitempty"`
+}
+
+type ExportAuth struct {
+	Email           string    `json:"email,omitempty"`
+	Phone           string    `json:"phone,omitempty"`
+	IsEmailVerified bool      `json:"is_email_verified"`
+	IsPhoneVerified bool      `json:"is_phone_verified"`
+	Created         time.Time `json:"created"`
+	Updated         time.Time `json:"updated"`
+}
+
+type ExportProfile struct {
+	DisplayName string            `json:"display_name,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Locale      string            `json:"locale,omitempty"`
+	Timezone    string            `json:"timezone,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Created     time.Time         `json:"created"`
+	Updated     time.Time         `json:"updated"`
+}
+
+type ExportProvider struct {
+	Provider        string `json:"provider"`
+	ProviderUserKey string `json:"provider_user_key"`
+}
+
+type ExportMfa struct {
+	Type          string `json:"type"`
+	Authenticator bool   `json:"authenticator"`
+	Phone         string `json:"phone,omitempty"`
+	RecoveryCodes int    `json:"recovery_codes"`
+}
+
+type ExportHistory struct {
+	Id        int64      `json:"id"`
+	LoggedIn  time.Time  `json:"logged_in"`
+	LoggedOut *time.Time `json:"logged_out,omitempty"`
+	Meta      string     `json:"meta,omitempty"`
+}
+
+type ExportConsent struct {
+	Kind    string     `json:"kind"`
+	Version string     `json:"version"`
+	Granted time.Time  `json:"granted"`
+	Revoked *time.Time `json:"revoked,omitempty"`
+}
+
+// ChangePassword Response
+type ChangePasswordResponse struct {
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *ChangePasswordResponse) Error() error {
+	return d.Err
+}
+
+func (d *ChangePasswordResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// ForgotPassword Response is the same for known and unknown emails
+type ForgotPasswordResponse struct {
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *ForgotPasswordResponse) Error() error {
+	return d.Err
+}
+
+func (d *ForgotPasswordResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// ResetPassword Response
+type ResetPasswordResponse struct {
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *ResetPasswordResponse) Error() error {
+	return d.Err
+}
+
+func (d *ResetPasswordResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// SuspendUser Response
+type SuspendUserResponse struct {
+	Suspended      bool
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *SuspendUserResponse) Error() error {
+	return d.Err
+}
+
+func (d *SuspendUserResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// ExportUsers Response, Write streams the users to the body
+type ExportUsersResponse struct {
+	Format         string
+	Write          func(w io.Writer) error
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *ExportUsersResponse) Error() error {
+	return d.Err
+}
+
+func (d *ExportUsersResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// MailPreview Response
+type MailPreviewResponse struct {
+	Subject        string
+	Text           string
+	HTML           string
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *MailPreviewResponse) Error() error {
+	return d.Err
+}
+
+func (d *MailPreviewResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// RefreshToken Response
+type RefreshTokenResponse struct {
+	Token string
+	// PasswordChangeRequired is set when the password expired since the sign in,
+	// Token is then accepted only by the change password endpoint
+	PasswordChangeRequired bool
+	HttpStatusCode         int
+	Err                    error
+}
+
+func (d *RefreshTokenResponse) Error() error {
+	return d.Err
+}
+
+func (d *RefreshTokenResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
+
+// VerifyToken Response describes the user of the token
+type VerifyTokenResponse struct {
+	UserId         uint64
+	Email          string
+	Type           string
+	Expires        time.Time
+	HttpStatusCode int
+	Err            error
+}
+
+func (d *VerifyTokenResponse) Error() error {
+	return d.Err
+}
+
+func (d *VerifyTokenResponse) StatusCode() int {
+	return d.HttpStatusCode
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cheebo/gorest"
+	"github.com/nori-io/nori-common/endpoint"
+	"github.com/nori-io/nori-common/interfaces"
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/issuer"
+)
+
+const defaultTokenTTL = time.Hour
+
+// NotRevoked rejects requests which token id is in the denylist, it must run after auth.Authenticated
+func NotRevoked(revoked database.RevokedTokens, session interfaces.Session, log *logrus.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			sid := session.SessionId(ctx)
+			if len(sid) == 0 {
+				return nil, httpError(401, i18n.Text(ctx, msgUnauthorized))
+			}
+			denied, err := revoked.IsRevoked(ctx, string(sid))
+			if err != nil {
+				log.Error(err)
+				return nil, internalError(ctx)
+			}
+			if denied {
+				return nil, httpError(401, i18n.Text(ctx, msgUnauthorized))
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+func (s *service) SuspendUser(ctx context.Context, req SuspendUserRequest) (resp *SuspendUserResponse) {
+	resp = &SuspendUserResponse{}
+
+	caller, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if caller.Type != database.UserTypeAdmin {
+		resp.Err = httpError(403, i18n.Text(ctx, msgForbidden))
+		return resp
+	}
+	if req.UserId == caller.Id {
+		resp.Err = httpError(400, i18n.Text(ctx, msgSuspendSelf))
+		return resp
+	}
+
+	user, err := s.db.Users().FindByID(ctx, req.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		resp.Err = rest.ErrorNotFound(i18n.Text(ctx, msgUserNotFound))
+		return resp
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	user.StatusId = database.UserStatusActive
+	if req.Suspend {
+		user.StatusId = database.UserStatusSuspended
+	}
+	user.Updated = time.Now()
+	if err := s.db.Users().Update(ctx, user); err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	if req.Suspend {
+		if err := s.revokeSessions(ctx, user.Id, ""); err != nil {
+			s.log.Error(err)
+			resp.Err = internalError(ctx)
+			return resp
+		}
+	}
+	resp.Suspended = req.Suspend
+	return resp
+}
+
+// revokeSession denies the token of the session and closes the session
+func (s *service) revokeSession(ctx context.Context, sid string, expires time.Time) error {
+	s.session.Delete([]byte(sid))
+	if !expires.After(time.Now()) {
+		return nil
+	}
+	return s.revoked.Revoke(ctx, sid, expires)
+}
+
+// revokeSessions revokes all open sessions but keep and all refresh tokens of the user
+func (s *service) revokeSessions(ctx context.Context, userId uint64, keep string) error {
+	if err := s.db.RefreshTokens().RevokeByUser(ctx, userId); err != nil {
+		return err
+	}
+	history, err := s.db.AuthenticationHistory().FindByUserID(ctx, userId)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, h := range history {
+		if h.Secret == "" || h.Secret == keep || !h.LoggedOut.IsZero() {
+			continue
+		}
+		if err := s.revokeSession(ctx, h.Secret, h.LoggedIn.Add(s.tokenTTL())); err != nil {
+			return err
+		}
+		h.LoggedOut = now
+		if err := s.db.AuthenticationHistory().Update(ctx, &h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenExpiry is the expiry of the request token, the registry Auth tokens
+// are assumed to live for the configured token lifetime
+func (s *service) tokenExpiry(ctx context.Context, issued time.Time) time.Time {
+	if claims, ok := issuer.ClaimsFromContext(ctx); ok && claims.Exp != 0 {
+		return time.Unix(claims.Exp, 0)
+	}
+	return issued.Add(s.tokenTTL())
+}
+
+func (s *service) tokenTTL() time.Duration {
+	return duration(s.cfg.TokenTTL, defaultTokenTTL)
+}
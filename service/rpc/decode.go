@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/rpc/authpb"
+)
+
+func DecodeSignUpRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*authpb.SignUpRequest)
+	body := service.SignUpRequest{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if err := body.Validate(ctx); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return body, nil
+}
+
+func DecodeSignInRequest(ctx context.Context, r interface{}) (interface{}, error) {
+	req := r.(*authpb.SignInRequest)
+	body := service.SignInRequest{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if err := body.Validate(ctx); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return body, nil
+}
+
+func DecodeSignOutRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return service.SignOutRequest{}, nil
+}
+
+func DecodeRefreshTokenRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return service.RefreshTokenRequest{}, nil
+}
+
+func DecodeVerifyTokenRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return service.VerifyTokenRequest{}, nil
+}
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/rpc/authpb"
+)
+
+func EncodeSignUpResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(service.SignUpResponse)
+	return &authpb.SignUpResponse{
+		Id:    resp.Id,
+		Email: resp.Email,
+	}, nil
+}
+
+func EncodeSignInResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(service.SignInResponse)
+	return &authpb.SignInResponse{
+		Id:                     resp.Id,
+		Token:                  resp.Token,
+		Mfa:                    resp.MFA,
+		DeletionCancelled:      resp.DeletionCancelled,
+		PasswordBreached:       resp.PasswordBreached,
+		PasswordChangeRequired: resp.PasswordChangeRequired,
+	}, nil
+}
+
+func EncodeSignOutResponse(_ context.Context, _ interface{}) (interface{}, error) {
+	return &authpb.SignOutResponse{}, nil
+}
+
+func EncodeRefreshTokenResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(service.RefreshTokenResponse)
+	return &authpb.RefreshTokenResponse{Token: resp.Token}, nil
+}
+
+func EncodeVerifyTokenResponse(_ context.Context, r interface{}) (interface{}, error) {
+	resp := r.(service.VerifyTokenResponse)
+	return &authpb.VerifyTokenResponse{
+		UserId:  resp.UserId,
+		Email:   resp.Email,
+		Type:    resp.Type,
+		Expires: timestamppb.New(resp.Expires),
+	}, nil
+}
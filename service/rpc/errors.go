@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nori-io/auth/service/issuer"
+)
+
+// codesByStatus maps HTTP status codes of the service errors to gRPC codes
+var codesByStatus = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// statusError converts the service error to the gRPC status error
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, issuer.ErrUnauthorized) {
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	httpCode := httpStatus(err)
+	code, ok := codesByStatus[httpCode]
+	if !ok {
+		code = codes.Unknown
+	}
+	message := err.Error()
+	if message == "" {
+		message = http.StatusText(httpCode)
+	}
+	return status.Error(code, message)
+}
+
+// unauthenticated converts errors of the authentication middlewares, the registry
+// plugins return own errors which all mean the token is not accepted
+func unauthenticated(err error) error {
+	err = statusError(err)
+	if status.Code(err) == codes.Unknown {
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return err
+}
+
+// httpStatus returns the status code gorest errors keep in Meta.ErrCode, 0 for other errors
+func httpStatus(err error) int {
+	v := reflect.Indirect(reflect.ValueOf(err))
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	meta := reflect.Indirect(v.FieldByName("Meta"))
+	if meta.Kind() != reflect.Struct {
+		return 0
+	}
+	code := meta.FieldByName("ErrCode")
+	switch code.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(code.Int())
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package rpc
+
+//go:generate protoc --go_out=authpb --go_opt=paths=source_relative --go-grpc_out=authpb --go-grpc_opt=paths=source_relative -I authpb auth.proto
+
+import (
+	"context"
+
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+
+	"github.com/nori-io/auth/service/rpc/authpb"
+)
+
+// server implements authpb.AuthServer with the go-kit handlers of the endpoints
+type server struct {
+	authpb.UnimplementedAuthServer
+
+	signUp       kitgrpc.Handler
+	signIn       kitgrpc.Handler
+	signOut      kitgrpc.Handler
+	refreshToken kitgrpc.Handler
+	verifyToken  kitgrpc.Handler
+}
+
+func (s *server) SignUp(ctx context.Context, req *authpb.SignUpRequest) (*authpb.SignUpResponse, error) {
+	_, resp, err := s.signUp.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*authpb.SignUpResponse), nil
+}
+
+func (s *server) SignIn(ctx context.Context, req *authpb.SignInRequest) (*authpb.SignInResponse, error) {
+	_, resp, err := s.signIn.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*authpb.SignInResponse), nil
+}
+
+func (s *server) SignOut(ctx context.Context, req *authpb.SignOutRequest) (*authpb.SignOutResponse, error) {
+	_, resp, err := s.signOut.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*authpb.SignOutResponse), nil
+}
+
+func (s *server) RefreshToken(ctx context.Context, req *authpb.RefreshTokenRequest) (*authpb.RefreshTokenResponse, error) {
+	_, resp, err := s.refreshToken.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*authpb.RefreshTokenResponse), nil
+}
+
+func (s *server) VerifyToken(ctx context.Context, req *authpb.VerifyTokenRequest) (*authpb.VerifyTokenResponse, error) {
+	_, resp, err := s.verifyToken.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*authpb.VerifyTokenResponse), nil
+}
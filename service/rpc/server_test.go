@@ -0,0 +1,218 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/cheebo/gorest"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/breach"
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/fakes"
+	"github.com/nori-io/auth/service/issuer"
+	"github.com/nori-io/auth/service/rpc/authpb"
+)
+
+const testPassword = "Xy7!kq2Lmn#p"
+
+// newTestClient serves Transport with the fakes over the in-memory database on bufconn
+func newTestClient(t *testing.T) authpb.AuthClient {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	db := database.NewMemory()
+	auth := fakes.NewAuth()
+	session := fakes.NewSession()
+	cfg := &service.Config{
+		Sub: func() string { return "user" },
+		Iss: func() string { return "auth" },
+	}
+	srv := service.NewService(auth, session, cfg, logger, db, nil, breach.NewChecker(breach.Config{}), db.RevokedTokens())
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	Transport(auth, fakes.NewTransport(), session, db.RevokedTokens(), server, srv, nil, logger)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return authpb.NewAuthClient(conn)
+}
+
+// withToken returns the context sending the token in the authorization metadata
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func signIn(t *testing.T, client authpb.AuthClient, email string) *authpb.SignInResponse {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := client.SignUp(ctx, &authpb.SignUpRequest{Email: email, Password: testPassword}); err != nil {
+		t.Fatalf("sign up: %v", err)
+	}
+	resp, err := client.SignIn(ctx, &authpb.SignInRequest{Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	return resp
+}
+
+func TestSignUp(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	resp, err := client.SignUp(ctx, &authpb.SignUpRequest{Email: "user@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetId() == 0 || resp.GetEmail() != "user@example.com" {
+		t.Errorf("response = %v", resp)
+	}
+
+	for name, req := range map[string]*authpb.SignUpRequest{
+		"duplicate email": {Email: "user@example.com", Password: testPassword},
+		"invalid email":   {Email: "user", Password: testPassword},
+		"weak password":   {Email: "other@example.com", Password: "12345678"},
+	} {
+		if _, err := client.SignUp(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: err = %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	client := newTestClient(t)
+	resp := signIn(t, client, "user@example.com")
+	if resp.GetId() == 0 || resp.GetToken() == "" || resp.GetPasswordChangeRequired() {
+		t.Errorf("response = %v", resp)
+	}
+
+	_, err := client.SignIn(context.Background(), &authpb.SignInRequest{Email: "user@example.com", Password: testPassword + "1"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("wrong password: err = %v, want NotFound", err)
+	}
+
+	// the messages are in the languages of the accept-language metadata
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "accept-language", "ru")
+	_, err = client.SignIn(ctx, &authpb.SignInRequest{Email: "nobody@example.com", Password: testPassword})
+	if s, _ := status.FromError(err); s.Code() != codes.NotFound || s.Message() != "Пользователь не найден" {
+		t.Errorf("unknown email: err = %v", err)
+	}
+}
+
+func TestMetadataAuthentication(t *testing.T) {
+	client := newTestClient(t)
+	token := signIn(t, client, "user@example.com").GetToken()
+
+	for name, ctx := range map[string]context.Context{
+		"no metadata":   context.Background(),
+		"unknown token": withToken("unknown"),
+		"no bearer":     metadata.AppendToOutgoingContext(context.Background(), "authorization", token),
+	} {
+		if _, err := client.VerifyToken(ctx, &authpb.VerifyTokenRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: err = %v, want Unauthenticated", name, err)
+		}
+	}
+	if _, err := client.VerifyToken(withToken(token), &authpb.VerifyTokenRequest{}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	client := newTestClient(t)
+	user := signIn(t, client, "user@example.com")
+
+	resp, err := client.RefreshToken(withToken(user.GetToken()), &authpb.RefreshTokenRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetToken() == "" || resp.GetToken() == user.GetToken() {
+		t.Errorf("response = %v", resp)
+	}
+	if _, err := client.VerifyToken(withToken(user.GetToken()), &authpb.VerifyTokenRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("refreshed token: err = %v, want Unauthenticated", err)
+	}
+	if _, err := client.RefreshToken(withToken(user.GetToken()), &authpb.RefreshTokenRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("refreshed token is refreshed again: err = %v", err)
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	client := newTestClient(t)
+	user := signIn(t, client, "user@example.com")
+
+	resp, err := client.VerifyToken(withToken(user.GetToken()), &authpb.VerifyTokenRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetUserId() != user.GetId() || resp.GetEmail() != "user@example.com" || resp.GetExpires() == nil {
+		t.Errorf("response = %v", resp)
+	}
+
+	if _, err := client.SignOut(withToken(user.GetToken()), &authpb.SignOutRequest{}); err != nil {
+		t.Fatalf("sign out: %v", err)
+	}
+	if _, err := client.VerifyToken(withToken(user.GetToken()), &authpb.VerifyTokenRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("token of the closed session: err = %v, want Unauthenticated", err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	withCode := func(code int) error {
+		return rest.ErrFieldResp{Meta: rest.ErrFieldRespMeta{ErrCode: code, ErrMessage: "message"}}
+	}
+	for _, tt := range []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"nil", nil, codes.OK},
+		{"bad request", withCode(400), codes.InvalidArgument},
+		{"unauthorized", withCode(401), codes.Unauthenticated},
+		{"forbidden", withCode(403), codes.PermissionDenied},
+		{"not found", rest.ErrorNotFound("message"), codes.NotFound},
+		{"conflict", withCode(409), codes.AlreadyExists},
+		{"precondition failed", withCode(412), codes.FailedPrecondition},
+		{"too many requests", withCode(429), codes.ResourceExhausted},
+		{"internal", rest.ErrorInternal("message"), codes.Internal},
+		{"not implemented", withCode(501), codes.Unimplemented},
+		{"unavailable", withCode(503), codes.Unavailable},
+		{"unmapped status", withCode(418), codes.Unknown},
+		{"plain error", errors.New("message"), codes.Unknown},
+		{"issuer", issuer.ErrUnauthorized, codes.Unauthenticated},
+		{"status", status.Error(codes.Aborted, "message"), codes.Aborted},
+	} {
+		if got := status.Code(statusError(tt.err)); got != tt.code {
+			t.Errorf("%s: code = %v, want %v", tt.name, got, tt.code)
+		}
+	}
+
+	// the registry auth plugins return own errors
+	if got := status.Code(unauthenticated(errors.New("token expired"))); got != codes.Unauthenticated {
+		t.Errorf("unauthenticated: code = %v, want Unauthenticated", got)
+	}
+	if got := status.Code(unauthenticated(withCode(403))); got != codes.PermissionDenied {
+		t.Errorf("unauthenticated forbidden: code = %v, want PermissionDenied", got)
+	}
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+	"github.com/nori-io/nori-common/endpoint"
+	"github.com/nori-io/nori-common/interfaces"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/nori-io/auth/service"
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/locale"
+	"github.com/nori-io/auth/service/rpc/authpb"
+)
+
+// Transport registers the gRPC service of the auth endpoints. The calls are authenticated
+// the same way as by service.Transport, the token is taken from the authorization metadata.
+// registrar is the grpc.Server, or any other registrar the service is served with
+func Transport(
+	auth interfaces.Auth,
+	transport interfaces.HTTPTransport,
+	session interfaces.Session,
+	revoked database.RevokedTokens,
+	registrar grpc.ServiceRegistrar,
+	srv service.Service,
+	messages *i18n.Catalog,
+	logger *logrus.Logger,
+) {
+
+	notRevoked := service.NotRevoked(revoked, session, logger)
+	authenticated := func(e endpoint.Endpoint) endpoint.Endpoint {
+		guarded := auth.Authenticated()(notRevoked(session.Verify()(statusErrors(e))))
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := guarded(ctx, request)
+			if err != nil {
+				return nil, unauthenticated(err)
+			}
+			return response, nil
+		}
+	}
+
+	localized := kitgrpc.ServerBefore(languages(messages))
+	opts := []kitgrpc.ServerOption{
+		localized,
+		kitgrpc.ServerBefore(fromMetadata(transport)),
+	}
+
+	authpb.RegisterAuthServer(registrar, &server{
+		signUp: kitgrpc.NewServer(
+			kitendpoint.Endpoint(statusErrors(service.MakeSignUpEndpoint(srv))),
+			DecodeSignUpRequest,
+			EncodeSignUpResponse,
+			localized,
+		),
+		signIn: kitgrpc.NewServer(
+			kitendpoint.Endpoint(statusErrors(service.MakeSignInEndpoint(srv))),
+			DecodeSignInRequest,
+			EncodeSignInResponse,
+			localized,
+		),
+		signOut: kitgrpc.NewServer(
+			kitendpoint.Endpoint(authenticated(service.MakeSignOutEndpoint(srv))),
+			DecodeSignOutRequest,
+			EncodeSignOutResponse,
+			opts...,
+		),
+		refreshToken: kitgrpc.NewServer(
+			kitendpoint.Endpoint(authenticated(service.MakeRefreshTokenEndpoint(srv))),
+			DecodeRefreshTokenRequest,
+			EncodeRefreshTokenResponse,
+			opts...,
+		),
+		verifyToken: kitgrpc.NewServer(
+			kitendpoint.Endpoint(authenticated(service.MakeVerifyTokenEndpoint(srv))),
+			DecodeVerifyTokenRequest,
+			EncodeVerifyTokenResponse,
+			opts...,
+		),
+	})
+}
+
+// statusErrors converts errors of the endpoint to gRPC status errors
+func statusErrors(e endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := e(ctx, request)
+		if err != nil {
+			return nil, statusError(err)
+		}
+		return response, nil
+	}
+}
+
+// languages keeps messages and the languages of the accept-language metadata for the error messages
+func languages(messages *i18n.Catalog) kitgrpc.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		for _, value := range md.Get("accept-language") {
+			ctx = locale.WithLocale(ctx, locale.Parse(value)...)
+		}
+		return i18n.WithCatalog(ctx, messages)
+	}
+}
+
+// fromMetadata hands the authorization metadata to the HTTP transport as the request header,
+// so the token is read by the same auth plugin as for HTTP calls
+func fromMetadata(transport interfaces.HTTPTransport) kitgrpc.ServerRequestFunc {
+	toContext := transport.ToContext()
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+		if err != nil {
+			return ctx
+		}
+		for _, value := range md.Get("authorization") {
+			r.Header.Add("Authorization", value)
+		}
+		return toContext(ctx, r)
+	}
+}
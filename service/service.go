@@ -2,12 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/cheebo/gorest"
 	"github.com/cheebo/rand"
 	"github.com/nori-io/nori-common/interfaces"
 
+	"github.com/nori-io/auth/service/breach"
 	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/password"
+	"github.com/nori-io/auth/service/templates"
 	//"github.com/cheebo/gorest"
 	//	"github.com/cheebo/rand"
 	"github.com/sirupsen/logrus"
@@ -17,19 +24,77 @@ type Service interface {
 	SignUp(ctx context.Context, req SignUpRequest) (resp *SignUpResponse)
 	SignIn(ctx context.Context, req SignInRequest) (resp *SignInResponse)
 	SignOut(ctx context.Context, req SignOutRequest) (resp *SignOutResponse)
+	Export(ctx context.Context, req ExportRequest) (resp *ExportResponse)
+	DeleteAccount(ctx context.Context, req DeleteAccountRequest) (resp *DeleteAccountResponse)
+	ChangePassword(ctx context.Context, req ChangePasswordRequest) (resp *ChangePasswordResponse)
+	ForgotPassword(ctx context.Context, req ForgotPasswordRequest) (resp *ForgotPasswordResponse)
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) (resp *ResetPasswordResponse)
+	SuspendUser(ctx context.Context, req SuspendUserRequest) (resp *SuspendUserResponse)
+	ExportUsers(ctx context.Context, req ExportUsersRequest) (resp *ExportUsersResponse)
+	MailPreview(ctx context.Context, req MailPreviewRequest) (resp *MailPreviewResponse)
+	RefreshToken(ctx context.Context, req RefreshTokenRequest) (resp *RefreshTokenResponse)
+	VerifyToken(ctx context.Context, req VerifyTokenRequest) (resp *VerifyTokenResponse)
 }
 
 type Config struct {
 	Sub func() string
 	Iss func() string
+	// DeletionGracePeriod is the duration between the deletion request and the account purge
+	DeletionGracePeriod func() string
+	// PurgeInterval is how often accounts with expired grace period are purged
+	PurgeInterval func() string
+	// ResetURL is the password reset page, the token is appended to it
+	ResetURL func() string
+	// ResetTTL is how long the password reset link is valid
+	ResetTTL func() string
+	// PasswordHistory is the number of recent passwords which can't be reused, 0 disables the check
+	PasswordHistory func() int
+	// PasswordMaxAge is the password lifetime after which it must be changed, empty disables expiry
+	PasswordMaxAge func() string
+	// PlaintextPasswords accepts the passwords stored as plain text before hashing was introduced,
+	// they are hashed on sign in. It must be disabled once the old accounts signed in
+	PlaintextPasswords func() bool
+	Password           password.Config
+	// TokenTTL is the access token lifetime, revoked token ids are kept in the denylist this long
+	TokenTTL func() string
+	// RefreshMaxAge is how long after the sign in the token can be refreshed, the user must sign in again afterwards
+	RefreshMaxAge func() string
+	// Templates are the templates of the emails
+	Templates templates.Config
+	// SignUp selects the optional fields of the sign up form
+	SignUp SignUpConfig
+}
+
+const (
+	defaultDeletionGracePeriod = 30 * 24 * time.Hour
+	defaultPurgeInterval       = time.Hour
+	defaultResetTTL            = time.Hour
+	defaultRefreshMaxAge       = 30 * 24 * time.Hour
+)
+
+// duration parses config value, def is returned when the value is empty or invalid
+func duration(value func() string, def time.Duration) time.Duration {
+	if value == nil {
+		return def
+	}
+	d, err := time.ParseDuration(value())
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
 }
 
 type service struct {
-	auth    interfaces.Auth
-	db      database.Database
-	session interfaces.Session
-	cfg     *Config
-	log     *logrus.Logger
+	auth      interfaces.Auth
+	db        database.Database
+	session   interfaces.Session
+	mail      Mailer
+	templates *templates.Templates
+	policy    *password.Policy
+	breach    *breach.Checker
+	revoked   database.RevokedTokens
+	cfg       *Config
+	log       *logrus.Logger
 }
 
 func NewService(
@@ -38,58 +103,111 @@ func NewService(
 	cfg *Config,
 	log *logrus.Logger,
 	db database.Database,
+	mail Mailer,
+	breach *breach.Checker,
+	revoked database.RevokedTokens,
 ) Service {
 	return &service{
-		auth:    auth,
-		db:      db,
-		session: session,
-		cfg:     cfg,
-		log:     log,
+		auth:      auth,
+		db:        db,
+		session:   session,
+		mail:      mail,
+		templates: templates.New(cfg.Templates),
+		policy:    password.NewPolicy(cfg.Password),
+		breach:    breach,
+		revoked:   revoked,
+		cfg:       cfg,
+		log:       log,
 	}
 }
 
 func (s *service) SignUp(ctx context.Context, req SignUpRequest) (resp *SignUpResponse) {
 	var err error
-		var model *database.AuthModel
-		resp = &SignUpResponse{}
-		errField := rest.ErrFieldResp{
-			Meta: rest.ErrFieldRespMeta{
-				ErrCode: 400,
-			},
-		}
+	var model *database.AuthModel
+	resp = &SignUpResponse{}
+	errField := rest.ErrFieldResp{
+		Meta: rest.ErrFieldRespMeta{
+			ErrCode: 400,
+		},
+	}
 
-		if model, err = s.db.Auth().FindByEmail(req.Email); err != nil {
-			resp.Err = rest.ErrorInternal(err.Error())
-			return resp
-		}
+	model, err = s.db.Auth().FindByEmail(ctx, req.Email)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
 
-		if model != nil && model.Id_Auth != 0 {
-			errField.AddError("email", 400, "Email already exists.")
-		}
-		if errField.HasErrors() {
-			resp.Err = errField
-			return resp
-		}
+	if model != nil {
+		errField.AddError("email", 400, i18n.Text(ctx, msgEmailExists))
+	}
+	s.validatePassword(ctx, "password", req.Password, req.Email, &errField)
+	profile := s.signUpProfile(ctx, req, &errField)
+	if errField.HasErrors() {
+		resp.Err = errField
+		return resp
+	}
 
-		model = &database.AuthModel{
-			Email_Auth:    req.Email,
-			Password_Auth: req.Password,
+	hash, err := password.Hash(req.Password)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	now := time.Now()
+	model = &database.AuthModel{
+		Email_Auth:           req.Email,
+		Password_Auth:        hash,
+		PasswordChanged_Auth: now,
+		Created_Auth:         now,
+		Updated_Auth:         now,
+		Type_Users:           database.UserTypeUser,
+		Created_Users:        now,
+		Updated_Users:        now,
+	}
 
+	err = s.db.Tx(ctx, func(tx database.Database) error {
+		if err := tx.Auth().Create(ctx, model); err != nil {
+			return err
 		}
-
-		err = s.db.Auth().Create(model)
-		if err != nil {
-			s.log.Error(err)
-			resp.Err = rest.ErrFieldResp{
-				Meta: rest.ErrFieldRespMeta{
-					ErrCode:    500,
-					ErrMessage: err.Error(),
-				},
+		profile.UserId, profile.Created, profile.Updated = model.UserId_Auth, now, now
+		if err := tx.Profiles().Create(ctx, profile); err != nil {
+			return err
+		}
+		// the version is checked against the current one, it is recorded only when the terms are configured
+		if s.cfg.SignUp.terms() != "" {
+			err := tx.Consents().Create(ctx, &database.ConsentModel{
+				UserId:  model.UserId_Auth,
+				Kind:    ConsentTerms,
+				Version: req.TermsVersion,
+				Granted: now,
+			})
+			if err != nil {
+				return err
 			}
-			return resp
 		}
+		return s.recordPassword(ctx, tx, model.UserId_Auth, hash)
+	})
+	if errors.Is(err, database.ErrDuplicateEmail) {
+		errField.AddError("email", 400, i18n.Text(ctx, msgEmailExists))
+		resp.Err = errField
+		return resp
+	}
+	if errors.Is(err, database.ErrDuplicateUsername) {
+		errField.AddError(SignUpUsername, 400, i18n.Text(ctx, msgUsernameTaken))
+		resp.Err = errField
+		return resp
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
 
-		resp.Email = req.Email
+	resp.Id = model.UserId_Auth
+	resp.Name = profile.DisplayName
+	resp.Username = profile.Username
+	resp.Email = req.Email
 
 	return resp
 }
@@ -97,21 +215,70 @@ func (s *service) SignUp(ctx context.Context, req SignUpRequest) (resp *SignUpRe
 func (s *service) SignIn(ctx context.Context, req SignInRequest) (resp *SignInResponse) {
 	resp = &SignInResponse{}
 
-	model, err := s.db.Auth().FindByEmail(req.Email)
+	model, err := s.db.Auth().FindByEmail(ctx, req.Email)
+	if errors.Is(err, database.ErrNotFound) {
+		resp.Err = rest.ErrorNotFound(i18n.Text(ctx, msgUserNotFound))
+		return resp
+	}
 	if err != nil {
-		resp.Err = rest.ErrorInternal("Internal error")
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
 		return resp
 	}
-	if model == nil {
-		resp.Err = rest.ErrorNotFound("User not found")
+
+	if !s.checkPassword(ctx, model, req.Password) {
+		resp.Err = rest.ErrorNotFound(i18n.Text(ctx, msgUserNotFound))
 		return resp
 	}
+	if model.StatusId_Users == database.UserStatusSuspended {
+		resp.Err = httpError(403, i18n.Text(ctx, msgAccountSuspended))
+		return resp
+	}
+
+	// an expired password gives a session usable only to change it
+	state := interfaces.SessionActive
+	if s.passwordExpired(model) {
+		state = interfaces.SessionLocked
+		resp.PasswordChangeRequired = true
+	}
 
-	if req.Password != model.Password_Auth {
-		resp.Err = rest.ErrorNotFound("User not found")
+	if s.breach.RecheckOnSignIn() {
+		breached, err := s.breach.Breached(req.Password)
+		if err != nil {
+			s.log.Warn(err)
+		}
+		resp.PasswordBreached = breached
+	}
+
+	if model.DeletionScheduled_Users != nil {
+		if err := s.db.Users().CancelDeletion(ctx, model.UserId_Auth); err != nil {
+			s.log.Error(err)
+			resp.Err = internalError(ctx)
+			return resp
+		}
+		model.DeletionScheduled_Users = nil
+		resp.DeletionCancelled = true
+	}
+
+	token, err := s.startSession(ctx, model, state, time.Time{})
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
 		return resp
 	}
 
+	resp.Id = model.UserId_Auth
+	resp.Token = token
+	resp.User = *model
+	resp.User.Password_Auth = ""
+	resp.User.Salt_Auth = ""
+
+	return resp
+}
+
+// startSession issues the token of the new session of the user in the state,
+// signedIn is the sign in time of the refreshed session, zero for a new sign in
+func (s *service) startSession(ctx context.Context, model *database.AuthModel, state interfaces.SessionState, signedIn time.Time) (string, error) {
 	sid := rand.RandomAlphaNum(32)
 
 	token, err := s.auth.AccessToken(func(op interface{}) interface{} {
@@ -121,10 +288,14 @@ func (s *service) SignIn(ctx context.Context, req SignInRequest) (resp *SignInRe
 		}
 		switch key {
 		case "raw":
-			return map[string]string{
-				"id":    string(model.Id_Auth),
+			raw := map[string]string{
+				"id":    strconv.FormatUint(model.UserId_Auth, 10),
 				"email": model.Email_Auth,
 			}
+			if state != interfaces.SessionActive {
+				raw["scope"] = scopePasswordChange
+			}
+			return raw
 		case "jti":
 			return sid
 		case "sub":
@@ -135,23 +306,273 @@ func (s *service) SignIn(ctx context.Context, req SignInRequest) (resp *SignInRe
 			return ""
 		}
 	})
+	if err != nil {
+		return "", err
+	}
+
+	s.session.Save([]byte(sid), state, 0)
+
+	now := time.Now()
+	if signedIn.IsZero() {
+		signedIn = now
+	}
+	err = s.db.AuthenticationHistory().Create(ctx, &database.AuthenticationHistoryModel{
+		UserId:   model.UserId_Auth,
+		LoggedIn: now,
+		Secret:   sid,
+		SignedIn: signedIn,
+	})
+	if err != nil {
+		s.log.Error(err)
+	}
+	return token, nil
+}
+
+func (s *service) SignOut(ctx context.Context, req SignOutRequest) (resp *SignOutResponse) {
+	resp = &SignOutResponse{}
+	sid := s.session.SessionId(ctx)
 
+	history, err := s.db.AuthenticationHistory().FindBySecret(ctx, string(sid))
 	if err != nil {
-		resp.Err = rest.ErrorInternal(err.Error())
+		s.session.Delete(sid)
+		s.log.Error(err)
 		return resp
 	}
+	if err := s.revokeSession(ctx, string(sid), s.tokenExpiry(ctx, history.LoggedIn)); err != nil {
+		s.log.Error(err)
+	}
+	history.LoggedOut = time.Now()
+	if err := s.db.AuthenticationHistory().Update(ctx, history); err != nil {
+		s.log.Error(err)
+	}
+	return resp
+}
 
-	s.session.Save([]byte(sid), interfaces.SessionActive, 0)
+func (s *service) DeleteAccount(ctx context.Context, req DeleteAccountRequest) (resp *DeleteAccountResponse) {
+	resp = &DeleteAccountResponse{}
 
-	resp.Id = uint64(model.Id_Auth)
-	resp.Token = token
-	resp.User = *model
+	caller, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
 
+	model, err := s.db.Auth().FindByUserID(ctx, caller.Id)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	if !s.checkPassword(ctx, model, req.Password) {
+		errField := rest.ErrFieldResp{
+			Meta: rest.ErrFieldRespMeta{
+				ErrCode: 400,
+			},
+		}
+		errField.AddError("password", 400, i18n.Text(ctx, msgPasswordIncorrect))
+		resp.Err = errField
+		return resp
+	}
+
+	at := time.Now().Add(duration(s.cfg.DeletionGracePeriod, defaultDeletionGracePeriod))
+	if err := s.db.Users().ScheduleDeletion(ctx, caller.Id, at); err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	s.SignOut(ctx, SignOutRequest{})
+
+	resp.DeletionScheduled = at
 	return resp
 }
 
-func (s *service) SignOut(ctx context.Context, req SignOutRequest) (resp *SignOutResponse) {
-	resp = &SignOutResponse{}
-	s.session.Delete(s.session.SessionId(ctx))
+func (s *service) Export(ctx context.Context, req ExportRequest) (resp *ExportResponse) {
+	resp = &ExportResponse{Zip: req.Zip}
+
+	caller, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if req.UserId == 0 {
+		req.UserId = caller.Id
+	}
+	if req.UserId != caller.Id && caller.Type != database.UserTypeAdmin {
+		resp.Err = httpError(403, i18n.Text(ctx, msgForbidden))
+		return resp
+	}
+
+	user, err := s.db.Users().FindByID(ctx, req.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		resp.Err = rest.ErrorNotFound(i18n.Text(ctx, msgUserNotFound))
+		return resp
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	export, err := s.export(ctx, user)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	resp.Data = export
 	return resp
 }
+
+// export collects everything the plugin stores about the user, secrets are left out
+func (s *service) export(ctx context.Context, user *database.UsersModel) (*UserExport, error) {
+	export := &UserExport{
+		Exported: time.Now(),
+		User: ExportUser{
+			Id:       user.Id,
+			Kind:     user.Kind,
+			StatusId: user.StatusId,
+			Type:     user.Type,
+			Created:  user.Created,
+			Updated:  user.Updated,
+			Deleted:  user.Deleted,
+
+			DeletionScheduled: user.DeletionScheduled,
+		},
+	}
+
+	auth, err := s.db.Auth().FindByUserID(ctx, user.Id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+	if auth != nil {
+		export.Auth = &ExportAuth{
+			Email:           auth.Email_Auth,
+			Phone:           auth.Phone_Auth,
+			IsEmailVerified: auth.IsEmailVerified_Auth,
+			IsPhoneVerified: auth.IsPhoneVerified_Auth,
+			Created:         auth.Created_Auth,
+			Updated:         auth.Updated_Auth,
+		}
+	}
+
+	profile, err := s.db.Profiles().FindByUserID(ctx, user.Id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+	if profile != nil {
+		export.Profile = &ExportProfile{
+			DisplayName: profile.DisplayName,
+			Username:    profile.Username,
+			Locale:      profile.Locale,
+			Timezone:    profile.Timezone,
+			Attributes:  profile.Attributes,
+			Created:     profile.Created,
+			Updated:     profile.Updated,
+		}
+	}
+
+	providers, err := s.db.AuthProviders().FindByUserID(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		export.Providers = append(export.Providers, ExportProvider{
+			Provider:        p.Provider,
+			ProviderUserKey: p.ProviderUserKey,
+		})
+	}
+
+	mfa, err := s.db.Mfa().State(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	export.Mfa = ExportMfa{
+		Type:          mfa.Type,
+		Authenticator: mfa.Secret,
+		Phone:         mfa.Phone,
+		RecoveryCodes: mfa.Codes,
+	}
+
+	history, err := s.db.AuthenticationHistory().FindByUserID(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range history {
+		item := ExportHistory{
+			Id:       h.Id,
+			LoggedIn: h.LoggedIn,
+			Meta:     h.Meta,
+		}
+		if !h.LoggedOut.IsZero() {
+			loggedOut := h.LoggedOut
+			item.LoggedOut = &loggedOut
+		}
+		export.History = append(export.History, item)
+		if item.LoggedOut == nil {
+			export.Sessions = append(export.Sessions, item)
+		}
+	}
+
+	consents, err := s.db.Consents().FindByUserID(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range consents {
+		export.Consents = append(export.Consents, ExportConsent{
+			Kind:    c.Kind,
+			Version: c.Version,
+			Granted: c.Granted,
+			Revoked: c.Revoked,
+		})
+	}
+
+	return export, nil
+}
+
+// checkPassword reports whether password matches the hash stored in model,
+// the hash is upgraded when it is in an outdated format
+func (s *service) checkPassword(ctx context.Context, model *database.AuthModel, pw string) bool {
+	plaintext := s.cfg.PlaintextPasswords != nil && s.cfg.PlaintextPasswords()
+	ok, rehash := password.Verify(model.Password_Auth, model.Salt_Auth, pw, plaintext)
+	if !ok || !rehash {
+		return ok
+	}
+
+	hash, err := password.Hash(pw)
+	if err != nil {
+		s.log.Error(err)
+		return ok
+	}
+	model.Password_Auth = hash
+	model.Salt_Auth = ""
+	if err := s.db.Auth().Update(ctx, model); err != nil {
+		s.log.Error(err)
+	}
+	return ok
+}
+
+// caller returns the user owning the session of ctx
+func (s *service) caller(ctx context.Context) (*database.UsersModel, error) {
+	sid := s.session.SessionId(ctx)
+	if len(sid) == 0 {
+		return nil, httpError(401, i18n.Text(ctx, msgUnauthorized))
+	}
+	history, err := s.db.AuthenticationHistory().FindBySecret(ctx, string(sid))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, httpError(401, i18n.Text(ctx, msgUnauthorized))
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, internalError(ctx)
+	}
+	user, err := s.db.Users().FindByID(ctx, history.UserId)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, httpError(401, i18n.Text(ctx, msgUnauthorized))
+	}
+	if err != nil {
+		s.log.Error(err)
+		return nil, internalError(ctx)
+	}
+	return user, nil
+}
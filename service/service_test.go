@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/breach"
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/fakes"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/locale"
+)
+
+var errDatabase = errors.New("dial tcp 10.0.0.7:3306: connection refused")
+
+// lookupFailing fails the auth lookups by email
+type lookupFailing struct {
+	database.Database
+}
+
+func (db lookupFailing) Auth() database.Auth {
+	return authLookupFailing{db.Database.Auth()}
+}
+
+type authLookupFailing struct {
+	database.Auth
+}
+
+func (authLookupFailing) FindByEmail(context.Context, string) (*database.AuthModel, error) {
+	return nil, errDatabase
+}
+
+// txFailing fails the transactions
+type txFailing struct {
+	database.Database
+}
+
+func (txFailing) Tx(context.Context, func(tx database.Database) error) error {
+	return errDatabase
+}
+
+func TestInternalErrors(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &Config{
+		Sub: func() string { return "user" },
+		Iss: func() string { return "auth" },
+	}
+	memory := database.NewMemory()
+	newService := func(db database.Database) Service {
+		return NewService(fakes.NewAuth(), fakes.NewSession(), cfg, logger, db, &mailbox{}, breach.NewChecker(breach.Config{}), memory.RevokedTokens())
+	}
+	ctx := locale.WithLocale(context.Background(), "ru")
+	want := i18n.Text(ctx, msgInternal)
+
+	signUp := SignUpRequest{Email: "user@example.com", Password: testPassword}
+	signIn := SignInRequest{Email: "user@example.com", Password: testPassword}
+	for name, err := range map[string]error{
+		"sign up lookup":      newService(lookupFailing{memory}).SignUp(ctx, signUp).Err,
+		"sign up transaction": newService(txFailing{memory}).SignUp(ctx, signUp).Err,
+		"sign in lookup":      newService(lookupFailing{memory}).SignIn(ctx, signIn).Err,
+	} {
+		// the details are only logged, the response has the localized message
+		if err == nil || err.Error() != want || strings.Contains(err.Error(), "10.0.0.7") {
+			t.Errorf("%s: err = %v, want %q", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cheebo/gorest"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+	"github.com/nori-io/auth/service/locale"
+)
+
+// optional fields of the sign up form, they are accepted only when enabled in SignUpConfig.Fields
+const (
+	SignUpName       = "name"
+	SignUpUsername   = "username"
+	SignUpLocale     = "locale"
+	SignUpTimezone   = "timezone"
+	SignUpAttributes = "attributes"
+
+	// ConsentTerms is the kind of the consent recording the accepted terms of service
+	ConsentTerms = "terms"
+
+	maxDisplayName    = 64
+	maxAttributeValue = 255
+)
+
+// SignUpConfig reads the sign up form from the config manager, the lists are comma separated
+type SignUpConfig struct {
+	// Fields are the optional fields accepted on sign up: name, username, locale, timezone, attributes
+	Fields func() string
+	// Required are the fields which must be filled, they are accepted even when missing in Fields
+	Required func() string
+	// ReservedUsernames can't be taken in addition to the builtin ones
+	ReservedUsernames func() string
+	// Attributes are the keys of the custom attributes
+	Attributes func() string
+	// TermsVersion is the current version of the terms of service, the user must accept it
+	// to sign up. Empty disables the check
+	TermsVersion func() string
+}
+
+// usernames look like "john.doe", they start and end with a letter or a digit
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,30}[a-z0-9]$`)
+
+// builtinReserved are the usernames which could pass for the service or its staff
+var builtinReserved = []string{
+	"admin", "administrator", "root", "system", "support", "help", "info", "security",
+	"abuse", "postmaster", "webmaster", "hostmaster", "noreply", "no-reply", "moderator",
+	"staff", "official", "api", "auth", "oauth", "login", "signin", "signup", "me", "null",
+}
+
+// terms returns the current version of the terms of service, empty when they are not required
+func (c SignUpConfig) terms() string {
+	if c.TermsVersion == nil {
+		return ""
+	}
+	return strings.TrimSpace(c.TermsVersion())
+}
+
+// list splits the comma separated config value, empty items are skipped
+func list(value func() string) []string {
+	if value == nil {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value(), ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// signUpProfile checks the optional fields and the accepted terms, the problems are added to errField.
+// The returned profile has the normalized values
+func (s *service) signUpProfile(ctx context.Context, req SignUpRequest, errField *rest.ErrFieldResp) *database.ProfileModel {
+	cfg := s.cfg.SignUp
+	required := list(cfg.Required)
+	accepted := append(list(cfg.Fields), required...)
+	profile := &database.ProfileModel{}
+
+	values := map[string]bool{
+		SignUpName:       strings.TrimSpace(req.Name) != "",
+		SignUpUsername:   strings.TrimSpace(req.Username) != "",
+		SignUpLocale:     strings.TrimSpace(req.Locale) != "",
+		SignUpTimezone:   strings.TrimSpace(req.Timezone) != "",
+		SignUpAttributes: len(req.Attributes) > 0,
+	}
+	for _, field := range []string{SignUpName, SignUpUsername, SignUpLocale, SignUpTimezone, SignUpAttributes} {
+		switch {
+		case values[field] && !contains(accepted, field):
+			errField.AddError(field, 400, i18n.Text(ctx, msgFieldNotAccepted))
+			values[field] = false
+		case !values[field] && contains(required, field):
+			errField.AddError(field, 400, i18n.Text(ctx, msgField+"required"))
+		}
+	}
+
+	if values[SignUpName] {
+		profile.DisplayName = strings.TrimSpace(req.Name)
+		if !displayNameValid(profile.DisplayName) {
+			errField.AddError(SignUpName, 400, i18n.Text(ctx, msgNameInvalid, maxDisplayName))
+		}
+	}
+	if values[SignUpUsername] {
+		profile.Username = strings.ToLower(strings.TrimSpace(req.Username))
+		s.checkUsername(ctx, profile.Username, errField)
+	}
+	if values[SignUpLocale] {
+		profile.Locale = locale.Normalize(req.Locale)
+		if profile.Locale == "" || profile.Locale == "*" {
+			errField.AddError(SignUpLocale, 400, i18n.Text(ctx, msgLocaleInvalid))
+		}
+	}
+	if values[SignUpTimezone] {
+		profile.Timezone = strings.TrimSpace(req.Timezone)
+		if _, err := time.LoadLocation(profile.Timezone); err != nil || profile.Timezone == "Local" {
+			errField.AddError(SignUpTimezone, 400, i18n.Text(ctx, msgTimezoneInvalid))
+		}
+	}
+	if values[SignUpAttributes] {
+		keys := list(cfg.Attributes)
+		profile.Attributes = make(map[string]string, len(req.Attributes))
+		for key, value := range req.Attributes {
+			key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+			switch {
+			case !contains(keys, key):
+				errField.AddError(SignUpAttributes+"."+key, 400, i18n.Text(ctx, msgAttributeUnknown))
+			case utf8.RuneCountInString(value) > maxAttributeValue:
+				errField.AddError(SignUpAttributes+"."+key, 400, i18n.Text(ctx, msgAttributeTooLong, maxAttributeValue))
+			case value != "":
+				profile.Attributes[key] = value
+			}
+		}
+	}
+
+	if terms := cfg.terms(); terms != "" {
+		switch req.TermsVersion {
+		case terms:
+		case "":
+			errField.AddError("terms_version", 400, i18n.Text(ctx, msgTermsRequired))
+		default:
+			errField.AddError("terms_version", 400, i18n.Text(ctx, msgTermsOutdated, terms))
+		}
+	}
+	return profile
+}
+
+// checkUsername adds the errors of the lowercased username, it is taken when another profile has it
+func (s *service) checkUsername(ctx context.Context, username string, errField *rest.ErrFieldResp) {
+	if !usernamePattern.MatchString(username) {
+		errField.AddError(SignUpUsername, 400, i18n.Text(ctx, msgUsernameInvalid))
+		return
+	}
+	if contains(builtinReserved, username) || contains(list(s.cfg.SignUp.ReservedUsernames), username) {
+		errField.AddError(SignUpUsername, 400, i18n.Text(ctx, msgUsernameReserved))
+		return
+	}
+	_, err := s.db.Profiles().FindByUsername(ctx, username)
+	if err == nil {
+		errField.AddError(SignUpUsername, 400, i18n.Text(ctx, msgUsernameTaken))
+		return
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		// the unique index still rejects the taken username on insert
+		s.log.Error(err)
+	}
+}
+
+func displayNameValid(name string) bool {
+	if !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxDisplayName {
+		return false
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// userLanguages prefers the locale the user chose on sign up over the languages of the request
+func (s *service) userLanguages(ctx context.Context, userId uint64) context.Context {
+	profile, err := s.db.Profiles().FindByUserID(ctx, userId)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			s.log.Error(err)
+		}
+		return ctx
+	}
+	return locale.WithLocale(ctx, profile.Locale)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/breach"
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/fakes"
+	"github.com/nori-io/auth/service/i18n"
+)
+
+// newSignUpService is the service with the sign up form, it is called directly to see the field errors
+func newSignUpService(t *testing.T, form SignUpConfig) (Service, database.Database) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &Config{
+		Sub:    func() string { return "user" },
+		Iss:    func() string { return "auth" },
+		SignUp: form,
+	}
+	db := database.NewMemory()
+	return NewService(fakes.NewAuth(), fakes.NewSession(), cfg, logger, db, &mailbox{}, breach.NewChecker(breach.Config{}), db.RevokedTokens()), db
+}
+
+func value(v string) func() string {
+	return func() string { return v }
+}
+
+// hasError tells whether the response sent for err has the message of code
+func hasError(err error, code string, args ...interface{}) bool {
+	if err == nil {
+		return false
+	}
+	body, _ := json.Marshal(err)
+	message, _ := json.Marshal(i18n.Text(context.Background(), code, args...))
+	return bytes.Contains(body, message)
+}
+
+func TestSignUpProfile(t *testing.T) {
+	srv, db := newSignUpService(t, SignUpConfig{
+		Fields:     value("name, locale, timezone, attributes"),
+		Required:   value("username"),
+		Attributes: value("company"),
+	})
+	ctx := context.Background()
+
+	resp := srv.SignUp(ctx, SignUpRequest{
+		Email:      "user@example.com",
+		Password:   testPassword,
+		Name:       "  Jane Doe ",
+		Username:   "Jane.Doe",
+		Locale:     "pt_br",
+		Timezone:   "Europe/Lisbon",
+		Attributes: map[string]string{" Company ": " Acme ", "company": ""},
+	})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if resp.Name != "Jane Doe" || resp.Username != "jane.doe" {
+		t.Errorf("response = %+v", resp)
+	}
+	profile, err := db.Profiles().FindByUserID(ctx, resp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.DisplayName != "Jane Doe" || profile.Username != "jane.doe" || profile.Locale != "pt-BR" || profile.Timezone != "Europe/Lisbon" || profile.Attributes["company"] != "Acme" {
+		t.Errorf("profile = %+v", profile)
+	}
+
+	for _, tc := range []struct {
+		name string
+		req  SignUpRequest
+		code string
+		args []interface{}
+	}{
+		{"required", SignUpRequest{}, msgField + "required", nil},
+		{"taken", SignUpRequest{Username: "JANE.DOE"}, msgUsernameTaken, nil},
+		{"reserved", SignUpRequest{Username: "admin"}, msgUsernameReserved, nil},
+		{"too short", SignUpRequest{Username: "jd"}, msgUsernameInvalid, nil},
+		{"separator at the end", SignUpRequest{Username: "jane."}, msgUsernameInvalid, nil},
+		{"not latin", SignUpRequest{Username: "жанна"}, msgUsernameInvalid, nil},
+		{"long name", SignUpRequest{Username: "jane", Name: strings.Repeat("я", maxDisplayName+1)}, msgNameInvalid, []interface{}{maxDisplayName}},
+		{"control in name", SignUpRequest{Username: "jane", Name: "Jane\u0007"}, msgNameInvalid, []interface{}{maxDisplayName}},
+		{"locale", SignUpRequest{Username: "jane", Locale: "*"}, msgLocaleInvalid, nil},
+		{"timezone", SignUpRequest{Username: "jane", Timezone: "Mars/Olympus"}, msgTimezoneInvalid, nil},
+		{"local timezone", SignUpRequest{Username: "jane", Timezone: "Local"}, msgTimezoneInvalid, nil},
+		{"unknown attribute", SignUpRequest{Username: "jane", Attributes: map[string]string{"role": "admin"}}, msgAttributeUnknown, nil},
+		{"long attribute", SignUpRequest{Username: "jane", Attributes: map[string]string{"company": strings.Repeat("a", maxAttributeValue+1)}}, msgAttributeTooLong, []interface{}{maxAttributeValue}},
+	} {
+		tc.req.Email, tc.req.Password = "other@example.com", testPassword
+		if resp := srv.SignUp(ctx, tc.req); !hasError(resp.Err, tc.code, tc.args...) {
+			t.Errorf("%s: err = %v, want %s", tc.name, resp.Err, tc.code)
+		}
+	}
+	if _, err := db.Auth().FindByEmail(ctx, "other@example.com"); err == nil {
+		t.Error("user with the invalid profile is created")
+	}
+}
+
+func TestSignUpFields(t *testing.T) {
+	srv, _ := newSignUpService(t, SignUpConfig{})
+	ctx := context.Background()
+
+	// the fields which aren't enabled are rejected
+	for name, req := range map[string]SignUpRequest{
+		SignUpName:       {Name: "Jane"},
+		SignUpUsername:   {Username: "jane"},
+		SignUpLocale:     {Locale: "en"},
+		SignUpTimezone:   {Timezone: "UTC"},
+		SignUpAttributes: {Attributes: map[string]string{"company": "Acme"}},
+	} {
+		req.Email, req.Password = "user@example.com", testPassword
+		if resp := srv.SignUp(ctx, req); !hasError(resp.Err, msgFieldNotAccepted) {
+			t.Errorf("%s: err = %v", name, resp.Err)
+		}
+	}
+
+	srv, db := newSignUpService(t, SignUpConfig{Fields: value("username"), ReservedUsernames: value("acme, Billing")})
+	for _, username := range []string{"acme", "billing", "support"} {
+		resp := srv.SignUp(ctx, SignUpRequest{Email: "user@example.com", Password: testPassword, Username: username})
+		if !hasError(resp.Err, msgUsernameReserved) {
+			t.Errorf("%s: err = %v", username, resp.Err)
+		}
+	}
+
+	// the profile is created empty without the optional fields
+	resp := srv.SignUp(ctx, SignUpRequest{Email: "user@example.com", Password: testPassword})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	profile, err := db.Profiles().FindByUserID(ctx, resp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.DisplayName != "" || profile.Username != "" || profile.Locale != "" || profile.Timezone != "" || len(profile.Attributes) != 0 {
+		t.Errorf("profile = %+v", profile)
+	}
+}
+
+func TestSignUpTerms(t *testing.T) {
+	srv, db := newSignUpService(t, SignUpConfig{TermsVersion: value(" 2024-05 ")})
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		version string
+		code    string
+		args    []interface{}
+	}{
+		{"", msgTermsRequired, nil},
+		{"2023-01", msgTermsOutdated, []interface{}{"2024-05"}},
+	} {
+		resp := srv.SignUp(ctx, SignUpRequest{Email: "user@example.com", Password: testPassword, TermsVersion: tc.version})
+		if !hasError(resp.Err, tc.code, tc.args...) {
+			t.Errorf("%q: err = %v, want %s", tc.version, resp.Err, tc.code)
+		}
+	}
+
+	resp := srv.SignUp(ctx, SignUpRequest{Email: "user@example.com", Password: testPassword, TermsVersion: "2024-05"})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	consents, err := db.Consents().FindByUserID(ctx, resp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(consents) != 1 || consents[0].Kind != ConsentTerms || consents[0].Version != "2024-05" || consents[0].Granted.IsZero() {
+		t.Errorf("consents = %+v", consents)
+	}
+
+	// nothing is recorded when the terms aren't configured
+	srv, db = newSignUpService(t, SignUpConfig{})
+	resp = srv.SignUp(ctx, SignUpRequest{Email: "user@example.com", Password: testPassword, TermsVersion: "2024-05"})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+	if consents, _ := db.Consents().FindByUserID(ctx, resp.Id); len(consents) != 0 {
+		t.Errorf("consents = %+v", consents)
+	}
+}
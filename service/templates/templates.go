@@ -0,0 +1,193 @@
+// Package templates renders the transactional emails. Every email has a subject, a plain
+// text body and optionally an HTML body, each locale may override any of them
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/nori-io/auth/service/locale"
+)
+
+// Names of the emails
+const (
+	PasswordReset     = "password_reset"
+	PasswordChanged   = "password_changed"
+	EmailVerification = "email_verification"
+	MagicLink         = "magic_link"
+)
+
+// Names lists the emails which have default templates
+var Names = []string{PasswordReset, PasswordChanged, EmailVerification, MagicLink}
+
+const defaultLocale = "en"
+
+// ErrNotFound is returned for emails without templates
+var ErrNotFound = errors.New("template not found")
+
+//go:embed default
+var defaults embed.FS
+
+// Data is passed to the templates, an email uses the fields it needs
+type Data struct {
+	Email string
+	// Link is the reset, verification or magic link
+	Link string
+	// Expires is when the link stops working
+	Expires time.Time
+	// Time is when the event of the notification happened
+	Time time.Time
+}
+
+// Message is the rendered email, HTML is empty when the email has only the text template
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Config struct {
+	// Dir has a directory per locale with the templates overriding the defaults:
+	// <locale>/<name>.subject.txt, <locale>/<name>.txt and <locale>/<name>.html
+	Dir func() string
+	// DefaultLocale is used when none of the languages of the user has templates
+	DefaultLocale func() string
+}
+
+type Templates struct {
+	cfg Config
+}
+
+func New(cfg Config) *Templates {
+	return &Templates{cfg: cfg}
+}
+
+// Render renders the email in the first of the languages which has its text template. The
+// configured directory may override some parts of a locale, the rest are taken from the defaults
+func (t *Templates) Render(name string, languages []string, data Data) (*Message, error) {
+	var sources []fs.FS
+	for _, tag := range t.candidates(languages) {
+		if s := t.sources(tag); exists(s, name+".txt") {
+			sources = s
+			break
+		}
+	}
+	if sources == nil {
+		return nil, ErrNotFound
+	}
+
+	subject, err := t.text(sources, name+".subject.txt", data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := t.text(sources, name+".txt", data)
+	if err != nil {
+		return nil, err
+	}
+	html, err := t.html(sources, name+".html", data)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject),
+		Text:    text,
+		HTML:    html,
+	}, nil
+}
+
+// candidates are the locales to try, the configured default locale goes before the built in one
+func (t *Templates) candidates(languages []string) []string {
+	fallback := defaultLocale
+	if t.cfg.DefaultLocale != nil && t.cfg.DefaultLocale() != "" {
+		fallback = t.cfg.DefaultLocale()
+	}
+	// languages may be the slice kept in the context, it is not appended to
+	return locale.Candidates(append(languages[:len(languages):len(languages)], fallback), defaultLocale)
+}
+
+// sources are the directories with the templates of the locale, the configured one goes first
+func (t *Templates) sources(tag string) []fs.FS {
+	var sources []fs.FS
+	if t.cfg.Dir != nil && t.cfg.Dir() != "" {
+		sources = append(sources, os.DirFS(filepath.Join(t.cfg.Dir(), tag)))
+	}
+	if sub, err := fs.Sub(defaults, "default/"+tag); err == nil {
+		sources = append(sources, sub)
+	}
+	return sources
+}
+
+func (t *Templates) text(sources []fs.FS, file string, data Data) (string, error) {
+	src, err := lookup(sources, file)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := texttemplate.New(file).Parse(src)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (t *Templates) html(sources []fs.FS, file string, data Data) (string, error) {
+	src, err := lookup(sources, file)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := htmltemplate.New(file).Parse(src)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func exists(sources []fs.FS, file string) bool {
+	for _, src := range sources {
+		if _, err := fs.Stat(src, file); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the first file found in sources
+func lookup(sources []fs.FS, file string) (string, error) {
+	for _, src := range sources {
+		b, err := fs.ReadFile(src, file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return "", ErrNotFound
+}
+
+// Sample is the data the previews are rendered with
+func Sample(email string) Data {
+	now := time.Now()
+	return Data{
+		Email:   email,
+		Link:    "https://example.com/link?token=sample",
+		Expires: now.Add(time.Hour),
+		Time:    now,
+	}
+}
@@ -0,0 +1,140 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testData = Data{
+	Email:   "user@example.com",
+	Link:    "https://example.com/reset?token=a&b",
+	Expires: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	Time:    time.Date(2024, 5, 1, 11, 30, 0, 0, time.UTC),
+}
+
+// writeFiles creates the files of the template directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDefaults(t *testing.T) {
+	tmpl := New(Config{})
+	for _, lang := range []string{"en", "ru"} {
+		for _, name := range Names {
+			msg, err := tmpl.Render(name, []string{lang}, testData)
+			if err != nil {
+				t.Fatalf("%s %s: %v", lang, name, err)
+			}
+			if msg.Subject == "" || strings.Contains(msg.Subject, "\n") || msg.Text == "" || msg.HTML == "" {
+				t.Errorf("%s %s: message = %+v", lang, name, msg)
+			}
+		}
+	}
+
+	msg, err := tmpl.Render(PasswordReset, nil, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the link is escaped in HTML only
+	if !strings.Contains(msg.Text, testData.Link) || !strings.Contains(msg.HTML, "token=a&amp;b") || !strings.Contains(msg.Text, "user@example.com") {
+		t.Errorf("message = %+v", msg)
+	}
+	if _, err := tmpl.Render("unknown", []string{"en"}, testData); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown template: err = %v", err)
+	}
+}
+
+func TestLocaleFallback(t *testing.T) {
+	en, _ := New(Config{}).Render(PasswordReset, []string{"en"}, testData)
+	ru, _ := New(Config{}).Render(PasswordReset, []string{"ru"}, testData)
+
+	for _, tc := range []struct {
+		name      string
+		languages []string
+		fallback  string
+		want      string
+	}{
+		{"first with templates", []string{"de", "ru-RU", "en"}, "", ru.Subject},
+		{"region", []string{"ru-UA"}, "", ru.Subject},
+		{"built in default", []string{"de"}, "", en.Subject},
+		{"configured default", []string{"de"}, "ru", ru.Subject},
+		{"user language before the default", []string{"en"}, "ru", en.Subject},
+		{"no languages", nil, "", en.Subject},
+	} {
+		tmpl := New(Config{DefaultLocale: func() string { return tc.fallback }})
+		msg, err := tmpl.Render(PasswordReset, tc.languages, testData)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if msg.Subject != tc.want {
+			t.Errorf("%s: subject = %q, want %q", tc.name, msg.Subject, tc.want)
+		}
+	}
+
+	// the languages of the caller are kept as they are
+	languages := make([]string, 1, 4)
+	languages[0] = "de"
+	New(Config{}).Render(PasswordReset, languages, testData)
+	if extra := languages[:2]; extra[1] != "" {
+		t.Errorf("languages are appended to: %q", extra)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		// only the subject of the built in locale is overridden
+		"ru/password_reset.subject.txt": "Новый пароль для {{.Email}}\n",
+		// the locale without defaults has the text email only
+		"de/password_reset.subject.txt": "Passwort",
+		"de/password_reset.txt":         "Hallo {{.Email}}, {{.Link}}",
+		// the incomplete locale is skipped
+		"fr/password_reset.subject.txt": "Mot de passe",
+	})
+	tmpl := New(Config{Dir: func() string { return dir }})
+	ru, _ := New(Config{}).Render(PasswordReset, []string{"ru"}, testData)
+
+	msg, err := tmpl.Render(PasswordReset, []string{"ru"}, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Новый пароль для user@example.com" || msg.Text != ru.Text || msg.HTML != ru.HTML {
+		t.Errorf("ru = %+v", msg)
+	}
+
+	msg, err = tmpl.Render(PasswordReset, []string{"de-AT"}, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Passwort" || msg.Text != "Hallo user@example.com, "+testData.Link || msg.HTML != "" {
+		t.Errorf("de = %+v", msg)
+	}
+
+	msg, err = tmpl.Render(PasswordReset, []string{"fr", "ru"}, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(msg.Subject, "Новый пароль") {
+		t.Errorf("fr = %+v", msg)
+	}
+
+	brokenDir := writeFiles(t, map[string]string{"en/password_reset.txt": "{{.Link"})
+	broken := New(Config{Dir: func() string { return brokenDir }})
+	if _, err := broken.Render(PasswordReset, []string{"en"}, testData); err == nil {
+		t.Error("broken template is rendered")
+	}
+}
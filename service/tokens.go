@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nori-io/nori-common/interfaces"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+)
+
+// RefreshToken replaces the token of the caller with the token of a new session,
+// the old session is closed and its token denied. The sessions are refreshed until
+// RefreshMaxAge passes since the sign in, the user must sign in again afterwards
+func (s *service) RefreshToken(ctx context.Context, req RefreshTokenRequest) (resp *RefreshTokenResponse) {
+	resp = &RefreshTokenResponse{}
+
+	user, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if user.StatusId == database.UserStatusSuspended {
+		resp.Err = httpError(403, i18n.Text(ctx, msgAccountSuspended))
+		return resp
+	}
+	sid := string(s.session.SessionId(ctx))
+	history, err := s.db.AuthenticationHistory().FindBySecret(ctx, sid)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	signedIn := history.SignedIn
+	if signedIn.IsZero() {
+		signedIn = history.LoggedIn
+	}
+	if time.Since(signedIn) > duration(s.cfg.RefreshMaxAge, defaultRefreshMaxAge) {
+		resp.Err = httpError(401, i18n.Text(ctx, msgSignInRequired))
+		return resp
+	}
+	model, err := s.db.Auth().FindByUserID(ctx, user.Id)
+	if errors.Is(err, database.ErrNotFound) {
+		resp.Err = httpError(401, i18n.Text(ctx, msgUnauthorized))
+		return resp
+	}
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	// the password could expire since the sign in
+	state := interfaces.SessionActive
+	if s.passwordExpired(model) {
+		state = interfaces.SessionLocked
+		resp.PasswordChangeRequired = true
+	}
+	token, err := s.startSession(ctx, model, state, signedIn)
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	if err := s.revokeSession(ctx, sid, s.tokenExpiry(ctx, history.LoggedIn)); err != nil {
+		s.log.Error(err)
+	}
+	history.LoggedOut = time.Now()
+	if err := s.db.AuthenticationHistory().Update(ctx, history); err != nil {
+		s.log.Error(err)
+	}
+
+	resp.Token = token
+	return resp
+}
+
+// VerifyToken returns the user of the request token, other services call it to check the tokens they get
+func (s *service) VerifyToken(ctx context.Context, req VerifyTokenRequest) (resp *VerifyTokenResponse) {
+	resp = &VerifyTokenResponse{}
+
+	user, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if user.Deleted != nil || user.StatusId == database.UserStatusSuspended {
+		resp.Err = httpError(401, i18n.Text(ctx, msgUnauthorized))
+		return resp
+	}
+	history, err := s.db.AuthenticationHistory().FindBySecret(ctx, string(s.session.SessionId(ctx)))
+	if err != nil {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+	model, err := s.db.Auth().FindByUserID(ctx, user.Id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.log.Error(err)
+		resp.Err = internalError(ctx)
+		return resp
+	}
+
+	resp.UserId = user.Id
+	resp.Type = user.Type
+	if model != nil {
+		resp.Email = model.Email_Auth
+	}
+	resp.Expires = s.tokenExpiry(ctx, history.LoggedIn)
+	return resp
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+// lastSession returns the newest sign in record of the user
+func lastSession(t *testing.T, db database.Database, userId uint64) database.AuthenticationHistoryModel {
+	t.Helper()
+	history, err := db.AuthenticationHistory().FindByUserID(context.Background(), userId)
+	if err != nil || len(history) == 0 {
+		t.Fatalf("history = %v, %v", history, err)
+	}
+	return history[0]
+}
+
+func TestRefreshToken(t *testing.T) {
+	server, db := newConfiguredServer(t, &Config{}, nil)
+	user := signUp(t, server, "user@example.com")
+	token := signIn(t, server, "user@example.com").Token
+	signedIn := lastSession(t, db, user.Id).SignedIn
+
+	code, body := call(t, server, "POST", "/auth/token/refresh", token, nil)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: %d %s", code, body)
+	}
+	var resp RefreshTokenResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token == "" || resp.Token == token || resp.PasswordChangeRequired {
+		t.Errorf("response = %+v", resp)
+	}
+	if code, body := call(t, server, "GET", "/auth/token/verify", token, nil); code == http.StatusOK {
+		t.Errorf("refreshed token is accepted: %s", body)
+	}
+	if code, body := call(t, server, "GET", "/auth/token/verify", resp.Token, nil); code != http.StatusOK {
+		t.Errorf("verify: %d %s", code, body)
+	}
+	// the new session keeps the time of the sign in
+	if got := lastSession(t, db, user.Id).SignedIn; !got.Equal(signedIn) {
+		t.Errorf("signed in = %v, want %v", got, signedIn)
+	}
+}
+
+func TestRefreshTokenSignInRequired(t *testing.T) {
+	server, db := newConfiguredServer(t, &Config{RefreshMaxAge: func() string { return "1h" }}, nil)
+	user := signUp(t, server, "user@example.com")
+	token := signIn(t, server, "user@example.com").Token
+
+	history := lastSession(t, db, user.Id)
+	history.SignedIn = time.Now().Add(-2 * time.Hour)
+	if err := db.AuthenticationHistory().Update(context.Background(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := call(t, server, "POST", "/auth/token/refresh", token, nil); code == http.StatusOK {
+		t.Errorf("session signed in too long ago is refreshed: %s", body)
+	}
+}
+
+func TestRefreshTokenPasswordExpired(t *testing.T) {
+	server, db := newConfiguredServer(t, &Config{PasswordMaxAge: func() string { return "1h" }}, nil)
+	user := signUp(t, server, "user@example.com")
+	token := signIn(t, server, "user@example.com").Token
+
+	model, err := db.Auth().FindByUserID(context.Background(), user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.PasswordChanged_Auth = time.Now().Add(-2 * time.Hour)
+	if err := db.Auth().Update(context.Background(), model); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body := call(t, server, "POST", "/auth/token/refresh", token, nil)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: %d %s", code, body)
+	}
+	var resp RefreshTokenResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.PasswordChangeRequired {
+		t.Errorf("response = %+v", resp)
+	}
+	// the locked session is accepted only by the change password endpoint
+	if code, body := call(t, server, "GET", "/auth/token/verify", resp.Token, nil); code == http.StatusOK {
+		t.Errorf("token of the locked session is accepted: %s", body)
+	}
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
 	"github.com/nori-io/nori-common/endpoint"
 	"github.com/nori-io/nori-common/interfaces"
 	"github.com/nori-io/nori-common/transport/http"
@@ -12,13 +14,23 @@ func Transport(
 	auth interfaces.Auth,
 	transport interfaces.HTTPTransport,
 	session interfaces.Session,
+	revoked database.RevokedTokens,
 	router interfaces.Http,
 	srv Service,
+	messages *i18n.Catalog,
 	logger *logrus.Logger,
 ) {
 
+	// the messages of the errors are in the languages of the Accept-Language header
+	localized := http.ServerBefore(i18n.ToContext(messages))
+
+	// the registered routes are checked against the OpenAPI document at the end
+	recorder := &routeRecorder{Http: router}
+	router = recorder
+
+	notRevoked := NotRevoked(revoked, session, logger)
 	authenticated := func(e endpoint.Endpoint) endpoint.Endpoint {
-		return auth.Authenticated()(session.Verify()(e))
+		return auth.Authenticated()(notRevoked(session.Verify()(e)))
 	}
 
 	signupHandler := http.NewServer(
@@ -26,16 +38,19 @@ func Transport(
 		DecodeSignUpRequest,
 		http.EncodeJSONResponse,
 		logger,
+		localized,
 	)
 	signinHandler := http.NewServer(
 		MakeSignInEndpoint(srv),
 		DecodeLogInRequest,
 		http.EncodeJSONResponse,
 		logger,
+		localized,
 	)
 
 	opts := []http.ServerOption{
 		http.ServerBefore(transport.ToContext()),
+		localized,
 	}
 
 	signoutHandler := http.NewServer(
@@ -46,15 +61,116 @@ func Transport(
 		opts...,
 	)
 
-/*	router.HandleFunc("/test", func(w http2.ResponseWriter, r *http2.Request) {
+	exportHandler := http.NewServer(
+		authenticated(MakeExportEndpoint(srv)),
+		DecodeExportRequest,
+		EncodeExportResponse,
+		logger,
+		opts...,
+	)
+
+	userExportHandler := http.NewServer(
+		authenticated(MakeExportEndpoint(srv)),
+		DecodeUserExportRequest,
+		EncodeExportResponse,
+		logger,
+		opts...,
+	)
+
+	deleteAccountHandler := http.NewServer(
+		authenticated(MakeDeleteAccountEndpoint(srv)),
+		DecodeDeleteAccountRequest,
+		http.EncodeJSONResponse,
+		logger,
+		opts...,
+	)
+
+	changePasswordHandler := http.NewServer(
+		auth.Authenticated()(notRevoked(MakeChangePasswordEndpoint(srv))),
+		DecodeChangePasswordRequest,
+		http.EncodeJSONResponse,
+		logger,
+		opts...,
+	)
+
+	forgotPasswordHandler := http.NewServer(
+		MakeForgotPasswordEndpoint(srv),
+		DecodeForgotPasswordRequest,
+		http.EncodeJSONResponse,
+		logger,
+		localized,
+	)
+
+	resetPasswordHandler := http.NewServer(
+		MakeResetPasswordEndpoint(srv),
+		DecodeResetPasswordRequest,
+		http.EncodeJSONResponse,
+		logger,
+		localized,
+	)
+
+	suspendUserHandler := http.NewServer(
+		authenticated(MakeSuspendUserEndpoint(srv)),
+		DecodeSuspendUserRequest,
+		http.EncodeJSONResponse,
+		logger,
+		opts...,
+	)
+
+	exportUsersHandler := http.NewServer(
+		authenticated(MakeExportUsersEndpoint(srv)),
+		DecodeExportUsersRequest,
+		EncodeExportUsersResponse,
+		logger,
+		opts...,
+	)
+
+	mailPreviewHandler := http.NewServer(
+		authenticated(MakeMailPreviewEndpoint(srv)),
+		DecodeMailPreviewRequest,
+		http.EncodeJSONResponse,
+		logger,
+		opts...,
+	)
+
+	refreshTokenHandler := http.NewServer(
+		authenticated(MakeRefreshTokenEndpoint(srv)),
+		DecodeRefreshTokenRequest,
+		http.EncodeJSONResponse,
+		logger,
+		opts...,
+	)
+
+	verifyTokenHandler := http.NewServer(
+		authenticated(MakeVerifyTokenEndpoint(srv)),
+		DecodeVerifyTokenRequest,
+		http.EncodeJSONResponse,
+		logger,
+		opts...,
+	)
+
+	/*	router.HandleFunc("/test", func(w http2.ResponseWriter, r *http2.Request) {
 		w.Write([]byte("Hello World!!!"))
 	}).Methods("GET")*/
 	router.Handle("/auth/signup", signupHandler).Methods("POST")
 
 	router.Handle("/auth/signin", signinHandler).Methods("POST")
 	router.Handle("/auth/signout", signoutHandler).Methods("GET")
+	router.Handle("/auth/token/refresh", refreshTokenHandler).Methods("POST")
+	router.Handle("/auth/token/verify", verifyTokenHandler).Methods("GET")
+	router.Handle("/auth/password/change", changePasswordHandler).Methods("POST")
+	router.Handle("/auth/password/forgot", forgotPasswordHandler).Methods("POST")
+	router.Handle("/auth/password/reset", resetPasswordHandler).Methods("POST")
+	router.Handle("/auth/me", deleteAccountHandler).Methods("DELETE")
+	router.Handle("/auth/me/export", exportHandler).Methods("GET")
+	router.Handle("/auth/users/export", exportUsersHandler).Methods("GET")
+	router.Handle("/auth/users/{id:[0-9]+}/export", userExportHandler).Methods("GET")
+	router.Handle("/auth/users/{id:[0-9]+}/suspend", suspendUserHandler).Methods("POST")
+	router.Handle("/auth/users/{id:[0-9]+}/unsuspend", suspendUserHandler).Methods("POST")
+	router.Handle("/auth/mail/templates/{name}/preview", mailPreviewHandler).Methods("GET")
+	router.Handle("/auth/openapi.json", OpenAPIHandler(logger)).Methods("GET")
 
-
+	for _, drift := range specDrift(recorder.routes) {
+		logger.Errorf("openapi: %s", drift)
+	}
 }
-
-
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nori-io/auth/service/breach"
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/fakes"
+)
+
+const testPassword = "Xy7!kq2Lmn#p"
+
+// newTestServer wires Transport with the fakes over the in-memory database
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server, _ := newConfiguredServer(t, &Config{}, nil)
+	return server
+}
+
+// newConfiguredServer is newTestServer with the config and the mailer, nil mail is discarded.
+// The database is returned to alter the records
+func newConfiguredServer(t *testing.T, cfg *Config, mail Mailer) (*httptest.Server, database.Database) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	db := database.NewMemory()
+	auth := fakes.NewAuth()
+	session := fakes.NewSession()
+	router := fakes.NewRouter()
+	cfg.Sub = func() string { return "user" }
+	cfg.Iss = func() string { return "auth" }
+	if mail == nil {
+		mail = &mailbox{}
+	}
+	srv := NewService(auth, session, cfg, logger, db, mail, breach.NewChecker(breach.Config{}), db.RevokedTokens())
+	Transport(auth, fakes.NewTransport(), session, db.RevokedTokens(), router, srv, nil, logger)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, db
+}
+
+// call sends body as JSON with the bearer token when it is set, the response body is returned
+func call(t *testing.T, server *httptest.Server, method, path, token string, body interface{}) (int, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, b
+}
+
+func signUp(t *testing.T, server *httptest.Server, email string) SignUpResponse {
+	t.Helper()
+	code, body := call(t, server, "POST", "/auth/signup", "", SignUpRequest{Email: email, Password: testPassword})
+	if code != http.StatusOK {
+		t.Fatalf("sign up: %d %s", code, body)
+	}
+	var resp SignUpResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func signIn(t *testing.T, server *httptest.Server, email string) SignInResponse {
+	t.Helper()
+	code, body := call(t, server, "POST", "/auth/signin", "", SignInRequest{Email: email, Password: testPassword})
+	if code != http.StatusOK {
+		t.Fatalf("sign in: %d %s", code, body)
+	}
+	var resp SignInResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestSignUp(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := signUp(t, server, "user@example.com")
+	if resp.Id == 0 || resp.Email != "user@example.com" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	code, body := call(t, server, "POST", "/auth/signup", "", SignUpRequest{Email: "user@example.com", Password: testPassword})
+	if code == http.StatusOK {
+		t.Errorf("duplicate email is accepted: %s", body)
+	}
+}
+
+func TestSignUpValidation(t *testing.T) {
+	server := newTestServer(t)
+
+	for name, req := range map[string]SignUpRequest{
+		"invalid email":  {Email: "user", Password: testPassword},
+		"empty password": {Email: "user@example.com"},
+		"weak password":  {Email: "user@example.com", Password: "12345678"},
+	} {
+		if code, body := call(t, server, "POST", "/auth/signup", "", req); code == http.StatusOK {
+			t.Errorf("%s is accepted: %s", name, body)
+		}
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	server := newTestServer(t)
+	user := signUp(t, server, "user@example.com")
+
+	resp := signIn(t, server, "user@example.com")
+	if resp.Id != user.Id || resp.Token == "" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	for name, req := range map[string]SignInRequest{
+		"wrong password": {Email: "user@example.com", Password: testPassword + "1"},
+		"unknown email":  {Email: "nobody@example.com", Password: testPassword},
+	} {
+		if code, body := call(t, server, "POST", "/auth/signin", "", req); code == http.StatusOK {
+			t.Errorf("%s is accepted: %s", name, body)
+		}
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	server := newTestServer(t)
+	signUp(t, server, "user@example.com")
+	token := signIn(t, server, "user@example.com").Token
+
+	if code, body := call(t, server, "GET", "/auth/signout", "", nil); code == http.StatusOK {
+		t.Errorf("sign out without token is accepted: %s", body)
+	}
+	if code, body := call(t, server, "GET", "/auth/signout", token, nil); code != http.StatusOK {
+		t.Fatalf("sign out: %d %s", code, body)
+	}
+	if code, body := call(t, server, "GET", "/auth/signout", token, nil); code == http.StatusOK {
+		t.Errorf("token of the closed session is accepted: %s", body)
+	}
+
+	// the other sessions stay open
+	other := signIn(t, server, "user@example.com").Token
+	if code, body := call(t, server, "GET", "/auth/token/verify", other, nil); code != http.StatusOK {
+		t.Errorf("verify: %d %s", code, body)
+	}
+}
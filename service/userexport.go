@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nori-io/auth/service/database"
+	"github.com/nori-io/auth/service/i18n"
+)
+
+const (
+	UsersExportCSV   = "csv"
+	UsersExportJSONL = "jsonl"
+
+	usersExportPage = 500
+)
+
+// UsersExportFields are the fields the bulk export may select, all of them are exported by default
+var UsersExportFields = []string{
+	"id", "email", "phone", "status_id", "type", "email_verified", "phone_verified",
+	"created", "updated", "providers", "mfa_type",
+}
+
+// UsersExportFieldsValid returns an error naming the first unknown field
+func UsersExportFieldsValid(fields []string) error {
+	for _, f := range fields {
+		if !contains(UsersExportFields, f) {
+			return fmt.Errorf("unknown field %s", f)
+		}
+	}
+	return nil
+}
+
+// ExportUsers streams the users to the admin, the encoder writes them with Write
+func (s *service) ExportUsers(ctx context.Context, req ExportUsersRequest) (resp *ExportUsersResponse) {
+	resp = &ExportUsersResponse{Format: req.Format}
+
+	caller, err := s.caller(ctx)
+	if err != nil {
+		resp.Err = err
+		return resp
+	}
+	if caller.Type != database.UserTypeAdmin {
+		resp.Err = httpError(403, i18n.Text(ctx, msgForbidden))
+		return resp
+	}
+
+	filter := database.UsersFilter{Type: req.Type}
+	resp.Write = func(w io.Writer) error {
+		// the request context is still alive while the response is encoded
+		return WriteUsers(ctx, s.db, w, req.Format, req.Fields, filter)
+	}
+	return resp
+}
+
+// WriteUsers streams the users matching filter to w page by page, so only a page is kept in
+// memory. Service accounts have no auth row, their email, phone and verification flags are empty
+func WriteUsers(ctx context.Context, db database.Database, w io.Writer, format string, fields []string, filter database.UsersFilter) error {
+	if len(fields) == 0 {
+		fields = UsersExportFields
+	}
+	if err := UsersExportFieldsValid(fields); err != nil {
+		return err
+	}
+
+	var write func(values map[string]interface{}) error
+	switch format {
+	case UsersExportCSV:
+		cw := csv.NewWriter(w)
+		defer cw.Flush()
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+		write = func(values map[string]interface{}) error {
+			record := make([]string, len(fields))
+			for i, f := range fields {
+				record[i] = csvValue(values[f])
+			}
+			return cw.Write(record)
+		}
+	case UsersExportJSONL, "":
+		enc := json.NewEncoder(w)
+		write = func(values map[string]interface{}) error {
+			return enc.Encode(values)
+		}
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+
+	providers := contains(fields, "providers")
+	filter.Limit = usersExportPage
+	for {
+		users, next, err := db.Users().List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			values, err := exportedValues(ctx, db, user, providers)
+			if err != nil {
+				return err
+			}
+			selected := make(map[string]interface{}, len(fields))
+			for _, f := range fields {
+				selected[f] = values[f]
+			}
+			if err := write(selected); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		filter.Cursor = next
+	}
+}
+
+func exportedValues(ctx context.Context, db database.Database, user database.UsersModel, providers bool) (map[string]interface{}, error) {
+	values := map[string]interface{}{
+		"id":             user.Id,
+		"email":          "",
+		"phone":          "",
+		"status_id":      user.StatusId,
+		"type":           user.Type,
+		"email_verified": false,
+		"phone_verified": false,
+		"created":        user.Created,
+		"updated":        user.Updated,
+		"providers":      []string{},
+		"mfa_type":       user.MfaType,
+	}
+
+	auth, err := db.Auth().FindByUserID(ctx, user.Id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+	if auth != nil {
+		values["email"] = auth.Email_Auth
+		values["phone"] = auth.Phone_Auth
+		values["email_verified"] = auth.IsEmailVerified_Auth
+		values["phone_verified"] = auth.IsPhoneVerified_Auth
+	}
+
+	if providers {
+		linked, err := db.AuthProviders().FindByUserID(ctx, user.Id)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, p := range linked {
+			names = append(names, p.Provider)
+		}
+		values["providers"] = names
+	}
+	return values, nil
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, ";")
+	}
+	return fmt.Sprint(v)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
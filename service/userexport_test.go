@@ -0,0 +1,142 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nori-io/auth/service/database"
+)
+
+// exportDB has a user with a linked provider, an admin and a service account
+func exportDB(t *testing.T) database.Database {
+	t.Helper()
+	ctx := context.Background()
+	db := database.NewMemory()
+	for _, m := range []*database.AuthModel{
+		{Email_Auth: "user@example.com", Phone_Auth: "+15550100", IsEmailVerified_Auth: true, Type_Users: database.UserTypeUser, StatusId_Users: database.UserStatusActive},
+		{Email_Auth: "admin@example.com", Type_Users: database.UserTypeAdmin, StatusId_Users: database.UserStatusActive},
+	} {
+		if err := db.Auth().Create(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AuthProviders().Create(ctx, &database.AuthProvidersModel{Provider: "google", ProviderUserKey: "g1", UserId: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Users().Create(ctx, &database.UsersModel{Type: database.UserTypeService, StatusId: database.UserStatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestWriteUsersCSV(t *testing.T) {
+	var out bytes.Buffer
+	fields := []string{"email", "id", "providers", "email_verified"}
+	if err := WriteUsers(context.Background(), exportDB(t), &out, UsersExportCSV, fields, database.UsersFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"email", "id", "providers", "email_verified"},
+		{"user@example.com", "1", "google", "true"},
+		{"admin@example.com", "2", "", "false"},
+		// the service account has no auth row
+		{"", "3", "", "false"},
+	}
+	if fmt.Sprint(records) != fmt.Sprint(want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestWriteUsersJSONL(t *testing.T) {
+	var out bytes.Buffer
+	filter := database.UsersFilter{Type: database.UserTypeUser}
+	if err := WriteUsers(context.Background(), exportDB(t), &out, UsersExportJSONL, nil, filter); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var user map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &user); err != nil {
+		t.Fatal(err)
+	}
+	// all the fields are exported by default
+	if len(user) != len(UsersExportFields) || user["email"] != "user@example.com" || user["phone"] != "+15550100" || user["type"] != database.UserTypeUser {
+		t.Errorf("user = %v", user)
+	}
+}
+
+func TestWriteUsersPages(t *testing.T) {
+	ctx := context.Background()
+	db := database.NewMemory()
+	total := 2*usersExportPage + 1
+	for n := 0; n < total; n++ {
+		if err := db.Users().Create(ctx, &database.UsersModel{Type: database.UserTypeService}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := WriteUsers(ctx, db, &out, UsersExportCSV, []string{"id"}, database.UsersFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != total+1 || records[total][0] != fmt.Sprint(total) {
+		t.Errorf("%d records, last %v", len(records), records[len(records)-1])
+	}
+}
+
+func TestWriteUsersErrors(t *testing.T) {
+	db := exportDB(t)
+	for name, err := range map[string]error{
+		"unknown field":  WriteUsers(context.Background(), db, &bytes.Buffer{}, UsersExportCSV, []string{"email", "password"}, database.UsersFilter{}),
+		"unknown format": WriteUsers(context.Background(), db, &bytes.Buffer{}, "xml", nil, database.UsersFilter{}),
+	} {
+		if err == nil {
+			t.Errorf("%s is accepted", name)
+		}
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	server, db := newConfiguredServer(t, &Config{}, nil)
+	user := signUp(t, server, "user@example.com")
+
+	if code, body := call(t, server, "GET", "/auth/users/export", signIn(t, server, "user@example.com").Token, nil); code == http.StatusOK {
+		t.Errorf("export by the user: %s", body)
+	}
+
+	admin, err := db.Users().FindByID(context.Background(), signUp(t, server, "admin@example.com").Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin.Type = database.UserTypeAdmin
+	if err := db.Users().Update(context.Background(), admin); err != nil {
+		t.Fatal(err)
+	}
+	token := signIn(t, server, "admin@example.com").Token
+
+	code, body := call(t, server, "GET", "/auth/users/export?format=csv&type=user&fields=id,email", token, nil)
+	if code != http.StatusOK {
+		t.Fatalf("export: %d %s", code, body)
+	}
+	if want := fmt.Sprintf("id,email\n%d,user@example.com\n", user.Id); string(body) != want {
+		t.Errorf("export = %q, want %q", body, want)
+	}
+	if code, body := call(t, server, "GET", "/auth/users/export?fields=password", token, nil); code == http.StatusOK {
+		t.Errorf("unknown field is exported: %s", body)
+	}
+}